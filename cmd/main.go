@@ -1,21 +1,118 @@
 package main
 
 import (
+	"avito-tech-internship/internal/config"
+	"avito-tech-internship/internal/migrate"
 	"avito-tech-internship/internal/server"
+	"avito-tech-internship/internal/tracing"
+	"avito-tech-internship/migrations"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
-	dbURL := os.Getenv("DATABASE_URL")
-	db, err := sqlx.Connect("postgres", dbURL)
+	// app migrate up|down [N]|status [флаги]
+	args := os.Args[1:]
+	var migrateArgs []string
+	if len(args) > 0 && args[0] == "migrate" {
+		migrateArgs, args = splitCommand(args[1:])
+	}
+
+	cfg, printConfig, err := config.Load(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		// Ошибки конфигурации выводим построчно, чтобы было видно все сразу
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+	if printConfig {
+		out, err := cfg.Redacted().YAML()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not print configuration: %v\n", err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(out)
+		return
+	}
+
+	slog.SetDefault(newLogger(cfg.Log))
+	if migrateArgs != nil {
+		err = runMigrate(cfg, migrateArgs)
+	} else {
+		err = run(cfg)
+	}
+	if err != nil {
+		slog.Error("service stopped with error", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run(cfg *config.Config) error {
+	// Отменяется по SIGTERM/SIGINT и запускает мягкую остановку
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		ServiceName: cfg.Tracing.ServiceName,
+	})
+	if err != nil {
+		return fmt.Errorf("could not set up tracing: %w", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	db, err := openDB(ctx, cfg.Database)
 	if err != nil {
-		slog.Error("could not connect to database: %v", err)
+		return err
 	}
+	// Пул закрывается последним - после остановки HTTP-сервера и фоновых задач
 	defer db.Close()
 
-	s := server.NewServer(db)
-	s.Start()
+	migrator, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		return err
+	}
+	if cfg.Database.AutoMigrate {
+		// Реплики применяют миграции по очереди под advisory lock
+		if err := migrator.Up(ctx); err != nil {
+			return fmt.Errorf("could not apply migrations: %w", err)
+		}
+	}
+
+	s := server.NewServer(db, cfg, migrator)
+	return s.Run(ctx)
+}
+
+func openDB(ctx context.Context, cfg config.DatabaseConfig) (*sqlx.DB, error) {
+	db, err := sqlx.ConnectContext(ctx, "postgres", cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to database: %w", err)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	return db, nil
+}
+
+func newLogger(cfg config.LogConfig) *slog.Logger {
+	var level slog.Level
+	// Значение уже проверено при загрузке конфигурации
+	_ = level.UnmarshalText([]byte(cfg.Level))
+
+	opts := &slog.HandlerOptions{Level: level}
+	if cfg.Format == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts))
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, opts))
 }
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"avito-tech-internship/internal/config"
+	"avito-tech-internship/internal/migrate"
+	"avito-tech-internship/migrations"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const migrateUsage = "usage: app migrate up|down [N]|status [flags]"
+
+// splitCommand отделяет аргументы подкоманды от флагов конфигурации
+func splitCommand(args []string) (command, rest []string) {
+	command = []string{}
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command = append(command, args[0])
+		args = args[1:]
+	}
+	return command, args
+}
+
+func runMigrate(cfg *config.Config, args []string) error {
+	// Аргументы проверяем до подключения к БД
+	steps := 1
+	switch {
+	case len(args) == 1 && (args[0] == "up" || args[0] == "status"):
+	case len(args) == 1 && args[0] == "down":
+	case len(args) == 2 && args[0] == "down":
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid number of steps %q", args[1])
+		}
+		steps = n
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	db, err := openDB(ctx, cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		return migrator.Down(ctx, steps)
+	default:
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(status)
+	}
+}
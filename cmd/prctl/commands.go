@@ -0,0 +1,414 @@
+package main
+
+import (
+	"avito-tech-internship/internal/domain"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// parseFlags разбирает флаги вперемешку с позиционными аргументами и проверяет их число
+func parseFlags(fs *flag.FlagSet, args []string, positional int, usage string) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	var rest []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, fmt.Errorf("%w\nusage: prctl %s", err, usage)
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		rest = append(rest, args[0])
+		args = args[1:]
+	}
+	if len(rest) != positional {
+		return nil, fmt.Errorf("usage: prctl %s", usage)
+	}
+	return rest, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func readJSON(path string, v any) error {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Teams
+
+func teamAdd(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("team add", flag.ContinueOnError)
+	file := fs.String("f", "", "team JSON file, - for stdin")
+	mode := fs.String("mode", "", "conflict mode for existing users")
+	if _, err := parseFlags(fs, args, 0, "team add -f FILE [--mode fail|move|skip|join]"); err != nil {
+		return err
+	}
+	if *file == "" {
+		return fmt.Errorf("team add: -f is required")
+	}
+
+	var req domain.CreateTeamRequest
+	if err := readJSON(*file, &req); err != nil {
+		return fmt.Errorf("read %s: %w", *file, err)
+	}
+	if *mode != "" {
+		req.Mode = *mode
+	}
+
+	var resp struct {
+		Team    *domain.Team               `json:"team"`
+		Members []*domain.TeamMemberResult `json:"members"`
+	}
+	if err := a.client.post(ctx, "/team/add", req, &resp); err != nil {
+		return err
+	}
+	return a.printer.print(resp, []string{"USER_ID", "RESULT", "FROM_TEAM"}, memberResultRows(resp.Members))
+}
+
+func memberResultRows(results []*domain.TeamMemberResult) [][]string {
+	rows := make([][]string, 0, len(results))
+	for _, result := range results {
+		rows = append(rows, []string{result.UserID, result.Result, orDash(result.FromTeam)})
+	}
+	return rows
+}
+
+func teamGet(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("team get", flag.ContinueOnError)
+	rest, err := parseFlags(fs, args, 1, "team get NAME")
+	if err != nil {
+		return err
+	}
+
+	var team domain.Team
+	if err := a.client.get(ctx, "/team/get/"+url.PathEscape(rest[0]), nil, &team); err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(team.Members))
+	for _, member := range team.Members {
+		rows = append(rows, []string{team.TeamName, orDash(team.ParentTeamName), member.UserId, member.Username, yesNo(member.IsActive)})
+	}
+	return a.printer.print(team, []string{"TEAM", "PARENT", "USER_ID", "USERNAME", "ACTIVE"}, rows)
+}
+
+func teamTree(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("team tree", flag.ContinueOnError)
+	if _, err := parseFlags(fs, args, 0, "team tree"); err != nil {
+		return err
+	}
+
+	var resp struct {
+		Teams []*domain.TeamNode `json:"teams"`
+	}
+	if err := a.client.get(ctx, "/team/tree", nil, &resp); err != nil {
+		return err
+	}
+
+	var rows [][]string
+	var walk func(nodes []*domain.TeamNode, depth int)
+	walk = func(nodes []*domain.TeamNode, depth int) {
+		for _, node := range nodes {
+			rows = append(rows, []string{strings.Repeat("  ", depth) + node.TeamName})
+			walk(node.Children, depth+1)
+		}
+	}
+	walk(resp.Teams, 0)
+	return a.printer.print(resp, []string{"TEAM"}, rows)
+}
+
+func teamAddMember(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("team add-member", flag.ContinueOnError)
+	share := fs.Float64("share", 0, "review share in (0, 1], default 1")
+	rest, err := parseFlags(fs, args, 2, "team add-member TEAM USER [--share 0.5]")
+	if err != nil {
+		return err
+	}
+
+	req := domain.TeamMemberRequest{TeamName: rest[0], UserID: rest[1]}
+	if *share != 0 {
+		req.ReviewShare = share
+	}
+	return a.userCall(ctx, "/team/addMember", req)
+}
+
+func teamRemoveMember(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("team remove-member", flag.ContinueOnError)
+	rest, err := parseFlags(fs, args, 2, "team remove-member TEAM USER")
+	if err != nil {
+		return err
+	}
+	return a.userCall(ctx, "/team/removeMember", domain.TeamMemberRequest{TeamName: rest[0], UserID: rest[1]})
+}
+
+func teamSetParent(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("team set-parent", flag.ContinueOnError)
+	// Без PARENT команда становится корневой
+	if len(args) == 1 {
+		args = append(args, "")
+	}
+	rest, err := parseFlags(fs, args, 2, "team set-parent TEAM [PARENT]")
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		Team *domain.Team `json:"team"`
+	}
+	req := domain.SetTeamParentRequest{TeamName: rest[0], ParentTeamName: rest[1]}
+	if err := a.client.post(ctx, "/team/setParent", req, &resp); err != nil {
+		return err
+	}
+	return a.printer.print(resp, []string{"TEAM", "PARENT"}, [][]string{{resp.Team.TeamName, orDash(resp.Team.ParentTeamName)}})
+}
+
+// Users
+
+type userResponse struct {
+	User *domain.User `json:"user"`
+}
+
+// userCall выполняет запрос, отвечающий {"user": ...}, и печатает пользователя
+func (a *app) userCall(ctx context.Context, path string, req any) error {
+	var resp userResponse
+	if err := a.client.post(ctx, path, req, &resp); err != nil {
+		return err
+	}
+	return a.printUser(resp)
+}
+
+func (a *app) printUser(resp userResponse) error {
+	user := resp.User
+	teams := make([]string, 0, len(user.Teams))
+	for _, team := range user.Teams {
+		teams = append(teams, team.TeamName)
+	}
+	limit := "-"
+	if user.MaxOpenReviews != nil {
+		limit = strconv.Itoa(*user.MaxOpenReviews)
+	}
+	row := []string{user.UserId, user.Username, yesNo(user.IsActive), orDash(strings.Join(teams, ",")), orDash(strings.Join(user.Skills, ",")), limit}
+	return a.printer.print(resp, []string{"USER_ID", "USERNAME", "ACTIVE", "TEAMS", "SKILLS", "MAX_OPEN_REVIEWS"}, [][]string{row})
+}
+
+func userGet(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("user get", flag.ContinueOnError)
+	rest, err := parseFlags(fs, args, 1, "user get ID")
+	if err != nil {
+		return err
+	}
+
+	var resp userResponse
+	if err := a.client.get(ctx, "/users/getById/"+url.PathEscape(rest[0]), nil, &resp); err != nil {
+		return err
+	}
+	return a.printUser(resp)
+}
+
+func userActivate(ctx context.Context, a *app, args []string) error {
+	return setActive(ctx, a, args, "user activate ID", true)
+}
+
+func userDeactivate(ctx context.Context, a *app, args []string) error {
+	return setActive(ctx, a, args, "user deactivate ID", false)
+}
+
+func setActive(ctx context.Context, a *app, args []string, usage string, isActive bool) error {
+	fs := flag.NewFlagSet(usage, flag.ContinueOnError)
+	rest, err := parseFlags(fs, args, 1, usage)
+	if err != nil {
+		return err
+	}
+	return a.userCall(ctx, "/users/setIsActive", map[string]any{"user_id": rest[0], "is_active": isActive})
+}
+
+func userSetLimit(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("user set-limit", flag.ContinueOnError)
+	rest, err := parseFlags(fs, args, 2, "user set-limit ID N|none")
+	if err != nil {
+		return err
+	}
+
+	req := domain.SetMaxOpenReviewsRequest{UserID: rest[0]}
+	if rest[1] != "none" {
+		limit, err := strconv.Atoi(rest[1])
+		if err != nil {
+			return fmt.Errorf("invalid limit %q", rest[1])
+		}
+		req.MaxOpenReviews = &limit
+	}
+	return a.userCall(ctx, "/users/setMaxOpenReviews", req)
+}
+
+func userReviews(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("user reviews", flag.ContinueOnError)
+	rest, err := parseFlags(fs, args, 1, "user reviews ID")
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		UserID       string                    `json:"user_id"`
+		PullRequests []domain.PullRequestShort `json:"pull_requests"`
+	}
+	if err := a.client.get(ctx, "/users/getReview", url.Values{"user_id": {rest[0]}}, &resp); err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(resp.PullRequests))
+	for _, pr := range resp.PullRequests {
+		rows = append(rows, []string{pr.ID, pr.Name, pr.AuthorID, pr.Status})
+	}
+	return a.printer.print(resp, []string{"PR_ID", "NAME", "AUTHOR", "STATUS"}, rows)
+}
+
+// Pull requests
+
+func (a *app) prCall(ctx context.Context, path string, req any) error {
+	var resp struct {
+		PR         *domain.PullRequest `json:"pr"`
+		ReplacedBy string              `json:"replaced_by,omitempty"`
+	}
+	if err := a.client.post(ctx, path, req, &resp); err != nil {
+		return err
+	}
+	pr := resp.PR
+	row := []string{pr.ID, pr.Name, pr.AuthorId, pr.Status, orDash(strings.Join(pr.AssignedReviewers, ","))}
+	return a.printer.print(resp, []string{"PR_ID", "NAME", "AUTHOR", "STATUS", "REVIEWERS"}, [][]string{row})
+}
+
+func prCreate(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("pr create", flag.ContinueOnError)
+	id := fs.String("id", "", "pull request id")
+	name := fs.String("name", "", "pull request name")
+	author := fs.String("author", "", "author user id")
+	files := fs.String("files", "", "comma-separated changed files")
+	tags := fs.String("tags", "", "comma-separated tags")
+	usage := "pr create --id ID --name NAME --author ID [--files a,b] [--tags x,y]"
+	if _, err := parseFlags(fs, args, 0, usage); err != nil {
+		return err
+	}
+	if *id == "" || *name == "" || *author == "" {
+		return fmt.Errorf("usage: prctl %s", usage)
+	}
+
+	return a.prCall(ctx, "/pullRequest/create", domain.CreatePRRequest{
+		PRID:         *id,
+		Name:         *name,
+		AuthorID:     *author,
+		ChangedFiles: splitList(*files),
+		Tags:         splitList(*tags),
+	})
+}
+
+func prMerge(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("pr merge", flag.ContinueOnError)
+	rest, err := parseFlags(fs, args, 1, "pr merge ID")
+	if err != nil {
+		return err
+	}
+	return a.prCall(ctx, "/pullRequest/merge", domain.MergePRRequest{PRID: rest[0]})
+}
+
+func prReassign(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("pr reassign", flag.ContinueOnError)
+	rest, err := parseFlags(fs, args, 2, "pr reassign ID OLD_REVIEWER")
+	if err != nil {
+		return err
+	}
+	return a.prCall(ctx, "/pullRequest/reassign", domain.ReassignRequest{PRID: rest[0], OldReviewerID: rest[1]})
+}
+
+// Stats
+
+func stats(ctx context.Context, a *app, args []string) error {
+	if len(args) > 0 && args[0] == "pairings" {
+		return pairingStats(ctx, a, args[1:])
+	}
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	view := "summary"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		view, args = args[0], args[1:]
+	}
+	if _, err := parseFlags(fs, args, 0, "stats [users|teams]"); err != nil {
+		return err
+	}
+
+	var resp struct {
+		Stats *domain.StatsResponse `json:"stats"`
+	}
+	if err := a.client.get(ctx, "/stats/getAllStats", nil, &resp); err != nil {
+		return err
+	}
+
+	switch view {
+	case "summary":
+		s := resp.Stats.Summary
+		rows := [][]string{
+			{"users", strconv.Itoa(s.TotalUsers)},
+			{"teams", strconv.Itoa(s.TotalTeams)},
+			{"pull requests", strconv.Itoa(s.TotalPRs)},
+			{"open", strconv.Itoa(s.OpenPRs)},
+			{"merged", strconv.Itoa(s.MergedPRs)},
+			{"reviews", strconv.Itoa(s.TotalReviews)},
+			{"reviews per PR", strconv.Itoa(s.AvgReviewsPerPR)},
+		}
+		return a.printer.print(s, []string{"METRIC", "VALUE"}, rows)
+	case "users":
+		rows := make([][]string, 0, len(resp.Stats.UserStats))
+		for _, u := range resp.Stats.UserStats {
+			rows = append(rows, []string{u.UserID, u.Username, u.TeamName, strconv.Itoa(u.PRCount), strconv.Itoa(u.MergedPRCount)})
+		}
+		return a.printer.print(resp.Stats.UserStats, []string{"USER_ID", "USERNAME", "TEAM", "REVIEWS", "MERGED"}, rows)
+	case "teams":
+		rows := make([][]string, 0, len(resp.Stats.TeamStats))
+		for _, t := range resp.Stats.TeamStats {
+			rows = append(rows, []string{t.TeamName, strconv.Itoa(t.MemberCount), strconv.Itoa(t.AuthoredPRCount), strconv.Itoa(t.ReviewedPRCount), strconv.Itoa(t.MergedPRCount)})
+		}
+		return a.printer.print(resp.Stats.TeamStats, []string{"TEAM", "MEMBERS", "AUTHORED", "REVIEWED", "MERGED"}, rows)
+	default:
+		return fmt.Errorf("usage: prctl stats [users|teams]")
+	}
+}
+
+func pairingStats(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("stats pairings", flag.ContinueOnError)
+	rest, err := parseFlags(fs, args, 1, "stats pairings TEAM")
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		Pairings *domain.PairingMatrix `json:"pairings"`
+	}
+	if err := a.client.get(ctx, "/stats/pairings", url.Values{"team_name": {rest[0]}}, &resp); err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(resp.Pairings.Pairings))
+	for _, p := range resp.Pairings.Pairings {
+		rows = append(rows, []string{p.AuthorID, p.ReviewerID, strconv.Itoa(p.Count)})
+	}
+	return a.printer.print(resp.Pairings, []string{"AUTHOR", "REVIEWER", "COUNT"}, rows)
+}
@@ -0,0 +1,148 @@
+// prctl - консольный клиент для администрирования сервиса назначения ревьюеров.
+// Работает через HTTP API, поэтому подчиняется тем же проверкам и API-ключам, что и остальные клиенты.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const usage = `usage: prctl [flags] <command> [args]
+
+commands:
+  team add -f FILE [--mode fail|move|skip|join]
+  team get NAME
+  team tree
+  team add-member TEAM USER [--share 0.5]
+  team remove-member TEAM USER
+  team set-parent TEAM [PARENT]
+  user get ID
+  user activate ID
+  user deactivate ID
+  user reviews ID
+  user set-limit ID N|none
+  pr create --id ID --name NAME --author ID [--files a,b] [--tags x,y]
+  pr merge ID
+  pr reassign ID OLD_REVIEWER
+  stats [users|teams]
+  stats pairings TEAM
+  export [-f FILE]
+  import -f FILE [--mode fail|move|skip|join]
+
+flags:
+`
+
+// command - обработчик подкоманды, args - аргументы после ее имени
+type command func(ctx context.Context, app *app, args []string) error
+
+type app struct {
+	client  *client
+	printer *printer
+}
+
+var commands = map[string]map[string]command{
+	"team": {
+		"add":           teamAdd,
+		"get":           teamGet,
+		"tree":          teamTree,
+		"add-member":    teamAddMember,
+		"remove-member": teamRemoveMember,
+		"set-parent":    teamSetParent,
+	},
+	"user": {
+		"get":        userGet,
+		"activate":   userActivate,
+		"deactivate": userDeactivate,
+		"reviews":    userReviews,
+		"set-limit":  userSetLimit,
+	},
+	"pr": {
+		"create":   prCreate,
+		"merge":    prMerge,
+		"reassign": prReassign,
+	},
+}
+
+// Команды без второго уровня
+var topLevel = map[string]command{
+	"stats":  stats,
+	"export": exportTeams,
+	"import": importTeams,
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "prctl:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("prctl", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	addr := fs.String("addr", envOr("PRCTL_ADDR", "http://localhost:8080"), "service base URL (env PRCTL_ADDR)")
+	apiKey := fs.String("api-key", os.Getenv("PRCTL_API_KEY"), "API key (env PRCTL_API_KEY)")
+	output := fs.String("o", outputTable, "output format: table or json")
+	timeout := fs.Duration("timeout", 30*time.Second, "request timeout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *output != outputTable && *output != outputJSON {
+		return fmt.Errorf("unknown output format %q", *output)
+	}
+
+	cmd, cmdArgs, err := resolve(fs.Args())
+	if err != nil {
+		fs.Usage()
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	a := &app{
+		client:  &client{baseURL: *addr, apiKey: *apiKey, http: &http.Client{Timeout: *timeout}},
+		printer: &printer{format: *output, w: os.Stdout},
+	}
+	return cmd(ctx, a, cmdArgs)
+}
+
+func resolve(args []string) (command, []string, error) {
+	if len(args) == 0 {
+		return nil, nil, errors.New("command is required")
+	}
+	if cmd, ok := topLevel[args[0]]; ok {
+		return cmd, args[1:], nil
+	}
+	group, ok := commands[args[0]]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown command %q", args[0])
+	}
+	if len(args) < 2 {
+		return nil, nil, fmt.Errorf("%s: subcommand is required", args[0])
+	}
+	cmd, ok := group[args[1]]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown command %q", args[0]+" "+args[1])
+	}
+	return cmd, args[2:], nil
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
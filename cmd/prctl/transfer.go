@@ -0,0 +1,126 @@
+package main
+
+import (
+	"avito-tech-internship/internal/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+// snapshot - формат export/import: команды в порядке от корня к листьям
+type snapshot struct {
+	Teams []*domain.CreateTeamRequest `json:"teams"`
+}
+
+func exportTeams(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	file := fs.String("f", "-", "output file, - for stdout")
+	if _, err := parseFlags(fs, args, 0, "export [-f FILE]"); err != nil {
+		return err
+	}
+
+	var tree struct {
+		Teams []*domain.TeamNode `json:"teams"`
+	}
+	if err := a.client.get(ctx, "/team/tree", nil, &tree); err != nil {
+		return err
+	}
+
+	// Обход в глубину дает родителей раньше детей - в таком порядке команды и создаются при импорте
+	var names []string
+	var walk func(nodes []*domain.TeamNode)
+	walk = func(nodes []*domain.TeamNode) {
+		for _, node := range nodes {
+			names = append(names, node.TeamName)
+			walk(node.Children)
+		}
+	}
+	walk(tree.Teams)
+
+	snap := snapshot{Teams: make([]*domain.CreateTeamRequest, 0, len(names))}
+	for _, name := range names {
+		var team domain.Team
+		if err := a.client.get(ctx, "/team/get/"+url.PathEscape(name), nil, &team); err != nil {
+			return fmt.Errorf("export team %s: %w", name, err)
+		}
+		snap.Teams = append(snap.Teams, &domain.CreateTeamRequest{
+			TeamName:       team.TeamName,
+			ParentTeamName: team.ParentTeamName,
+			Members:        team.Members,
+		})
+	}
+
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if *file == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(*file, data, 0o644)
+}
+
+func importTeams(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	file := fs.String("f", "", "snapshot file produced by export, - for stdin")
+	// Пользователь из нескольких команд при импорте второй команды уже существует - добавляем его в нее
+	mode := fs.String("mode", domain.TeamConflictJoin, "conflict mode for existing users")
+	if _, err := parseFlags(fs, args, 0, "import -f FILE [--mode fail|move|skip|join]"); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New("import: -f is required")
+	}
+
+	var snap snapshot
+	if err := readJSON(*file, &snap); err != nil {
+		return fmt.Errorf("read %s: %w", *file, err)
+	}
+
+	type importResult struct {
+		TeamName string `json:"team_name"`
+		Result   string `json:"result"`
+		Members  int    `json:"members"`
+		Error    string `json:"error,omitempty"`
+	}
+	results := make([]importResult, 0, len(snap.Teams))
+	failed := 0
+	for _, team := range snap.Teams {
+		team.Mode = *mode
+		var resp struct {
+			Team *domain.Team `json:"team"`
+		}
+		err := a.client.post(ctx, "/team/add", team, &resp)
+
+		var apiErr *apiError
+		switch {
+		case err == nil:
+			results = append(results, importResult{TeamName: team.TeamName, Result: "created", Members: len(resp.Team.Members)})
+		case errors.As(err, &apiErr) && apiErr.Code == "TEAM_EXISTS":
+			// Повторный импорт не должен падать на уже созданных командах
+			results = append(results, importResult{TeamName: team.TeamName, Result: "exists"})
+		default:
+			failed++
+			results = append(results, importResult{TeamName: team.TeamName, Result: "failed", Error: err.Error()})
+		}
+	}
+
+	rows := make([][]string, 0, len(results))
+	for _, result := range results {
+		rows = append(rows, []string{result.TeamName, result.Result, strconv.Itoa(result.Members), orDash(result.Error)})
+	}
+	if err := a.printer.print(results, []string{"TEAM", "RESULT", "MEMBERS", "ERROR"}, rows); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("import: %d of %d teams failed", failed, len(snap.Teams))
+	}
+	return nil
+}
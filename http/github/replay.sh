@@ -0,0 +1,17 @@
+#!/bin/sh
+# Повторяет записанный вебхук GitHub с подписью X-Hub-Signature-256.
+# Использование: GITHUB_WEBHOOK_SECRET=... ./replay.sh pull_request_opened.json [delivery-id]
+set -eu
+
+payload=$1
+delivery=${2:-$(date +%s)}
+addr=${ADDR:-http://localhost:8080}
+signature=$(openssl dgst -sha256 -hmac "$GITHUB_WEBHOOK_SECRET" -hex < "$payload" | sed 's/^.* //')
+
+curl -sS "$addr/integrations/github/webhook" \
+  -H "Content-Type: application/json" \
+  -H "X-GitHub-Event: pull_request" \
+  -H "X-GitHub-Delivery: $delivery" \
+  -H "X-Hub-Signature-256: sha256=$signature" \
+  --data-binary "@$payload"
+echo
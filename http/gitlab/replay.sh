@@ -0,0 +1,16 @@
+#!/bin/sh
+# Повторяет записанный Merge Request Hook GitLab.
+# Использование: GITLAB_WEBHOOK_TOKEN=... ./replay.sh merge_request_open.json [delivery-id]
+set -eu
+
+payload=$1
+delivery=${2:-$(date +%s)}
+addr=${ADDR:-http://localhost:8080}
+
+curl -sS "$addr/integrations/gitlab/webhook" \
+  -H "Content-Type: application/json" \
+  -H "X-Gitlab-Event: Merge Request Hook" \
+  -H "X-Gitlab-Event-UUID: $delivery" \
+  -H "X-Gitlab-Token: $GITLAB_WEBHOOK_TOKEN" \
+  --data-binary "@$payload"
+echo
@@ -0,0 +1,89 @@
+// Package background управляет фоновыми задачами сервиса: запуск, остановка и ожидание завершения.
+package background
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Group запускает фоновые задачи с общим контекстом, который отменяется при остановке
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu   sync.Mutex
+	jobs map[string]*JobStatus
+}
+
+// JobStatus - состояние задачи для проверки готовности
+type JobStatus struct {
+	Name      string     `json:"name"`
+	Running   bool       `json:"running"`
+	StartedAt time.Time  `json:"started_at"`
+	StoppedAt *time.Time `json:"stopped_at,omitempty"`
+}
+
+func NewGroup() *Group {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Group{ctx: ctx, cancel: cancel, jobs: make(map[string]*JobStatus)}
+}
+
+// Go запускает задачу. Задача должна завершиться после отмены ctx.
+func (g *Group) Go(name string, fn func(ctx context.Context)) {
+	g.mu.Lock()
+	status := &JobStatus{Name: name, Running: true, StartedAt: time.Now()}
+	g.jobs[name] = status
+	g.mu.Unlock()
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer func() {
+			g.mu.Lock()
+			now := time.Now()
+			status.Running = false
+			status.StoppedAt = &now
+			g.mu.Unlock()
+		}()
+		slog.Info("background job started", "job", name)
+		fn(g.ctx)
+		slog.Info("background job stopped", "job", name)
+	}()
+}
+
+// Status возвращает состояние задач, отсортированное по имени
+func (g *Group) Status() []JobStatus {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(g.jobs))
+	for _, status := range g.jobs {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// Stop отменяет контекст задач и ждет их завершения, но не дольше, чем живет ctx
+func (g *Group) Stop(ctx context.Context) error {
+	g.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,228 @@
+// Package codeowners разбирает файлы владельцев кода в формате GitHub CODEOWNERS
+// и находит владельцев измененных файлов (побеждает последнее совпавшее правило).
+package codeowners
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Виды владельцев
+const (
+	OwnerUser  = "user"
+	OwnerTeam  = "team"
+	OwnerEmail = "email"
+)
+
+type Owner struct {
+	Kind string
+	// Для пользователя - id, для команды - имя команды (часть после "@org/"), для email - адрес
+	Name string
+}
+
+type Rule struct {
+	Pattern string
+	Owners  []Owner
+	Line    int
+	re      *regexp.Regexp
+}
+
+type Ruleset struct {
+	Rules []*Rule
+}
+
+// Parse разбирает содержимое CODEOWNERS. Как и GitHub, не поддерживает
+// отрицание "!" и диапазоны символов "[ ]" - такие строки считаются ошибкой.
+func Parse(content string) (*Ruleset, error) {
+	ruleset := &Ruleset{}
+	for i, line := range strings.Split(content, "\n") {
+		lineNumber := i + 1
+		fields := splitFields(stripComment(strings.TrimRight(line, "\r")))
+		if len(fields) == 0 {
+			continue
+		}
+
+		pattern := fields[0]
+		re, err := compilePattern(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+
+		rule := &Rule{Pattern: pattern, Line: lineNumber, re: re}
+		for _, field := range fields[1:] {
+			owner, err := parseOwner(field)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			rule.Owners = append(rule.Owners, owner)
+		}
+		ruleset.Rules = append(ruleset.Rules, rule)
+	}
+	return ruleset, nil
+}
+
+// Match возвращает последнее правило, совпавшее с путем, или nil.
+// Правило без владельцев тоже может совпасть - тогда у файла нет владельцев.
+func (rs *Ruleset) Match(filePath string) *Rule {
+	filePath = strings.TrimPrefix(filePath, "/")
+	for i := len(rs.Rules) - 1; i >= 0; i-- {
+		if rs.Rules[i].re.MatchString(filePath) {
+			return rs.Rules[i]
+		}
+	}
+	return nil
+}
+
+// Owners собирает владельцев всех путей без повторов, в порядке появления
+func (rs *Ruleset) Owners(filePaths []string) []Owner {
+	seen := make(map[Owner]bool)
+	var owners []Owner
+	for _, filePath := range filePaths {
+		rule := rs.Match(filePath)
+		if rule == nil {
+			continue
+		}
+		for _, owner := range rule.Owners {
+			if !seen[owner] {
+				seen[owner] = true
+				owners = append(owners, owner)
+			}
+		}
+	}
+	return owners
+}
+
+// Комментарий начинается с "#", экранированный "\#" комментарием не считается
+func stripComment(line string) string {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// Разбиение по пробелам с учетом экранирования "\ " в шаблоне
+func splitFields(line string) []string {
+	var fields []string
+	var current strings.Builder
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && i+1 < len(line):
+			current.WriteByte(c)
+			current.WriteByte(line[i+1])
+			i++
+		case c == ' ' || c == '\t':
+			if current.Len() > 0 {
+				fields = append(fields, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteByte(c)
+		}
+	}
+	if current.Len() > 0 {
+		fields = append(fields, current.String())
+	}
+	return fields
+}
+
+func parseOwner(field string) (Owner, error) {
+	if strings.HasPrefix(field, "@") {
+		name := field[1:]
+		if name == "" {
+			return Owner{}, fmt.Errorf("empty owner %q", field)
+		}
+		if org, team, ok := strings.Cut(name, "/"); ok {
+			if org == "" || team == "" || strings.Contains(team, "/") {
+				return Owner{}, fmt.Errorf("invalid team owner %q", field)
+			}
+			return Owner{Kind: OwnerTeam, Name: team}, nil
+		}
+		return Owner{Kind: OwnerUser, Name: name}, nil
+	}
+	if strings.Contains(field, "@") {
+		return Owner{Kind: OwnerEmail, Name: field}, nil
+	}
+	return Owner{}, fmt.Errorf("invalid owner %q: expected @user, @org/team or email", field)
+}
+
+// compilePattern переводит шаблон CODEOWNERS в регулярное выражение по правилам gitignore:
+//   - шаблон с "/" в начале или в середине привязан к корню репозитория, иначе совпадает на любой глубине;
+//   - "/" в конце означает директорию: совпадает все ее содержимое;
+//   - "*" - любые символы кроме "/", "?" - один символ кроме "/";
+//   - "**/" в начале - любые директории, "/**" в конце - все внутри, "/**/" - ноль или больше директорий;
+//   - шаблон, совпавший с директорией, распространяется на все файлы внутри нее,
+//     кроме шаблонов вида "docs/*", которые (как в GitHub) не затрагивают вложенные директории.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if strings.HasPrefix(pattern, "!") {
+		return nil, fmt.Errorf("negation patterns are not supported: %q", pattern)
+	}
+	if strings.ContainsAny(unescaped(pattern), "[]") {
+		return nil, fmt.Errorf("character ranges are not supported: %q", pattern)
+	}
+
+	dirOnly := strings.HasSuffix(pattern, "/")
+	body := strings.TrimSuffix(pattern, "/")
+	anchored := strings.HasPrefix(body, "/") || strings.Contains(strings.TrimPrefix(body, "/"), "/")
+	body = strings.TrimPrefix(body, "/")
+	if body == "" {
+		return nil, fmt.Errorf("empty pattern %q", pattern)
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	if !anchored {
+		expr.WriteString("(?:.*/)?")
+	}
+
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case c == '\\' && i+1 < len(body):
+			i++
+			expr.WriteString(regexp.QuoteMeta(string(body[i])))
+		case strings.HasPrefix(body[i:], "**/") && (i == 0 || body[i-1] == '/'):
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(body[i:], "**") && i+2 == len(body) && (i == 0 || body[i-1] == '/'):
+			expr.WriteString(".*")
+			i++
+		case c == '*':
+			expr.WriteString("[^/]*")
+		case c == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	switch {
+	case dirOnly:
+		expr.WriteString("/.*$")
+	case strings.HasSuffix(body, "/*"):
+		// "docs/*" - только файлы непосредственно в docs, без вложенных директорий
+		expr.WriteString("$")
+	default:
+		expr.WriteString("(?:/.*)?$")
+	}
+	return regexp.Compile(expr.String())
+}
+
+// Шаблон без экранированных символов - для проверки неподдерживаемого синтаксиса
+func unescaped(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '\\' {
+			i++
+			continue
+		}
+		b.WriteByte(pattern[i])
+	}
+	return b.String()
+}
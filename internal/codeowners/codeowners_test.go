@@ -0,0 +1,170 @@
+package codeowners
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		path    string
+		want    bool
+	}{
+		// "/" в начале привязывает шаблон к корню
+		{"anchored root file", "/build.go", "build.go", true},
+		{"anchored does not match nested", "/build.go", "cmd/build.go", false},
+		{"unanchored matches nested", "build.go", "cmd/build.go", true},
+		{"slash in middle anchors", "cmd/main.go", "tools/cmd/main.go", false},
+		{"leading slash in path ignored", "/build.go", "/build.go", true},
+
+		// "docs/*" не затрагивает вложенные директории
+		{"star direct child", "docs/*", "docs/readme.md", true},
+		{"star skips nested", "docs/*", "docs/api/readme.md", false},
+		{"star extension", "*.go", "internal/storage/db.go", true},
+		{"star does not cross slash", "internal/*.go", "internal/storage/db.go", false},
+		{"question mark single char", "v?.txt", "v1.txt", true},
+		{"question mark not slash", "a?b", "a/b", false},
+
+		// "**" в начале, середине и конце
+		{"double star prefix root", "**/logs", "logs/app.log", true},
+		{"double star prefix nested", "**/logs", "var/tmp/logs/app.log", true},
+		{"double star middle zero dirs", "a/**/b.go", "a/b.go", true},
+		{"double star middle many dirs", "a/**/b.go", "a/x/y/b.go", true},
+		{"double star middle other root", "a/**/b.go", "c/a/x/b.go", false},
+		{"double star suffix", "vendor/**", "vendor/github.com/pkg/x.go", true},
+		{"double star suffix other dir", "vendor/**", "vendored/x.go", false},
+
+		// "/" в конце означает директорию
+		{"dir matches contents", "migrations/", "migrations/001_init.sql", true},
+		{"dir matches at any depth", "migrations/", "db/migrations/001_init.sql", true},
+		{"dir does not match file", "migrations/", "migrations", false},
+		{"dir matches deep contents", "/internal/", "internal/storage/db.go", true},
+		{"plain name matches dir", "internal", "internal/storage/db.go", true},
+
+		// Экранирование
+		{"escaped hash", `\#notes.md`, "#notes.md", true},
+		{"escaped space", `my\ file.txt`, "docs/my file.txt", true},
+		{"escaped star is literal", `a\*.go`, "a*.go", true},
+		{"escaped star not wildcard", `a\*.go`, "ab.go", false},
+		{"dot is literal", "*.go", "main_go", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, err := Parse(tt.pattern + " @owner")
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.pattern, err)
+			}
+			if got := rs.Match(tt.path) != nil; got != tt.want {
+				t.Errorf("pattern %q, path %q: match = %v, want %v", tt.pattern, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []*Rule
+		wantErr string
+	}{
+		{
+			name: "comments and owners",
+			content: "# комментарий\n" +
+				"\n" +
+				"*.go @alice @org/backend dev@example.com # владельцы Go\r\n" +
+				`\#hash.md @bob`,
+			want: []*Rule{
+				{Pattern: "*.go", Line: 3, Owners: []Owner{
+					{Kind: OwnerUser, Name: "alice"},
+					{Kind: OwnerTeam, Name: "backend"},
+					{Kind: OwnerEmail, Name: "dev@example.com"},
+				}},
+				{Pattern: `\#hash.md`, Line: 4, Owners: []Owner{{Kind: OwnerUser, Name: "bob"}}},
+			},
+		},
+		{
+			name:    "escaped space stays in pattern",
+			content: `docs/my\ file.txt @alice`,
+			want: []*Rule{
+				{Pattern: `docs/my\ file.txt`, Line: 1, Owners: []Owner{{Kind: OwnerUser, Name: "alice"}}},
+			},
+		},
+		{
+			name:    "rule without owners",
+			content: "generated/",
+			want:    []*Rule{{Pattern: "generated/", Line: 1}},
+		},
+		{name: "negation rejected", content: "!*.go @alice", wantErr: "line 1: negation"},
+		{name: "range rejected", content: "*.go @alice\nfile[0-9].txt @bob", wantErr: "line 2: character ranges"},
+		{name: "escaped brackets allowed", content: `file\[1\].txt @bob`},
+		{name: "invalid owner", content: "*.go alice", wantErr: "line 1: invalid owner"},
+		{name: "invalid team", content: "*.go @org/", wantErr: "line 1: invalid team owner"},
+		{name: "nested team", content: "*.go @org/a/b", wantErr: "line 1: invalid team owner"},
+		{name: "empty owner", content: "*.go @", wantErr: "line 1: empty owner"},
+		{name: "root only pattern", content: "/ @alice", wantErr: "line 1: empty pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, err := Parse(tt.content)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse error = %v, want prefix %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if tt.want == nil {
+				return
+			}
+			for _, rule := range rs.Rules {
+				rule.re = nil
+			}
+			if !reflect.DeepEqual(rs.Rules, tt.want) {
+				t.Errorf("rules = %+v, want %+v", rs.Rules, tt.want)
+			}
+		})
+	}
+}
+
+func TestLastMatchWins(t *testing.T) {
+	rs, err := Parse("* @default\n" +
+		"*.go @gopher\n" +
+		"/internal/storage/ @org/dba\n" +
+		"/internal/storage/generated/\n")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		wantLine int
+	}{
+		{"README.md", 1},
+		{"cmd/main.go", 2},
+		{"internal/storage/db.go", 3},
+		{"internal/storage/generated/models.go", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rule := rs.Match(tt.path)
+			if rule == nil || rule.Line != tt.wantLine {
+				t.Fatalf("Match(%q) = %+v, want rule at line %d", tt.path, rule, tt.wantLine)
+			}
+		})
+	}
+
+	// Совпадение с правилом без владельцев снимает владельцев предыдущих правил
+	got := rs.Owners([]string{"internal/storage/generated/models.go", "cmd/main.go", "main.go", "internal/storage/db.go"})
+	want := []Owner{{Kind: OwnerUser, Name: "gopher"}, {Kind: OwnerTeam, Name: "dba"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Owners = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,744 @@
+// Package config собирает конфигурацию сервиса из значений по умолчанию, YAML-файла,
+// переменных окружения и флагов командной строки (в порядке возрастания приоритета).
+package config
+
+import (
+	"avito-tech-internship/internal/scheduler"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+type Config struct {
+	Server        ServerConfig        `yaml:"server"`
+	Database      DatabaseConfig      `yaml:"database"`
+	Log           LogConfig           `yaml:"log"`
+	Tracing       TracingConfig       `yaml:"tracing"`
+	Reviewers     ReviewersConfig     `yaml:"reviewers"`
+	Auth          AuthConfig          `yaml:"auth"`
+	Features      FeaturesConfig      `yaml:"features"`
+	Webhooks      WebhooksConfig      `yaml:"webhooks"`
+	Outbox        OutboxConfig        `yaml:"outbox"`
+	Integrations  IntegrationsConfig  `yaml:"integrations"`
+	Notifications NotificationsConfig `yaml:"notifications"`
+	Digest        DigestConfig        `yaml:"digest"`
+	SLA           SLAConfig           `yaml:"sla"`
+	Stale         StaleConfig         `yaml:"stale"`
+	Scheduler     SchedulerConfig     `yaml:"scheduler"`
+	Events        EventsConfig        `yaml:"events"`
+}
+
+type ServerConfig struct {
+	ListenAddr string `yaml:"listen_addr"`
+	// Предельное время обработки запроса, 0 - без ограничения
+	RequestTimeout    time.Duration `yaml:"request_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	// Пауза между снятием готовности и остановкой приема соединений
+	ShutdownDelay time.Duration `yaml:"shutdown_delay"`
+	// Сколько ждать завершения текущих запросов и фоновых задач при остановке
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+type DatabaseConfig struct {
+	URL             string        `yaml:"url"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+	// Применять встроенные миграции при старте
+	AutoMigrate bool `yaml:"auto_migrate"`
+}
+
+type LogConfig struct {
+	// debug, info, warn или error
+	Level string `yaml:"level"`
+	// text или json
+	Format string `yaml:"format"`
+}
+
+type TracingConfig struct {
+	// none, otlp или stdout. Адрес коллектора задается стандартной OTEL_EXPORTER_OTLP_ENDPOINT.
+	Exporter    string `yaml:"exporter"`
+	ServiceName string `yaml:"service_name"`
+}
+
+type ReviewersConfig struct {
+	// Сколько ревьюеров назначать на новый PR
+	Count int `yaml:"count"`
+}
+
+type AuthConfig struct {
+	// Ключи доступа к API. Пустой список - аутентификация выключена.
+	APIKeys []string `yaml:"api_keys"`
+}
+
+// Правила подбора ревьюеров сверх базового случайного выбора из команды автора.
+// По умолчанию выключены, чтобы обновление не меняло назначение ревьюеров
+type FeaturesConfig struct {
+	SkillMatching  bool `yaml:"skill_matching"`
+	Codeowners     bool `yaml:"codeowners"`
+	PairingHistory bool `yaml:"pairing_history"`
+	TeamEscalation bool `yaml:"team_escalation"`
+}
+
+type WebhooksConfig struct {
+	// Запускать отправку вебхуков в этом экземпляре, по умолчанию выключено
+	Enabled      bool          `yaml:"enabled"`
+	PollInterval time.Duration `yaml:"poll_interval"`
+	BatchSize    int           `yaml:"batch_size"`
+	// После стольких неудачных попыток доставка попадает в dead-letter список
+	MaxAttempts    int           `yaml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	Timeout        time.Duration `yaml:"timeout"`
+}
+
+type IntegrationsConfig struct {
+	GitHub GitHubConfig `yaml:"github"`
+	GitLab GitLabConfig `yaml:"gitlab"`
+}
+
+type GitHubConfig struct {
+	// Секрет вебхука GitHub. Пустой - прием вебхуков выключен
+	WebhookSecret string `yaml:"webhook_secret"`
+}
+
+type GitLabConfig struct {
+	// Секретный токен вебхука GitLab. Пустой - прием вебхуков выключен
+	WebhookToken string `yaml:"webhook_token"`
+	// Записывать назначенных ревьюеров обратно в MR через API
+	WriteBack bool   `yaml:"write_back"`
+	URL       string `yaml:"url"`
+	// Токен доступа к API с правом api
+	APIToken string        `yaml:"api_token"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+type NotificationsConfig struct {
+	// Отправлять уведомления в чат (через outbox relay)
+	Enabled bool `yaml:"enabled"`
+	// Очередь сообщений: как часто проверять, сколько брать за раз и сколько раз повторять
+	PollInterval   time.Duration         `yaml:"poll_interval"`
+	BatchSize      int                   `yaml:"batch_size"`
+	MaxAttempts    int                   `yaml:"max_attempts"`
+	InitialBackoff time.Duration         `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration         `yaml:"max_backoff"`
+	Timeout        time.Duration         `yaml:"timeout"`
+	Templates      NotificationTemplates `yaml:"templates"`
+}
+
+// Шаблоны text/template, поля - notify.MessageData
+type NotificationTemplates struct {
+	Assigned   string `yaml:"assigned"`
+	Reassigned string `yaml:"reassigned"`
+	Merged     string `yaml:"merged"`
+}
+
+type DigestConfig struct {
+	// Отправлять ежедневную сводку ревью по email
+	Enabled bool `yaml:"enabled"`
+	// Время отправки ЧЧ:ММ в часовом поясе Timezone
+	SendAt   string `yaml:"send_at"`
+	Timezone string `yaml:"timezone"`
+	// Шаблоны text/template, поля - digest.Data
+	Subject string     `yaml:"subject"`
+	Body    string     `yaml:"body"`
+	SMTP    SMTPConfig `yaml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Addr     string        `yaml:"addr"`
+	Username string        `yaml:"username"`
+	Password string        `yaml:"password"`
+	From     string        `yaml:"from"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+type SLAConfig struct {
+	// SLA ответа ревьюера для команд без собственного значения
+	Default time.Duration `yaml:"default"`
+	// Cron-расписание сканера нарушений, по умолчанию пустое - сканер выключен
+	Schedule string `yaml:"schedule"`
+}
+
+type StaleConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Через сколько без активности PR помечается устаревшим
+	MarkAfter time.Duration `yaml:"mark_after"`
+	// Через сколько без активности устаревший PR закрывается, 0 - не закрывать
+	CloseAfter time.Duration `yaml:"close_after"`
+	// Cron-расписание проверки
+	Schedule string `yaml:"schedule"`
+}
+
+type SchedulerConfig struct {
+	// Выполнять задания в этом экземпляре, по умолчанию выключено. Каждое задание выполняет одна реплика,
+	// поэтому его можно включать везде; выключенный планировщик не запускает задания и не принимает ручной запуск
+	Enabled bool `yaml:"enabled"`
+	// Часовой пояс расписаний без CRON_TZ=
+	Timezone string `yaml:"timezone"`
+	// Очистка истории заданий, опубликованных событий outbox и других служебных записей
+	CleanupSchedule  string        `yaml:"cleanup_schedule"`
+	HistoryRetention time.Duration `yaml:"history_retention"`
+}
+
+type EventsConfig struct {
+	// Включить GET /events/stream
+	StreamEnabled bool `yaml:"stream_enabled"`
+	// Как часто проверять outbox на новые события, пока открыт хотя бы один поток
+	PollInterval time.Duration `yaml:"poll_interval"`
+	// Как часто отправлять keep-alive комментарий в открытый поток
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
+}
+
+type OutboxConfig struct {
+	PollInterval time.Duration `yaml:"poll_interval"`
+	BatchSize    int           `yaml:"batch_size"`
+	// На сколько relay забирает пачку событий; после падения реплики события вернутся в очередь через lease
+	Lease time.Duration `yaml:"lease"`
+	// После max_attempts неудачных попыток событие больше не публикуется
+	MaxAttempts    int           `yaml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	// Куда публиковать события: webhook, stdout, bus. Пустой список (по умолчанию) выключает relay
+	Sinks []string `yaml:"sinks"`
+	// Префикс subject для sink bus
+	BusSubjectPrefix string `yaml:"bus_subject_prefix"`
+}
+
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			ListenAddr:        ":8080",
+			RequestTimeout:    10 * time.Second,
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			ShutdownTimeout:   20 * time.Second,
+		},
+		Database: DatabaseConfig{
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			AutoMigrate:     true,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "text",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			ServiceName: "reviewer-service",
+		},
+		Reviewers: ReviewersConfig{
+			Count: 2,
+		},
+		Webhooks: WebhooksConfig{
+			PollInterval:   2 * time.Second,
+			BatchSize:      50,
+			MaxAttempts:    8,
+			InitialBackoff: 10 * time.Second,
+			MaxBackoff:     time.Hour,
+			Timeout:        10 * time.Second,
+		},
+		Outbox: OutboxConfig{
+			PollInterval:     time.Second,
+			BatchSize:        100,
+			Lease:            time.Minute,
+			MaxAttempts:      10,
+			InitialBackoff:   time.Second,
+			MaxBackoff:       5 * time.Minute,
+			BusSubjectPrefix: "reviewer.",
+		},
+		Notifications: NotificationsConfig{
+			PollInterval:   2 * time.Second,
+			BatchSize:      50,
+			MaxAttempts:    6,
+			InitialBackoff: 10 * time.Second,
+			MaxBackoff:     30 * time.Minute,
+			Timeout:        5 * time.Second,
+			Templates: NotificationTemplates{
+				Assigned:   `{{.Mentions}} you have been assigned to review {{.PRID}} "{{.PRName}}" by {{.Author}}`,
+				Reassigned: `{{.Mentions}} you replaced {{.OldReviewer}} as reviewer of {{.PRID}} "{{.PRName}}" by {{.Author}}`,
+				Merged:     `{{.PRID}} "{{.PRName}}" by {{.Author}} has been merged, thanks for the review {{.Mentions}}`,
+			},
+		},
+		Digest: DigestConfig{
+			SendAt:   "09:00",
+			Timezone: "UTC",
+			Subject:  `{{.Count}} pull request(s) waiting for your review`,
+			Body: `Hi {{.Username}},
+
+These pull requests are waiting for your review:
+{{range .PRs}}- {{.ID}} "{{.Name}}" by {{.AuthorID}}, open for {{.Age}}
+{{end}}`,
+			SMTP: SMTPConfig{
+				Addr:    "localhost:25",
+				Timeout: 30 * time.Second,
+			},
+		},
+		SLA: SLAConfig{
+			Default: 24 * time.Hour,
+		},
+		Stale: StaleConfig{
+			MarkAfter:  14 * 24 * time.Hour,
+			CloseAfter: 30 * 24 * time.Hour,
+			Schedule:   "0 * * * *",
+		},
+		Events: EventsConfig{
+			StreamEnabled:     true,
+			PollInterval:      time.Second,
+			HeartbeatInterval: 15 * time.Second,
+		},
+		Scheduler: SchedulerConfig{
+			Timezone:         "UTC",
+			CleanupSchedule:  "30 3 * * *",
+			HistoryRetention: 30 * 24 * time.Hour,
+		},
+		Integrations: IntegrationsConfig{
+			GitLab: GitLabConfig{
+				Timeout: 10 * time.Second,
+			},
+		},
+	}
+}
+
+// Привязка параметра к переменной окружения и (необязательно) к флагу
+type binding struct {
+	env    string
+	flag   string
+	usage  string
+	target any
+}
+
+func (c *Config) bindings() []binding {
+	return []binding{
+		{"LISTEN_ADDR", "listen-addr", "HTTP listen address", &c.Server.ListenAddr},
+		{"REQUEST_TIMEOUT", "request-timeout", "per-request deadline, 0 disables", &c.Server.RequestTimeout},
+		{"HTTP_READ_TIMEOUT", "", "", &c.Server.ReadTimeout},
+		{"HTTP_READ_HEADER_TIMEOUT", "", "", &c.Server.ReadHeaderTimeout},
+		{"HTTP_WRITE_TIMEOUT", "", "", &c.Server.WriteTimeout},
+		{"HTTP_IDLE_TIMEOUT", "", "", &c.Server.IdleTimeout},
+		{"SHUTDOWN_DELAY", "", "", &c.Server.ShutdownDelay},
+		{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "graceful shutdown timeout", &c.Server.ShutdownTimeout},
+		{"DATABASE_URL", "database-url", "Postgres connection URL", &c.Database.URL},
+		{"DB_MAX_OPEN_CONNS", "db-max-open-conns", "max open DB connections, 0 - unlimited", &c.Database.MaxOpenConns},
+		{"DB_MAX_IDLE_CONNS", "db-max-idle-conns", "max idle DB connections", &c.Database.MaxIdleConns},
+		{"DB_CONN_MAX_LIFETIME", "", "", &c.Database.ConnMaxLifetime},
+		{"DB_CONN_MAX_IDLE_TIME", "", "", &c.Database.ConnMaxIdleTime},
+		{"DB_AUTO_MIGRATE", "auto-migrate", "apply embedded migrations on startup", &c.Database.AutoMigrate},
+		{"LOG_LEVEL", "log-level", "debug, info, warn or error", &c.Log.Level},
+		{"LOG_FORMAT", "log-format", "text or json", &c.Log.Format},
+		{"OTEL_TRACES_EXPORTER", "traces-exporter", "none, otlp or stdout", &c.Tracing.Exporter},
+		{"OTEL_SERVICE_NAME", "", "", &c.Tracing.ServiceName},
+		{"REVIEWERS_COUNT", "reviewers-count", "reviewers assigned to a new PR", &c.Reviewers.Count},
+		{"API_KEYS", "", "", &c.Auth.APIKeys},
+		{"FEATURE_SKILL_MATCHING", "", "", &c.Features.SkillMatching},
+		{"FEATURE_CODEOWNERS", "", "", &c.Features.Codeowners},
+		{"FEATURE_PAIRING_HISTORY", "", "", &c.Features.PairingHistory},
+		{"FEATURE_TEAM_ESCALATION", "", "", &c.Features.TeamEscalation},
+		{"WEBHOOKS_ENABLED", "", "", &c.Webhooks.Enabled},
+		{"WEBHOOK_POLL_INTERVAL", "", "", &c.Webhooks.PollInterval},
+		{"WEBHOOK_BATCH_SIZE", "", "", &c.Webhooks.BatchSize},
+		{"WEBHOOK_MAX_ATTEMPTS", "", "", &c.Webhooks.MaxAttempts},
+		{"WEBHOOK_INITIAL_BACKOFF", "", "", &c.Webhooks.InitialBackoff},
+		{"WEBHOOK_MAX_BACKOFF", "", "", &c.Webhooks.MaxBackoff},
+		{"WEBHOOK_TIMEOUT", "", "", &c.Webhooks.Timeout},
+		{"OUTBOX_POLL_INTERVAL", "", "", &c.Outbox.PollInterval},
+		{"OUTBOX_BATCH_SIZE", "", "", &c.Outbox.BatchSize},
+		{"OUTBOX_LEASE", "", "", &c.Outbox.Lease},
+		{"OUTBOX_MAX_ATTEMPTS", "", "", &c.Outbox.MaxAttempts},
+		{"OUTBOX_INITIAL_BACKOFF", "", "", &c.Outbox.InitialBackoff},
+		{"OUTBOX_MAX_BACKOFF", "", "", &c.Outbox.MaxBackoff},
+		{"OUTBOX_SINKS", "", "", &c.Outbox.Sinks},
+		{"OUTBOX_BUS_SUBJECT_PREFIX", "", "", &c.Outbox.BusSubjectPrefix},
+		{"NOTIFICATIONS_ENABLED", "", "", &c.Notifications.Enabled},
+		{"NOTIFICATIONS_POLL_INTERVAL", "", "", &c.Notifications.PollInterval},
+		{"NOTIFICATIONS_BATCH_SIZE", "", "", &c.Notifications.BatchSize},
+		{"NOTIFICATIONS_MAX_ATTEMPTS", "", "", &c.Notifications.MaxAttempts},
+		{"NOTIFICATIONS_INITIAL_BACKOFF", "", "", &c.Notifications.InitialBackoff},
+		{"NOTIFICATIONS_MAX_BACKOFF", "", "", &c.Notifications.MaxBackoff},
+		{"NOTIFICATIONS_TIMEOUT", "", "", &c.Notifications.Timeout},
+		{"DIGEST_ENABLED", "", "", &c.Digest.Enabled},
+		{"DIGEST_SEND_AT", "", "", &c.Digest.SendAt},
+		{"DIGEST_TIMEZONE", "", "", &c.Digest.Timezone},
+		{"SMTP_ADDR", "", "", &c.Digest.SMTP.Addr},
+		{"SMTP_USERNAME", "", "", &c.Digest.SMTP.Username},
+		{"SMTP_PASSWORD", "", "", &c.Digest.SMTP.Password},
+		{"SMTP_FROM", "", "", &c.Digest.SMTP.From},
+		{"SMTP_TIMEOUT", "", "", &c.Digest.SMTP.Timeout},
+		{"REVIEW_SLA", "review-sla", "default review SLA", &c.SLA.Default},
+		{"SLA_SCHEDULE", "", "", &c.SLA.Schedule},
+		{"STALE_ENABLED", "", "", &c.Stale.Enabled},
+		{"STALE_MARK_AFTER", "", "", &c.Stale.MarkAfter},
+		{"STALE_CLOSE_AFTER", "", "", &c.Stale.CloseAfter},
+		{"STALE_SCHEDULE", "", "", &c.Stale.Schedule},
+		{"EVENTS_STREAM_ENABLED", "", "", &c.Events.StreamEnabled},
+		{"EVENTS_POLL_INTERVAL", "", "", &c.Events.PollInterval},
+		{"EVENTS_HEARTBEAT_INTERVAL", "", "", &c.Events.HeartbeatInterval},
+		{"SCHEDULER_ENABLED", "", "", &c.Scheduler.Enabled},
+		{"SCHEDULER_TIMEZONE", "", "", &c.Scheduler.Timezone},
+		{"CLEANUP_SCHEDULE", "", "", &c.Scheduler.CleanupSchedule},
+		{"HISTORY_RETENTION", "", "", &c.Scheduler.HistoryRetention},
+		{"GITHUB_WEBHOOK_SECRET", "", "", &c.Integrations.GitHub.WebhookSecret},
+		{"GITLAB_WEBHOOK_TOKEN", "", "", &c.Integrations.GitLab.WebhookToken},
+		{"GITLAB_WRITE_BACK", "", "", &c.Integrations.GitLab.WriteBack},
+		{"GITLAB_URL", "", "", &c.Integrations.GitLab.URL},
+		{"GITLAB_API_TOKEN", "", "", &c.Integrations.GitLab.APIToken},
+		{"GITLAB_TIMEOUT", "", "", &c.Integrations.GitLab.Timeout},
+	}
+}
+
+// Load читает конфигурацию. Путь к файлу задается флагом --config или CONFIG_FILE.
+// printConfig - запрошен режим --print-config.
+func Load(args []string) (cfg *Config, printConfig bool, err error) {
+	cfg = Default()
+	bindings := cfg.bindings()
+
+	fs := flag.NewFlagSet("reviewer-service", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("CONFIG_FILE"), "path to YAML config file")
+	fs.BoolVar(&printConfig, "print-config", false, "print effective configuration and exit")
+
+	// Значения флагов применяются последними, поэтому сначала только запоминаем их
+	type flagValue struct {
+		binding binding
+		raw     string
+	}
+	var flagValues []flagValue
+	for _, b := range bindings {
+		if b.flag == "" {
+			continue
+		}
+		b := b
+		remember := func(raw string) error {
+			if err := set(b.target, raw); err != nil {
+				return err
+			}
+			flagValues = append(flagValues, flagValue{binding: b, raw: raw})
+			return nil
+		}
+		usage := b.usage + " (env " + b.env + ")"
+		if _, ok := b.target.(*bool); ok {
+			fs.BoolFunc(b.flag, usage, remember)
+		} else {
+			fs.Func(b.flag, usage, remember)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, false, err
+	}
+
+	if *configPath != "" {
+		if err := cfg.loadFile(*configPath); err != nil {
+			return nil, false, err
+		}
+	}
+
+	for _, b := range bindings {
+		// Пустые переменные считаем незаданными, как и раньше
+		raw := os.Getenv(b.env)
+		if raw == "" {
+			continue
+		}
+		if err := set(b.target, raw); err != nil {
+			return nil, false, fmt.Errorf("env %s: %w", b.env, err)
+		}
+	}
+
+	for _, fv := range flagValues {
+		if err := set(fv.binding.target, fv.raw); err != nil {
+			return nil, false, fmt.Errorf("flag --%s: %w", fv.binding.flag, err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, false, err
+	}
+	return cfg, printConfig, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	// Опечатки в ключах должны быть ошибкой, а не молча игнорироваться
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func set(target any, raw string) error {
+	raw = strings.TrimSpace(raw)
+	switch t := target.(type) {
+	case *string:
+		*t = raw
+	case *int:
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		*t = v
+	case *bool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		*t = v
+	case *time.Duration:
+		v, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		*t = v
+	case *[]string:
+		*t = nil
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*t = append(*t, item)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported config value type %T", target)
+	}
+	return nil
+}
+
+const minAPIKeyLength = 16
+
+// Validate возвращает все найденные ошибки сразу
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.ListenAddr != "", "server.listen_addr must not be empty")
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"server.request_timeout", c.Server.RequestTimeout},
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.read_header_timeout", c.Server.ReadHeaderTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.idle_timeout", c.Server.IdleTimeout},
+		{"server.shutdown_delay", c.Server.ShutdownDelay},
+		{"server.shutdown_timeout", c.Server.ShutdownTimeout},
+		{"database.conn_max_lifetime", c.Database.ConnMaxLifetime},
+		{"database.conn_max_idle_time", c.Database.ConnMaxIdleTime},
+	}
+	for _, d := range durations {
+		check(d.value >= 0, "%s must not be negative", d.name)
+	}
+	if c.Server.WriteTimeout > 0 && c.Server.RequestTimeout > 0 {
+		check(c.Server.WriteTimeout > c.Server.RequestTimeout,
+			"server.write_timeout (%s) must be greater than server.request_timeout (%s)", c.Server.WriteTimeout, c.Server.RequestTimeout)
+	}
+
+	check(c.Database.URL != "", "database.url must be set (DATABASE_URL)")
+	if c.Database.URL != "" {
+		_, err := url.Parse(c.Database.URL)
+		check(err == nil, "database.url is not a valid URL")
+	}
+	check(c.Database.MaxOpenConns >= 0, "database.max_open_conns must not be negative")
+	check(c.Database.MaxIdleConns >= 0, "database.max_idle_conns must not be negative")
+	if c.Database.MaxOpenConns > 0 {
+		check(c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+			"database.max_idle_conns (%d) must not exceed database.max_open_conns (%d)", c.Database.MaxIdleConns, c.Database.MaxOpenConns)
+	}
+
+	check(oneOf(c.Log.Level, "debug", "info", "warn", "error"), "log.level must be one of debug, info, warn, error, got %q", c.Log.Level)
+	check(oneOf(c.Log.Format, "text", "json"), "log.format must be text or json, got %q", c.Log.Format)
+	check(c.Tracing.ServiceName != "", "tracing.service_name must not be empty")
+	check(oneOf(c.Tracing.Exporter, "none", "otlp", "stdout"), "tracing.exporter must be one of none, otlp, stdout, got %q", c.Tracing.Exporter)
+	check(c.Reviewers.Count >= 1 && c.Reviewers.Count <= 10, "reviewers.count must be between 1 and 10, got %d", c.Reviewers.Count)
+
+	for i, key := range c.Auth.APIKeys {
+		check(len(key) >= minAPIKeyLength, "auth.api_keys[%d] must be at least %d characters", i, minAPIKeyLength)
+	}
+
+	if c.Webhooks.Enabled {
+		check(c.Webhooks.PollInterval > 0, "webhooks.poll_interval must be positive")
+		check(c.Webhooks.BatchSize >= 1, "webhooks.batch_size must be at least 1")
+		check(c.Webhooks.MaxAttempts >= 1, "webhooks.max_attempts must be at least 1")
+		check(c.Webhooks.InitialBackoff > 0, "webhooks.initial_backoff must be positive")
+		check(c.Webhooks.MaxBackoff >= c.Webhooks.InitialBackoff, "webhooks.max_backoff must not be less than webhooks.initial_backoff")
+		check(c.Webhooks.Timeout > 0, "webhooks.timeout must be positive")
+	}
+
+	if c.Notifications.Enabled {
+		check(c.Notifications.PollInterval > 0, "notifications.poll_interval must be positive")
+		check(c.Notifications.BatchSize >= 1, "notifications.batch_size must be at least 1")
+		check(c.Notifications.MaxAttempts >= 1, "notifications.max_attempts must be at least 1")
+		check(c.Notifications.InitialBackoff > 0, "notifications.initial_backoff must be positive")
+		check(c.Notifications.MaxBackoff >= c.Notifications.InitialBackoff, "notifications.max_backoff must not be less than notifications.initial_backoff")
+		check(c.Notifications.Timeout > 0, "notifications.timeout must be positive")
+		templates := []struct {
+			name, text string
+		}{
+			{"notifications.templates.assigned", c.Notifications.Templates.Assigned},
+			{"notifications.templates.reassigned", c.Notifications.Templates.Reassigned},
+			{"notifications.templates.merged", c.Notifications.Templates.Merged},
+		}
+		for _, t := range templates {
+			if t.text == "" {
+				check(false, "%s must not be empty", t.name)
+				continue
+			}
+			_, err := template.New(t.name).Parse(t.text)
+			check(err == nil, "%s is not a valid template: %v", t.name, err)
+		}
+	}
+
+	location, err := time.LoadLocation(c.Scheduler.Timezone)
+	check(err == nil, "scheduler.timezone %q is not a known time zone", c.Scheduler.Timezone)
+	if location == nil {
+		location = time.UTC
+	}
+	schedules := []struct {
+		name, spec string
+		required   bool
+	}{
+		{"sla.schedule", c.SLA.Schedule, false},
+		{"stale.schedule", c.Stale.Schedule, c.Stale.Enabled},
+		{"scheduler.cleanup_schedule", c.Scheduler.CleanupSchedule, false},
+	}
+	for _, s := range schedules {
+		if s.spec == "" {
+			check(!s.required, "%s must not be empty", s.name)
+			continue
+		}
+		_, err := scheduler.Parse(s.spec, location)
+		check(err == nil, "%s is not a valid cron expression: %v", s.name, err)
+	}
+	check(c.Scheduler.HistoryRetention >= 24*time.Hour, "scheduler.history_retention must be at least 24h, got %s", c.Scheduler.HistoryRetention)
+
+	if c.Events.StreamEnabled {
+		check(c.Events.PollInterval > 0, "events.poll_interval must be positive")
+		check(c.Events.HeartbeatInterval > 0, "events.heartbeat_interval must be positive")
+	}
+
+	check(c.SLA.Default >= time.Minute, "sla.default must be at least 1m, got %s", c.SLA.Default)
+
+	check(c.Stale.MarkAfter >= time.Hour, "stale.mark_after must be at least 1h, got %s", c.Stale.MarkAfter)
+	check(c.Stale.CloseAfter == 0 || c.Stale.CloseAfter > c.Stale.MarkAfter,
+		"stale.close_after must be 0 or greater than stale.mark_after (%s), got %s", c.Stale.MarkAfter, c.Stale.CloseAfter)
+
+	if c.Digest.Enabled {
+		_, err := time.Parse("15:04", c.Digest.SendAt)
+		check(err == nil, "digest.send_at must be in HH:MM format, got %q", c.Digest.SendAt)
+		_, err = time.LoadLocation(c.Digest.Timezone)
+		check(err == nil, "digest.timezone %q is not a known time zone", c.Digest.Timezone)
+		digestTemplates := []struct {
+			name, text string
+		}{
+			{"digest.subject", c.Digest.Subject},
+			{"digest.body", c.Digest.Body},
+		}
+		for _, t := range digestTemplates {
+			_, err := template.New(t.name).Parse(t.text)
+			check(t.text != "", "%s must not be empty", t.name)
+			check(err == nil, "%s is not a valid template: %v", t.name, err)
+		}
+		_, _, err = net.SplitHostPort(c.Digest.SMTP.Addr)
+		check(err == nil, "digest.smtp.addr must be host:port, got %q", c.Digest.SMTP.Addr)
+		_, err = mail.ParseAddress(c.Digest.SMTP.From)
+		check(err == nil, "digest.smtp.from must be a valid email address (SMTP_FROM)")
+		check(c.Digest.SMTP.Timeout > 0, "digest.smtp.timeout must be positive")
+	}
+
+	// Relay нужен и для уведомлений в чат
+	if len(c.Outbox.Sinks) > 0 || c.Notifications.Enabled {
+		check(c.Outbox.PollInterval > 0, "outbox.poll_interval must be positive")
+		check(c.Outbox.BatchSize >= 1, "outbox.batch_size must be at least 1")
+		check(c.Outbox.Lease > 0, "outbox.lease must be positive")
+		check(c.Outbox.MaxAttempts >= 1, "outbox.max_attempts must be at least 1")
+		check(c.Outbox.InitialBackoff > 0, "outbox.initial_backoff must be positive")
+		check(c.Outbox.MaxBackoff >= c.Outbox.InitialBackoff, "outbox.max_backoff must not be less than outbox.initial_backoff")
+	}
+	seenSinks := make(map[string]bool, len(c.Outbox.Sinks))
+	for i, sink := range c.Outbox.Sinks {
+		check(oneOf(sink, "webhook", "stdout", "bus"), "outbox.sinks[%d] must be one of webhook, stdout, bus, got %q", i, sink)
+		check(!seenSinks[sink], "outbox.sinks[%d] duplicates %q", i, sink)
+		seenSinks[sink] = true
+	}
+
+	if secret := c.Integrations.GitHub.WebhookSecret; secret != "" {
+		check(len(secret) >= minAPIKeyLength, "integrations.github.webhook_secret must be at least %d characters", minAPIKeyLength)
+	}
+	if gitlab := c.Integrations.GitLab; gitlab.WebhookToken != "" {
+		check(len(gitlab.WebhookToken) >= minAPIKeyLength, "integrations.gitlab.webhook_token must be at least %d characters", minAPIKeyLength)
+	}
+	if gitlab := c.Integrations.GitLab; gitlab.WriteBack {
+		u, err := url.Parse(gitlab.URL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"integrations.gitlab.url must be an absolute http or https URL when write_back is enabled")
+		check(gitlab.APIToken != "", "integrations.gitlab.api_token must be set when write_back is enabled")
+		check(gitlab.Timeout > 0, "integrations.gitlab.timeout must be positive")
+	}
+
+	return errors.Join(errs...)
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
+
+// Redacted возвращает копию без секретов - для --print-config и логов
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	if u, err := url.Parse(c.Database.URL); err == nil && u.User != nil {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			u.User = url.UserPassword(u.User.Username(), "xxxxx")
+		}
+		redacted.Database.URL = u.String()
+	}
+	redacted.Auth.APIKeys = make([]string, len(c.Auth.APIKeys))
+	for i := range c.Auth.APIKeys {
+		redacted.Auth.APIKeys[i] = "xxxxx"
+	}
+	if c.Integrations.GitHub.WebhookSecret != "" {
+		redacted.Integrations.GitHub.WebhookSecret = "xxxxx"
+	}
+	if c.Digest.SMTP.Password != "" {
+		redacted.Digest.SMTP.Password = "xxxxx"
+	}
+	if c.Integrations.GitLab.WebhookToken != "" {
+		redacted.Integrations.GitLab.WebhookToken = "xxxxx"
+	}
+	if c.Integrations.GitLab.APIToken != "" {
+		redacted.Integrations.GitLab.APIToken = "xxxxx"
+	}
+	return &redacted
+}
+
+// YAML сериализует конфигурацию в формате файла конфигурации
+func (c *Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c)
+}
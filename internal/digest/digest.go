@@ -0,0 +1,168 @@
+// Package digest раз в день отправляет пользователям по email сводку открытых PR, ожидающих их ревью.
+package digest
+
+import (
+	"avito-tech-internship/internal/domain"
+	"avito-tech-internship/internal/metrics"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+	// Часовые пояса нужны и в образе без системной базы tzdata
+	_ "time/tzdata"
+)
+
+// Store реализуется storage.PostgresRepository
+type Store interface {
+	GetDigestRecipients(ctx context.Context) ([]*domain.DigestRecipient, error)
+	GetUserAssignedPRs(ctx context.Context, userID string) ([]domain.PullRequestShort, error)
+	ClaimDigest(ctx context.Context, userID string, day time.Time) (bool, error)
+	ReleaseDigest(ctx context.Context, userID string, day time.Time) error
+}
+
+type Config struct {
+	// Часовой пояс, по которому определяется день сводки
+	Location *time.Location
+	// Шаблоны text/template, поля описаны в Data
+	Subject string
+	Body    string
+}
+
+// Data - данные для шаблонов сводки
+type Data struct {
+	Username string
+	Count    int
+	PRs      []PR
+}
+
+type PR struct {
+	ID        string
+	Name      string
+	AuthorID  string
+	CreatedAt time.Time
+	// Сколько PR открыт, например "3d 4h"
+	Age string
+}
+
+type Job struct {
+	store   Store
+	mailer  Mailer
+	cfg     Config
+	subject *template.Template
+	body    *template.Template
+}
+
+// NewJob разбирает шаблоны; конфигурация проверяет их при старте, поэтому ошибка здесь - ошибка программы
+func NewJob(store Store, mailer Mailer, cfg Config) *Job {
+	return &Job{
+		store:   store,
+		mailer:  mailer,
+		cfg:     cfg,
+		subject: template.Must(template.New("subject").Parse(cfg.Subject)),
+		body:    template.Must(template.New("body").Parse(cfg.Body)),
+	}
+}
+
+// Run отправляет сводки за сегодня, вызывается планировщиком
+func (j *Job) Run(ctx context.Context) error {
+	sent, err := j.SendAll(ctx)
+	if err != nil {
+		return err
+	}
+	slog.Info("email digests sent", "sent", sent)
+	return nil
+}
+
+// SendAll отправляет сводку за сегодня всем подписанным пользователям с открытыми ревью.
+// Пользователи, которым сводка за сегодня уже ушла, пропускаются, поэтому вызов безопасно повторять.
+func (j *Job) SendAll(ctx context.Context) (int, error) {
+	now := time.Now().In(j.cfg.Location)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	recipients, err := j.store.GetDigestRecipients(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, recipient := range recipients {
+		if ctx.Err() != nil {
+			return sent, ctx.Err()
+		}
+		ok, err := j.send(ctx, recipient, day, now)
+		if err != nil {
+			metrics.EmailDigest("failed")
+			slog.Warn("could not send email digest", "user", recipient.UserID, "error", err)
+			continue
+		}
+		if ok {
+			metrics.EmailDigest("sent")
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+func (j *Job) send(ctx context.Context, recipient *domain.DigestRecipient, day, now time.Time) (bool, error) {
+	assigned, err := j.store.GetUserAssignedPRs(ctx, recipient.UserID)
+	if err != nil {
+		return false, err
+	}
+	data := Data{Username: recipient.Username}
+	for _, pr := range assigned {
+		if pr.Status != "OPEN" {
+			continue
+		}
+		data.PRs = append(data.PRs, PR{
+			ID:        pr.ID,
+			Name:      pr.Name,
+			AuthorID:  pr.AuthorID,
+			CreatedAt: pr.CreatedAt,
+			Age:       formatAge(now.Sub(pr.CreatedAt)),
+		})
+	}
+	// Пустые сводки не отправляем
+	if len(data.PRs) == 0 {
+		return false, nil
+	}
+	// Самые старые PR первыми
+	sort.Slice(data.PRs, func(a, b int) bool {
+		return data.PRs[a].CreatedAt.Before(data.PRs[b].CreatedAt)
+	})
+	data.Count = len(data.PRs)
+
+	var subject, body bytes.Buffer
+	if err := j.subject.Execute(&subject, data); err != nil {
+		return false, fmt.Errorf("render subject: %w", err)
+	}
+	if err := j.body.Execute(&body, data); err != nil {
+		return false, fmt.Errorf("render body: %w", err)
+	}
+
+	claimed, err := j.store.ClaimDigest(ctx, recipient.UserID, day)
+	if err != nil || !claimed {
+		return false, err
+	}
+	if err := j.mailer.Send(ctx, recipient.Email, strings.TrimSpace(subject.String()), body.String()); err != nil {
+		if releaseErr := j.store.ReleaseDigest(ctx, recipient.UserID, day); releaseErr != nil {
+			slog.Error("could not release email digest", "user", recipient.UserID, "error", releaseErr)
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func formatAge(age time.Duration) string {
+	switch {
+	case age >= 24*time.Hour:
+		return fmt.Sprintf("%dd %dh", int(age.Hours())/24, int(age.Hours())%24)
+	case age >= time.Hour:
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%dm", int(age.Minutes()))
+	}
+}
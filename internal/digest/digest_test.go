@@ -0,0 +1,255 @@
+package digest
+
+import (
+	"avito-tech-internship/internal/domain"
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smtpMessage - письмо, принятое тестовым SMTP-сервером
+type smtpMessage struct {
+	from    string
+	rcpt    []string
+	subject string
+	body    string
+}
+
+// smtpServer - минимальный SMTP-сервер в процессе: без STARTTLS и аутентификации, письма складываются в память
+type smtpServer struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	messages []smtpMessage
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &smtpServer{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go s.serve(t)
+	return s
+}
+
+func (s *smtpServer) addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *smtpServer) serve(t *testing.T) {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(t, conn)
+	}
+}
+
+func (s *smtpServer) handle(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 localhost ESMTP test")
+	var msg smtpMessage
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			msg = smtpMessage{from: strings.Trim(line[len("MAIL FROM:"):], "<> ")}
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			msg.rcpt = append(msg.rcpt, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(dataLine, "."))
+			}
+			parsed, err := mail.ReadMessage(strings.NewReader(data.String()))
+			if err != nil {
+				t.Errorf("invalid message: %v", err)
+				reply("554 invalid message")
+				continue
+			}
+			body, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+			if err != nil {
+				t.Errorf("invalid quoted-printable body: %v", err)
+			}
+			msg.subject, err = new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+			if err != nil {
+				t.Errorf("invalid subject: %v", err)
+			}
+			// quoted-printable в текстовом режиме переводит строки в CRLF
+			msg.body = strings.ReplaceAll(string(body), "\r\n", "\n")
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
+
+func (s *smtpServer) received() []smtpMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.messages)
+}
+
+// memoryStore хранит получателей, назначенные PR и отметки об отправленных сводках в памяти
+type memoryStore struct {
+	mu         sync.Mutex
+	recipients []*domain.DigestRecipient
+	assigned   map[string][]domain.PullRequestShort
+	claimed    map[string]bool
+}
+
+func (s *memoryStore) GetDigestRecipients(context.Context) ([]*domain.DigestRecipient, error) {
+	return s.recipients, nil
+}
+
+func (s *memoryStore) GetUserAssignedPRs(_ context.Context, userID string) ([]domain.PullRequestShort, error) {
+	return s.assigned[userID], nil
+}
+
+func (s *memoryStore) ClaimDigest(_ context.Context, userID string, day time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := userID + "/" + day.Format(time.DateOnly)
+	if s.claimed[key] {
+		return false, nil
+	}
+	s.claimed[key] = true
+	return true, nil
+}
+
+func (s *memoryStore) ReleaseDigest(_ context.Context, userID string, day time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.claimed, userID+"/"+day.Format(time.DateOnly))
+	return nil
+}
+
+func TestSendAllOverSMTP(t *testing.T) {
+	server := newSMTPServer(t)
+	now := time.Now()
+	store := &memoryStore{
+		recipients: []*domain.DigestRecipient{
+			{UserID: "u1", Username: "Alice", Email: "alice@example.com"},
+			{UserID: "u2", Username: "Bob", Email: "bob@example.com"},
+			// Ревью нет совсем
+			{UserID: "u3", Username: "Carol", Email: "carol@example.com"},
+			// Назначенные PR уже закрыты
+			{UserID: "u4", Username: "Dave", Email: "dave@example.com"},
+		},
+		assigned: map[string][]domain.PullRequestShort{
+			"u1": {
+				{ID: "pr-2", Name: "Newer", AuthorID: "u2", Status: "OPEN", CreatedAt: now.Add(-2 * time.Hour)},
+				{ID: "pr-1", Name: "Older", AuthorID: "u2", Status: "OPEN", CreatedAt: now.Add(-50 * time.Hour)},
+				{ID: "pr-3", Name: "Merged", AuthorID: "u2", Status: "MERGED", CreatedAt: now.Add(-time.Hour)},
+			},
+			"u2": {
+				{ID: "pr-4", Name: "Only", AuthorID: "u1", Status: "OPEN", CreatedAt: now.Add(-30 * time.Minute)},
+			},
+			"u4": {
+				{ID: "pr-5", Name: "Closed", AuthorID: "u1", Status: "CLOSED", CreatedAt: now.Add(-time.Hour)},
+			},
+		},
+		claimed: map[string]bool{},
+	}
+	mailer := NewSMTPMailer(SMTPConfig{Addr: server.addr(), From: "Reviewer Service <noreply@example.com>", Timeout: 5 * time.Second})
+	job := NewJob(store, mailer, Config{
+		Location: time.UTC,
+		Subject:  "{{.Count}} PR ждут ревью",
+		Body:     "Привет, {{.Username}}!\n{{range .PRs}}{{.ID}} {{.Name}} ({{.Age}})\n{{end}}",
+	})
+
+	sent, err := job.SendAll(context.Background())
+	if err != nil {
+		t.Fatalf("SendAll: %v", err)
+	}
+	if sent != 2 {
+		t.Fatalf("sent = %d, want 2", sent)
+	}
+
+	messages := server.received()
+	if len(messages) != 2 {
+		t.Fatalf("messages = %d, want 2", len(messages))
+	}
+	byRecipient := map[string]smtpMessage{}
+	for _, msg := range messages {
+		if len(msg.rcpt) != 1 {
+			t.Fatalf("message to %v, want single recipient", msg.rcpt)
+		}
+		if msg.from != "noreply@example.com" {
+			t.Errorf("MAIL FROM = %q, want noreply@example.com", msg.from)
+		}
+		byRecipient[msg.rcpt[0]] = msg
+	}
+	for _, email := range []string{"carol@example.com", "dave@example.com"} {
+		if _, ok := byRecipient[email]; ok {
+			t.Errorf("digest sent to %s without pending reviews", email)
+		}
+	}
+
+	alice, ok := byRecipient["alice@example.com"]
+	if !ok {
+		t.Fatal("no digest for alice@example.com")
+	}
+	if alice.subject != "2 PR ждут ревью" {
+		t.Errorf("alice subject = %q", alice.subject)
+	}
+	// Только открытые PR Alice, самые старые первыми
+	wantAlice := "Привет, Alice!\npr-1 Older (2d 2h)\npr-2 Newer (2h)\n"
+	if alice.body != wantAlice {
+		t.Errorf("alice body = %q, want %q", alice.body, wantAlice)
+	}
+
+	bob, ok := byRecipient["bob@example.com"]
+	if !ok {
+		t.Fatal("no digest for bob@example.com")
+	}
+	if wantBob := "Привет, Bob!\npr-4 Only (30m)\n"; bob.body != wantBob {
+		t.Errorf("bob body = %q, want %q", bob.body, wantBob)
+	}
+
+	// Повторный запуск в тот же день ничего не отправляет
+	if sent, err = job.SendAll(context.Background()); err != nil || sent != 0 {
+		t.Errorf("second SendAll = %d, %v, want 0", sent, err)
+	}
+	if got := len(server.received()); got != 2 {
+		t.Errorf("messages after second run = %d, want 2", got)
+	}
+}
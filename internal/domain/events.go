@@ -0,0 +1,173 @@
+package domain
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// Типы событий, на которые можно подписаться
+const (
+	EventPRCreated        = "pr.created"
+	EventReviewerAssigned = "reviewer.assigned"
+	EventReviewerReplaced = "reviewer.replaced"
+	EventPRMerged         = "pr.merged"
+	EventPRClosed         = "pr.closed"
+	EventPRReopened       = "pr.reopened"
+	EventPRStale          = "pr.stale"
+	EventUserDeactivated  = "user.deactivated"
+)
+
+var EventTypes = []string{
+	EventPRCreated,
+	EventReviewerAssigned,
+	EventReviewerReplaced,
+	EventPRMerged,
+	EventPRClosed,
+	EventPRReopened,
+	EventPRStale,
+	EventUserDeactivated,
+}
+
+func IsEventType(eventType string) bool {
+	for _, t := range EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Event - конверт события, в таком виде он уходит подписчикам
+type Event struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Data       any       `json:"data"`
+}
+
+func NewEvent(eventType string, data any) *Event {
+	return &Event{
+		ID:         "evt_" + rand.Text(),
+		Type:       eventType,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	}
+}
+
+// Participants возвращает пользователей, к которым относится событие на момент записи: автора и ревьюеров PR,
+// замененного ревьюера, деактивированного пользователя. По ним фильтруется поток событий
+func (e *Event) Participants() []string {
+	var ids []string
+	switch data := e.Data.(type) {
+	case *PullRequest:
+		ids = append([]string{data.AuthorId}, data.AssignedReviewers...)
+	case ReviewerAssignedData:
+		ids = []string{data.AuthorID, data.ReviewerID}
+	case ReviewerReplacedData:
+		ids = []string{data.AuthorID, data.OldReviewerID, data.NewReviewerID}
+	case *StalePR:
+		ids = append([]string{data.AuthorID}, data.AssignedReviewers...)
+	case *User:
+		ids = []string{data.UserId}
+	}
+
+	participants := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id != "" && !slices.Contains(participants, id) {
+			participants = append(participants, id)
+		}
+	}
+	return participants
+}
+
+// Данные reviewer.assigned
+type ReviewerAssignedData struct {
+	PRID       string `json:"pull_request_id"`
+	AuthorID   string `json:"author_id"`
+	ReviewerID string `json:"reviewer_id"`
+}
+
+// Данные reviewer.replaced
+type ReviewerReplacedData struct {
+	PRID          string `json:"pull_request_id"`
+	AuthorID      string `json:"author_id"`
+	OldReviewerID string `json:"old_reviewer_id"`
+	NewReviewerID string `json:"new_reviewer_id"`
+}
+
+// Статусы доставки вебхука
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	// Попытки исчерпаны, доставка попала в dead-letter список
+	DeliveryDead = "dead"
+)
+
+type WebhookSubscription struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Возвращается только при создании
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             string          `db:"id" json:"id"`
+	SubscriptionID string          `db:"subscription_id" json:"subscription_id"`
+	EventID        string          `db:"event_id" json:"event_id"`
+	EventType      string          `db:"event_type" json:"event_type"`
+	Payload        json.RawMessage `db:"payload" json:"payload"`
+	Status         string          `db:"status" json:"status"`
+	Attempts       int             `db:"attempts" json:"attempts"`
+	NextAttemptAt  time.Time       `db:"next_attempt_at" json:"next_attempt_at"`
+	LastError      *string         `db:"last_error" json:"last_error,omitempty"`
+	LastStatusCode *int            `db:"last_status_code" json:"last_status_code,omitempty"`
+	CreatedAt      time.Time       `db:"created_at" json:"created_at"`
+	DeliveredAt    *time.Time      `db:"delivered_at" json:"delivered_at,omitempty"`
+	// Заполняются при выборке на отправку
+	URL    string `db:"url" json:"-"`
+	Secret string `db:"secret" json:"-"`
+	// Журнал попыток, заполняется при просмотре одной доставки
+	AttemptLog []*WebhookAttempt `db:"-" json:"attempt_log,omitempty"`
+}
+
+type WebhookAttempt struct {
+	DeliveryID string    `db:"delivery_id" json:"-"`
+	Attempt    int       `db:"attempt" json:"attempt"`
+	StatusCode *int      `db:"status_code" json:"status_code,omitempty"`
+	Error      *string   `db:"error" json:"error,omitempty"`
+	DurationMs int64     `db:"duration_ms" json:"duration_ms"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+type WebhookDeliveryFilter struct {
+	SubscriptionID string
+	Status         string
+	Limit          int
+}
+
+type CreateWebhookRequest struct {
+	URL string `json:"url" binding:"required"`
+	// Если не задан, генерируется сервисом
+	Secret string   `json:"secret"`
+	Events []string `json:"events" binding:"required"`
+}
+
+type WebhookIDRequest struct {
+	ID string `json:"id" binding:"required"`
+}
+
+// OutboxEvent - событие из outbox. ID задает порядок публикации.
+type OutboxEvent struct {
+	ID          int64           `db:"id" json:"-"`
+	EventID     string          `db:"event_id" json:"-"`
+	EventType   string          `db:"event_type" json:"-"`
+	AggregateID string          `db:"aggregate_id" json:"-"`
+	Payload     json.RawMessage `db:"payload" json:"-"`
+	Attempts    int             `db:"attempts" json:"-"`
+	CreatedAt   time.Time       `db:"created_at" json:"-"`
+}
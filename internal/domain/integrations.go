@@ -0,0 +1,74 @@
+package domain
+
+import "time"
+
+// Внешние системы, из которых принимаются вебхуки
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+)
+
+var Providers = []string{ProviderGitHub, ProviderGitLab}
+
+func IsProvider(provider string) bool {
+	for _, p := range Providers {
+		if p == provider {
+			return true
+		}
+	}
+	return false
+}
+
+// Действия над PR во внешней системе, к которым сводятся события конкретного провайдера
+const (
+	ForgeOpened   = "opened"
+	ForgeMerged   = "merged"
+	ForgeClosed   = "closed"
+	ForgeReopened = "reopened"
+)
+
+// ForgePREvent - событие PR из внешней системы
+type ForgePREvent struct {
+	Action string
+	// Идентификатор PR в сервисе, например "org/repo#42" или "group/project!42"
+	PRID        string
+	Name        string
+	AuthorLogin string
+	Tags        []string
+	// Измененные файлы, если провайдер их передает
+	ChangedFiles []string
+}
+
+// Результат обработки входящего вебхука
+const (
+	IntegrationCreated  = "created"
+	IntegrationMerged   = "merged"
+	IntegrationClosed   = "closed"
+	IntegrationReopened = "reopened"
+	IntegrationIgnored  = "ignored"
+)
+
+type IntegrationDelivery struct {
+	Provider   string    `db:"provider" json:"provider"`
+	DeliveryID string    `db:"delivery_id" json:"delivery_id"`
+	Event      string    `db:"event" json:"event"`
+	Action     string    `db:"action" json:"action"`
+	PRID       *string   `db:"pull_request_id" json:"pull_request_id,omitempty"`
+	Result     string    `db:"result" json:"result"`
+	ReceivedAt time.Time `db:"received_at" json:"received_at"`
+	// Доставка уже была обработана раньше
+	Duplicate bool `db:"-" json:"duplicate,omitempty"`
+}
+
+type ExternalUserMapping struct {
+	Provider      string    `db:"provider" json:"provider"`
+	ExternalLogin string    `db:"external_login" json:"external_login"`
+	UserID        string    `db:"user_id" json:"user_id"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+}
+
+type ExternalUserMappingRequest struct {
+	Provider      string `json:"provider" binding:"required"`
+	ExternalLogin string `json:"external_login" binding:"required"`
+	UserID        string `json:"user_id"`
+}
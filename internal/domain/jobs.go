@@ -0,0 +1,47 @@
+package domain
+
+import "time"
+
+// Статусы запуска фонового задания
+const (
+	JobRunRunning   = "running"
+	JobRunSucceeded = "succeeded"
+	JobRunFailed    = "failed"
+	// Реплика остановилась, не записав результат
+	JobRunAbandoned = "abandoned"
+)
+
+// Чем вызван запуск задания
+const (
+	JobTriggerSchedule = "schedule"
+	JobTriggerManual   = "manual"
+)
+
+// JobRun - запись истории запусков задания
+type JobRun struct {
+	ID      int64  `db:"id" json:"id"`
+	JobName string `db:"job_name" json:"job"`
+	Trigger string `db:"trigger" json:"trigger"`
+	// Момент расписания, nil для ручного запуска
+	ScheduledFor *time.Time `db:"scheduled_for" json:"scheduled_for,omitempty"`
+	Status       string     `db:"status" json:"status"`
+	Error        *string    `db:"error" json:"error,omitempty"`
+	// Реплика, выполнившая запуск
+	Instance   string     `db:"instance" json:"instance"`
+	StartedAt  time.Time  `db:"started_at" json:"started_at"`
+	FinishedAt *time.Time `db:"finished_at" json:"finished_at,omitempty"`
+	DurationMs *int64     `db:"duration_ms" json:"duration_ms,omitempty"`
+}
+
+// ScheduledJob - задание планировщика с ближайшим и последним запуском
+type ScheduledJob struct {
+	Name      string    `json:"name"`
+	Schedule  string    `json:"schedule"`
+	NextRunAt time.Time `json:"next_run_at"`
+	// Последний запуск на любой реплике
+	LastRun *JobRun `json:"last_run,omitempty"`
+}
+
+type TriggerJobRequest struct {
+	Name string `json:"name" binding:"required"`
+}
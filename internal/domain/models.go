@@ -8,11 +8,86 @@ type User struct {
 	IsActive bool   `db:"is_active" json:"isActive"`
 	TeamName string `db:"team_name" json:"teamName,omitempty"`
 	TeamId   string `db:"team_id" json:"-"`
+	// Максимум одновременно открытых ревью, nil - без ограничений
+	MaxOpenReviews *int `db:"max_open_reviews" json:"max_open_reviews,omitempty"`
+	// Все команды пользователя, включая основную (TeamName)
+	Teams []*TeamMembership `db:"-" json:"teams,omitempty"`
+	// Навыки пользователя (теги вида "db", "frontend", "payments")
+	Skills []string `db:"-" json:"skills,omitempty"`
+}
+
+type TeamMembership struct {
+	TeamName string `db:"team_name" json:"team_name"`
+	// Доля ревью, которую пользователь готов брать в этой команде (0..1], nil - без ограничений
+	ReviewShare *float64 `db:"review_share" json:"review_share,omitempty"`
+}
+
+// Кандидат в ревьюеры с весом для случайного выбора
+type ReviewerCandidate struct {
+	User
+	ReviewShare float64 `db:"review_share"`
+	// Количество совпавших с PR навыков
+	SkillMatches int `db:"-"`
+	// Сколько из последних PR автора кандидат уже ревьюил
+	RecentPairings int `db:"-"`
+	// Количество открытых PR, где кандидат уже ревьюер
+	OpenReviews int `db:"open_reviews"`
+}
+
+// Загрузка ревьюера, упершегося в лимит открытых ревью
+type ReviewerLoad struct {
+	UserID         string `json:"user_id"`
+	OpenReviews    int    `json:"open_reviews"`
+	MaxOpenReviews int    `json:"max_open_reviews"`
+}
+
+// Все кандидаты в ревьюеры достигли лимита открытых ревью
+type CapacityError struct {
+	Users []*ReviewerLoad
+}
+
+func (e *CapacityError) Error() string {
+	return "CAPACITY_EXHAUSTED"
 }
 
 type Team struct {
-	TeamName string  `json:"team_name,omitempty"`
-	Members  []*User `json:"members"`
+	TeamName       string  `json:"team_name,omitempty"`
+	ParentTeamName string  `json:"parent_team_name,omitempty"`
+	Members        []*User `json:"members"`
+}
+
+// Связь команды с родительской (для построения дерева)
+type TeamLink struct {
+	TeamName       string  `db:"team_name"`
+	ParentTeamName *string `db:"parent_team_name"`
+}
+
+type TeamNode struct {
+	TeamName string      `json:"team_name"`
+	Children []*TeamNode `json:"children"`
+}
+
+// Режимы обработки пользователей, которые уже состоят в другой команде
+const (
+	TeamConflictFail = "fail"
+	TeamConflictMove = "move"
+	TeamConflictSkip = "skip"
+	TeamConflictJoin = "join"
+)
+
+// Итог обработки участника при создании команды
+const (
+	MemberCreated  = "created"
+	MemberMoved    = "moved"
+	MemberSkipped  = "skipped"
+	MemberJoined   = "joined"
+	MemberConflict = "conflict"
+)
+
+type TeamMemberResult struct {
+	UserID   string `json:"user_id"`
+	Result   string `json:"result"`
+	FromTeam string `json:"from_team,omitempty"`
 }
 
 type PullRequest struct {
@@ -23,20 +98,73 @@ type PullRequest struct {
 	AssignedReviewers []string   `db:"-" json:"assigned_reviewers"`
 	CreatedAt         time.Time  `db:"created_at" json:"created_at"`
 	MergedAt          *time.Time `db:"merged_at" json:"merged_at,omitempty"`
+	ClosedAt          *time.Time `db:"closed_at" json:"closed_at,omitempty"`
+	// Когда PR помечен устаревшим, сбрасывается при активности
+	StaleAt     *time.Time `db:"stale_at" json:"stale_at,omitempty"`
+	StaleExempt bool       `db:"stale_exempt" json:"stale_exempt,omitempty"`
+	// Владельцы кода из CODEOWNERS, которых не удалось назначить (email, неизвестная команда).
+	// Заполняется только в ответе на создание PR
+	UnresolvedOwners []string `db:"-" json:"unresolved_owners,omitempty"`
+	// Внешняя система, из вебхука которой создан PR, записывается при создании
+	Provider string `db:"-" json:"-"`
 }
 
 type PullRequestShort struct {
-	ID       string `db:"id" json:"pull_request_id"`
-	Name     string `db:"name" json:"pull_request_name"`
-	AuthorID string `db:"author_id" json:"author_id"`
-	Status   string `db:"status" json:"status"`
+	ID        string    `db:"id" json:"pull_request_id"`
+	Name      string    `db:"name" json:"pull_request_name"`
+	AuthorID  string    `db:"author_id" json:"author_id"`
+	Status    string    `db:"status" json:"status"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
 }
 
 // Request/Response структуры
+type CreateTeamRequest struct {
+	TeamName       string  `json:"team_name"`
+	ParentTeamName string  `json:"parent_team_name,omitempty"`
+	Members        []*User `json:"members"`
+	Mode           string  `json:"mode,omitempty"`
+}
+
+type TeamCodeownersRequest struct {
+	TeamName string `json:"team_name" binding:"required"`
+	Content  string `json:"content"`
+}
+
+type SetPairingDepthRequest struct {
+	TeamName string `json:"team_name" binding:"required"`
+	Depth    int    `json:"depth"`
+}
+
+type SetTeamParentRequest struct {
+	TeamName       string `json:"team_name" binding:"required"`
+	ParentTeamName string `json:"parent_team_name"`
+}
+
+type TeamMemberRequest struct {
+	TeamName    string   `json:"team_name" binding:"required"`
+	UserID      string   `json:"user_id" binding:"required"`
+	ReviewShare *float64 `json:"review_share,omitempty"`
+}
+
 type CreatePRRequest struct {
 	PRID     string `json:"pull_request_id" binding:"required"`
 	Name     string `json:"pull_request_name" binding:"required"`
 	AuthorID string `json:"author_id" binding:"required"`
+	// Измененные файлы и явные теги используются для подбора ревьюеров по навыкам
+	ChangedFiles []string `json:"changed_files,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	// Внешняя система, из вебхука которой создается PR; через API не задается
+	Provider string `json:"-"`
+}
+
+type UserSkillsRequest struct {
+	UserID string   `json:"user_id" binding:"required"`
+	Skills []string `json:"skills" binding:"required"`
+}
+
+type SetMaxOpenReviewsRequest struct {
+	UserID         string `json:"user_id" binding:"required"`
+	MaxOpenReviews *int   `json:"max_open_reviews"`
 }
 
 type MergePRRequest struct {
@@ -77,6 +205,25 @@ type TeamStats struct {
 	MergedPRCount   int    `db:"merged_pr_count" json:"merged_pr_count"`
 }
 
+// Сколько раз reviewer ревьюил PR автора
+type PairingStats struct {
+	AuthorID   string `db:"author_id" json:"author_id"`
+	ReviewerID string `db:"reviewer_id" json:"reviewer_id"`
+	Count      int    `db:"count" json:"count"`
+}
+
+type PairingMatrix struct {
+	TeamName string                    `json:"team_name"`
+	Pairings []*PairingStats           `json:"pairings"`
+	Matrix   map[string]map[string]int `json:"matrix"`
+}
+
+type TeamLoadStats struct {
+	TeamName        string `db:"team_name"`
+	OpenPRCount     int    `db:"open_pr_count"`
+	OpenReviewCount int    `db:"open_review_count"`
+}
+
 type StatsResponse struct {
 	UserStats []*UserStats  `json:"user_stats"`
 	PRStats   []*PRStats    `json:"pr_stats"`
@@ -90,6 +237,7 @@ type StatsSummary struct {
 	TotalPRs        int `json:"total_prs"`
 	OpenPRs         int `json:"open_prs"`
 	MergedPRs       int `json:"merged_prs"`
+	ClosedPRs       int `json:"closed_prs"`
 	TotalReviews    int `json:"total_reviews"`
 	AvgReviewsPerPR int `json:"avg_reviews_per_pr"`
 }
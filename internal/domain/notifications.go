@@ -0,0 +1,59 @@
+package domain
+
+import "time"
+
+// Канал команды для уведомлений в чат
+type TeamNotificationChannel struct {
+	TeamName   string    `db:"team_name" json:"team_name"`
+	WebhookURL string    `db:"webhook_url" json:"webhook_url"`
+	Channel    string    `db:"channel" json:"channel,omitempty"`
+	UpdatedAt  time.Time `db:"updated_at" json:"updated_at"`
+}
+
+type SetTeamChannelRequest struct {
+	TeamName string `json:"team_name" binding:"required"`
+	// Пустой URL удаляет канал команды
+	WebhookURL string `json:"webhook_url"`
+	Channel    string `json:"channel"`
+}
+
+type SetUserNotificationsRequest struct {
+	UserID  string `json:"user_id" binding:"required"`
+	Enabled *bool  `json:"enabled" binding:"required"`
+}
+
+// NotificationRecipient - пользователь и канал, в который ему отправляются уведомления.
+// Канал берется у основной команды, если у нее он не задан - у первой по имени команды пользователя
+type NotificationRecipient struct {
+	UserID     string  `db:"user_id"`
+	Username   string  `db:"username"`
+	Enabled    bool    `db:"chat_notifications"`
+	WebhookURL *string `db:"webhook_url"`
+	Channel    *string `db:"channel"`
+}
+
+// ChatDelivery - сообщение в один канал чата по одному событию. Статусы те же, что у доставки вебхука
+type ChatDelivery struct {
+	ID         int64  `db:"id"`
+	EventID    string `db:"event_id"`
+	EventType  string `db:"event_type"`
+	WebhookURL string `db:"webhook_url"`
+	Channel    string `db:"channel"`
+	Text       string `db:"text"`
+	Attempts   int    `db:"attempts"`
+}
+
+type SetUserEmailRequest struct {
+	UserID string `json:"user_id" binding:"required"`
+	// Пустой адрес отключает сводку
+	Email string `json:"email"`
+	// Получать ежедневную сводку, по умолчанию true
+	Digest *bool `json:"digest"`
+}
+
+// DigestRecipient - пользователь, которому отправляется ежедневная сводка
+type DigestRecipient struct {
+	UserID   string `db:"id"`
+	Username string `db:"username"`
+	Email    string `db:"email"`
+}
@@ -0,0 +1,63 @@
+package domain
+
+import "time"
+
+// Действия сканера SLA над нарушением
+const (
+	SLABreachFlagged    = "flagged"
+	SLABreachReassigned = "reassigned"
+)
+
+type TeamReviewSLA struct {
+	TeamName string `json:"team_name"`
+	// SLA команды, например "24h"; пустое - значение по умолчанию из конфигурации
+	SLA          string `json:"sla,omitempty"`
+	Effective    string `json:"effective_sla"`
+	AutoReassign bool   `json:"auto_reassign"`
+}
+
+type SetTeamReviewSLARequest struct {
+	TeamName     string `json:"team_name" binding:"required"`
+	SLA          string `json:"sla"`
+	AutoReassign bool   `json:"auto_reassign"`
+}
+
+type RespondReviewRequest struct {
+	PRID   string `json:"pull_request_id" binding:"required"`
+	UserID string `json:"user_id" binding:"required"`
+}
+
+// OverdueReview - назначение ревьюера, по которому истек SLA команды автора PR
+type OverdueReview struct {
+	PRID         string    `db:"pull_request_id" json:"pull_request_id"`
+	PRName       string    `db:"pull_request_name" json:"pull_request_name"`
+	AuthorID     string    `db:"author_id" json:"author_id"`
+	ReviewerID   string    `db:"user_id" json:"reviewer_id"`
+	TeamID       string    `db:"team_id" json:"-"`
+	TeamName     string    `db:"team_name" json:"team_name"`
+	AssignedAt   time.Time `db:"assigned_at" json:"assigned_at"`
+	SLASeconds   int64     `db:"sla_seconds" json:"-"`
+	AutoReassign bool      `db:"sla_auto_reassign" json:"-"`
+	SLA          string    `db:"-" json:"sla"`
+	DueAt        time.Time `db:"-" json:"due_at"`
+	OverdueBy    string    `db:"-" json:"overdue_by"`
+}
+
+type SLAStats struct {
+	Since time.Time `json:"since"`
+	// Ревью, просроченные прямо сейчас
+	CurrentlyOverdue int                 `json:"currently_overdue"`
+	Teams            []*TeamSLAStats     `json:"teams"`
+	Reviewers        []*ReviewerSLAStats `json:"reviewers"`
+}
+
+type TeamSLAStats struct {
+	TeamName   string `db:"team_name" json:"team_name"`
+	Breaches   int    `db:"breaches" json:"breaches"`
+	Reassigned int    `db:"reassigned" json:"reassigned"`
+}
+
+type ReviewerSLAStats struct {
+	UserID   string `db:"user_id" json:"user_id"`
+	Breaches int    `db:"breaches" json:"breaches"`
+}
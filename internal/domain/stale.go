@@ -0,0 +1,38 @@
+package domain
+
+import "time"
+
+// Действия политики устаревания PR
+const (
+	StaleActionMarked = "marked_stale"
+	StaleActionClosed = "closed"
+)
+
+// StalePR - открытый PR без активности, попавший под политику устаревания
+type StalePR struct {
+	PRID           string     `db:"pull_request_id" json:"pull_request_id"`
+	PRName         string     `db:"pull_request_name" json:"pull_request_name"`
+	AuthorID       string     `db:"author_id" json:"author_id"`
+	LastActivityAt time.Time  `db:"last_activity_at" json:"last_activity_at"`
+	StaleAt        *time.Time `db:"stale_at" json:"stale_at,omitempty"`
+	// Ревьюеры PR, заполняются в событии pr.stale
+	AssignedReviewers []string `db:"-" json:"assigned_reviewers,omitempty"`
+	// Например "408h0m0s", заполняется сервисом
+	InactiveFor string `db:"-" json:"inactive_for"`
+}
+
+// StaleReport - что политика сделает при следующем запуске
+type StaleReport struct {
+	MarkAfter string `json:"mark_after"`
+	// Пустое - автозакрытие выключено
+	CloseAfter string     `json:"close_after,omitempty"`
+	WouldMark  []*StalePR `json:"would_mark"`
+	WouldClose []*StalePR `json:"would_close"`
+}
+
+type SetStaleExemptRequest struct {
+	PRID string `json:"pull_request_id" binding:"required"`
+	// Исключить PR может только его автор
+	UserID string `json:"user_id" binding:"required"`
+	Exempt bool   `json:"exempt"`
+}
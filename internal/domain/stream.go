@@ -0,0 +1,43 @@
+package domain
+
+import "encoding/json"
+
+// StreamFilter - фильтр потока событий /events/stream, пустые поля не ограничивают
+type StreamFilter struct {
+	// События PR, где автор или ревьюер состоит в команде
+	TeamName string
+	// События PR, где пользователь автор или ревьюер, и события о самом пользователе
+	UserID string
+	Types  []string
+}
+
+// StreamEvent - событие из outbox для потока. ID служит id события SSE и Last-Event-ID
+type StreamEvent struct {
+	ID        int64           `db:"id"`
+	EventType string          `db:"event_type"`
+	Payload   json.RawMessage `db:"payload"`
+	// Событие проходит фильтр
+	Matches bool `db:"matches"`
+	// Событие записано достаточно давно, чтобы не ждать транзакций с меньшим id
+	Settled bool `db:"settled"`
+}
+
+// EventStreamStart - с какого места журнала начинается поток
+type EventStreamStart struct {
+	Cursor int64
+	// Событий после Last-Event-ID клиента уже нет в журнале (удалены очисткой) или id не из этого журнала:
+	// поток начинается с новых событий, а клиент должен заново прочитать состояние через API
+	Reset bool
+}
+
+// EventLogPage - результат чтения журнала событий после курсора
+type EventLogPage struct {
+	// События, прошедшие фильтр
+	Events []*StreamEvent
+	// id, до которого журнал прочитан, включая не прошедшие фильтр события
+	Cursor int64
+	// Прочитана полная пачка, можно читать дальше сразу
+	More bool
+	// Перед следующим событием пропуск в id: транзакция с меньшим id еще может зафиксироваться
+	Pending bool
+}
@@ -0,0 +1,89 @@
+// Package eventstream будит подписчиков потока событий, когда в outbox появляются новые записи.
+// Один Watcher на процесс опрашивает outbox, поэтому открытые потоки не нагружают базу, пока событий нет.
+package eventstream
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Store реализуется storage.PostgresRepository
+type Store interface {
+	GetLatestOutboxID(ctx context.Context) (int64, error)
+}
+
+type Watcher struct {
+	store    Store
+	interval time.Duration
+
+	mu     sync.Mutex
+	nextID int
+	subs   map[int]chan struct{}
+}
+
+func NewWatcher(store Store, interval time.Duration) *Watcher {
+	return &Watcher{store: store, interval: interval, subs: make(map[int]chan struct{})}
+}
+
+// Subscribe возвращает канал, в который приходит сигнал о новых событиях, и функцию отписки.
+// Сигналы не копятся: пропущенный сигнал означает, что подписчик еще не дочитал предыдущие события
+func (w *Watcher) Subscribe() (<-chan struct{}, func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	id := w.nextID
+	w.nextID++
+	ch := make(chan struct{}, 1)
+	w.subs[id] = ch
+	return ch, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		delete(w.subs, id)
+	}
+}
+
+// Wake будит всех подписчиков, например по событию из внутрипроцессной шины
+func (w *Watcher) Wake() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, ch := range w.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run опрашивает outbox раз в interval и будит подписчиков, когда появляется новое событие
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	var latest int64
+	for {
+		// Без подписчиков базу не опрашиваем: новый подписчик сам читает журнал при подключении
+		if w.subscribers() > 0 {
+			id, err := w.store.GetLatestOutboxID(ctx)
+			if err != nil && ctx.Err() == nil {
+				slog.Warn("could not check for new events", "error", err)
+			}
+			if err == nil && id != latest {
+				latest = id
+				w.Wake()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Watcher) subscribers() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.subs)
+}
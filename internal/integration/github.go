@@ -0,0 +1,96 @@
+// Package integration разбирает вебхуки внешних систем (GitHub, GitLab) и сводит их к событиям PR сервиса.
+package integration
+
+import (
+	"avito-tech-internship/internal/domain"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// VerifyGitHubSignature проверяет заголовок X-Hub-Signature-256: "sha256=" + hex HMAC-SHA256 тела на секрете вебхука
+func VerifyGitHubSignature(secret string, body []byte, header string) bool {
+	signature, ok := strings.CutPrefix(header, "sha256=")
+	if !ok || secret == "" {
+		return false
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// Поля события pull_request, которые использует сервис
+type githubPullRequestPayload struct {
+	Action      string `json:"action"`
+	PullRequest *struct {
+		Number int    `json:"number"`
+		Title  string `json:"title"`
+		Draft  bool   `json:"draft"`
+		Merged bool   `json:"merged"`
+		User   struct {
+			Login string `json:"login"`
+		} `json:"user"`
+		Labels []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+	} `json:"pull_request"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// ParseGitHubEvent разбирает вебхук по заголовку X-GitHub-Event и телу.
+// Возвращает действие из тела и событие PR; событие nil, если вебхук не влияет на PR в сервисе
+// (другой тип события, черновик, правка описания и т.п.)
+func ParseGitHubEvent(eventName string, body []byte) (string, *domain.ForgePREvent, error) {
+	if eventName != "pull_request" {
+		return "", nil, nil
+	}
+
+	var payload githubPullRequestPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "", nil, fmt.Errorf("invalid payload: %w", err)
+	}
+	if payload.PullRequest == nil || payload.Repository.FullName == "" || payload.PullRequest.Number == 0 {
+		return payload.Action, nil, errors.New("invalid payload: pull_request and repository are required")
+	}
+
+	pr := payload.PullRequest
+	event := &domain.ForgePREvent{
+		PRID:        fmt.Sprintf("%s#%d", payload.Repository.FullName, pr.Number),
+		Name:        pr.Title,
+		AuthorLogin: pr.User.Login,
+	}
+	for _, label := range pr.Labels {
+		event.Tags = append(event.Tags, label.Name)
+	}
+
+	switch payload.Action {
+	case "opened":
+		// Черновик попадет в сервис по ready_for_review
+		if pr.Draft {
+			return payload.Action, nil, nil
+		}
+		event.Action = domain.ForgeOpened
+	case "ready_for_review":
+		event.Action = domain.ForgeOpened
+	case "reopened":
+		event.Action = domain.ForgeReopened
+	case "closed":
+		event.Action = domain.ForgeClosed
+		if pr.Merged {
+			event.Action = domain.ForgeMerged
+		}
+	default:
+		return payload.Action, nil, nil
+	}
+	return payload.Action, event, nil
+}
@@ -0,0 +1,125 @@
+package integration
+
+import (
+	"avito-tech-internship/internal/domain"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// githubPayload собирает тело события pull_request
+func githubPayload(action string, draft, merged bool) []byte {
+	replacer := strings.NewReplacer("ACTION", action, "DRAFT", boolString(draft), "MERGED", boolString(merged))
+	return []byte(replacer.Replace(`{
+		"action": "ACTION",
+		"pull_request": {
+			"number": 42,
+			"title": "Add search by skills",
+			"draft": DRAFT,
+			"merged": MERGED,
+			"user": {"login": "octocat"},
+			"labels": [{"name": "backend"}, {"name": "search"}]
+		},
+		"repository": {"full_name": "avito-tech/reviewer-service"}
+	}`))
+}
+
+func boolString(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+func TestParseGitHubEvent(t *testing.T) {
+	tests := []struct {
+		name       string
+		eventName  string
+		body       []byte
+		wantAction string
+		// Пусто - событие не влияет на PR
+		wantForge string
+		wantErr   bool
+	}{
+		{name: "opened", eventName: "pull_request", body: githubPayload("opened", false, false), wantAction: "opened", wantForge: domain.ForgeOpened},
+		{name: "opened draft", eventName: "pull_request", body: githubPayload("opened", true, false), wantAction: "opened"},
+		{name: "ready for review", eventName: "pull_request", body: githubPayload("ready_for_review", false, false), wantAction: "ready_for_review", wantForge: domain.ForgeOpened},
+		{name: "reopened", eventName: "pull_request", body: githubPayload("reopened", false, false), wantAction: "reopened", wantForge: domain.ForgeReopened},
+		{name: "closed without merge", eventName: "pull_request", body: githubPayload("closed", false, false), wantAction: "closed", wantForge: domain.ForgeClosed},
+		{name: "closed merged", eventName: "pull_request", body: githubPayload("closed", false, true), wantAction: "closed", wantForge: domain.ForgeMerged},
+		{name: "synchronize", eventName: "pull_request", body: githubPayload("synchronize", false, false), wantAction: "synchronize"},
+		{name: "edited", eventName: "pull_request", body: githubPayload("edited", false, false), wantAction: "edited"},
+		{name: "other event", eventName: "push", body: []byte(`{"ref": "refs/heads/main"}`)},
+		{name: "invalid json", eventName: "pull_request", body: []byte(`{"action": `), wantErr: true},
+		{name: "without repository", eventName: "pull_request", body: []byte(`{"action": "opened", "pull_request": {"number": 1}}`), wantAction: "opened", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action, event, err := ParseGitHubEvent(tt.eventName, tt.body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if action != tt.wantAction {
+				t.Errorf("action = %q, want %q", action, tt.wantAction)
+			}
+			if tt.wantForge == "" {
+				if event != nil {
+					t.Errorf("event = %+v, want nil", event)
+				}
+				return
+			}
+			if event == nil {
+				t.Fatal("event = nil")
+			}
+			if event.Action != tt.wantForge {
+				t.Errorf("forge action = %q, want %q", event.Action, tt.wantForge)
+			}
+			if event.PRID != "avito-tech/reviewer-service#42" || event.Name != "Add search by skills" || event.AuthorLogin != "octocat" {
+				t.Errorf("event = %+v, want PR fields from payload", event)
+			}
+			if !slices.Equal(event.Tags, []string{"backend", "search"}) {
+				t.Errorf("tags = %v, want labels", event.Tags)
+			}
+		})
+	}
+}
+
+func TestVerifyGitHubSignature(t *testing.T) {
+	const secret = "github-webhook-secret"
+	body := []byte(`{"action":"opened"}`)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	valid := hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name   string
+		secret string
+		body   []byte
+		header string
+		want   bool
+	}{
+		{name: "valid", secret: secret, body: body, header: "sha256=" + valid, want: true},
+		{name: "missing header", secret: secret, body: body, header: ""},
+		{name: "without prefix", secret: secret, body: body, header: valid},
+		{name: "sha1 prefix", secret: secret, body: body, header: "sha1=" + valid},
+		{name: "not hex", secret: secret, body: body, header: "sha256=" + strings.Repeat("z", len(valid))},
+		{name: "truncated", secret: secret, body: body, header: "sha256=" + valid[:len(valid)-2]},
+		{name: "empty signature", secret: secret, body: body, header: "sha256="},
+		{name: "other body", secret: secret, body: []byte(`{"action":"closed"}`), header: "sha256=" + valid},
+		{name: "other secret", secret: "another-secret", body: body, header: "sha256=" + valid},
+		// Без секрета вебхук не принимается, даже если подпись посчитана на пустом ключе
+		{name: "empty secret", secret: "", body: body, header: "sha256=" + valid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyGitHubSignature(tt.secret, tt.body, tt.header); got != tt.want {
+				t.Errorf("VerifyGitHubSignature = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
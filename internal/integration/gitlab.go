@@ -0,0 +1,181 @@
+package integration
+
+import (
+	"avito-tech-internship/internal/domain"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// VerifyGitLabToken сравнивает X-Gitlab-Token с секретным токеном вебхука
+func VerifyGitLabToken(token, header string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(header)) == 1
+}
+
+// Поля Merge Request Hook, которые использует сервис
+type gitlabMergeRequestPayload struct {
+	ObjectKind string `json:"object_kind"`
+	// Пользователь, выполнивший действие. Для open и reopen это автор MR
+	User struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	Project struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+	ObjectAttributes *struct {
+		IID    int    `json:"iid"`
+		Title  string `json:"title"`
+		Action string `json:"action"`
+		Draft  bool   `json:"draft"`
+		// Старое название draft в GitLab до 14.0
+		WorkInProgress bool `json:"work_in_progress"`
+	} `json:"object_attributes"`
+	Labels []struct {
+		Title string `json:"title"`
+	} `json:"labels"`
+}
+
+// ParseGitLabEvent разбирает вебхук по заголовку X-Gitlab-Event и телу.
+// Как и ParseGitHubEvent, возвращает nil вместо события, если вебхук не влияет на PR в сервисе
+func ParseGitLabEvent(eventName string, body []byte) (string, *domain.ForgePREvent, error) {
+	if eventName != "Merge Request Hook" {
+		return "", nil, nil
+	}
+
+	var payload gitlabMergeRequestPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "", nil, fmt.Errorf("invalid payload: %w", err)
+	}
+	attrs := payload.ObjectAttributes
+	if attrs == nil || payload.Project.PathWithNamespace == "" || attrs.IID == 0 {
+		return "", nil, errors.New("invalid payload: object_attributes and project are required")
+	}
+
+	event := &domain.ForgePREvent{
+		PRID:        GitLabPRID(payload.Project.PathWithNamespace, attrs.IID),
+		Name:        attrs.Title,
+		AuthorLogin: payload.User.Username,
+	}
+	for _, label := range payload.Labels {
+		event.Tags = append(event.Tags, label.Title)
+	}
+
+	draft := attrs.Draft || attrs.WorkInProgress
+	switch attrs.Action {
+	// update приходит и при снятии черновика: неизвестный сервису MR создается, остальные игнорируются
+	case "open", "update":
+		if draft {
+			return attrs.Action, nil, nil
+		}
+		event.Action = domain.ForgeOpened
+	case "reopen":
+		event.Action = domain.ForgeReopened
+	case "merge":
+		event.Action = domain.ForgeMerged
+	case "close":
+		event.Action = domain.ForgeClosed
+	default:
+		return attrs.Action, nil, nil
+	}
+	return attrs.Action, event, nil
+}
+
+// GitLabPRID - идентификатор MR в сервисе: "group/project!42"
+func GitLabPRID(project string, iid int) string {
+	return fmt.Sprintf("%s!%d", project, iid)
+}
+
+// ParseGitLabPRID разбирает идентификатор из GitLabPRID
+func ParseGitLabPRID(prID string) (string, int, error) {
+	i := strings.LastIndex(prID, "!")
+	if i <= 0 {
+		return "", 0, fmt.Errorf("not a GitLab merge request id: %q", prID)
+	}
+	iid, err := strconv.Atoi(prID[i+1:])
+	if err != nil || iid <= 0 {
+		return "", 0, fmt.Errorf("not a GitLab merge request id: %q", prID)
+	}
+	return prID[:i], iid, nil
+}
+
+// GitLabClient выставляет ревьюеров MR через REST API GitLab
+type GitLabClient struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// NewGitLabClient создает клиент. baseURL - адрес GitLab, например https://gitlab.example.com;
+// token - токен с правом api
+func NewGitLabClient(baseURL, token string, client *http.Client) *GitLabClient {
+	return &GitLabClient{baseURL: strings.TrimRight(baseURL, "/"), token: token, client: client}
+}
+
+// SetReviewers заменяет ревьюеров MR пользователями GitLab с логинами logins
+func (c *GitLabClient) SetReviewers(ctx context.Context, prID string, logins []string) error {
+	project, iid, err := ParseGitLabPRID(prID)
+	if err != nil {
+		return err
+	}
+
+	reviewerIDs := make([]int, 0, len(logins))
+	for _, login := range logins {
+		var users []struct {
+			ID int `json:"id"`
+		}
+		path := "/api/v4/users?username=" + url.QueryEscape(login)
+		if err := c.do(ctx, http.MethodGet, path, nil, &users); err != nil {
+			return err
+		}
+		if len(users) == 0 {
+			return fmt.Errorf("gitlab user %q not found", login)
+		}
+		reviewerIDs = append(reviewerIDs, users[0].ID)
+	}
+
+	path := fmt.Sprintf("/api/v4/projects/%s/merge_requests/%d", url.PathEscape(project), iid)
+	return c.do(ctx, http.MethodPut, path, map[string]any{"reviewer_ids": reviewerIDs}, nil)
+}
+
+func (c *GitLabClient) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("PRIVATE-TOKEN", c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("gitlab %s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(snippet)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,118 @@
+package integration
+
+import (
+	"avito-tech-internship/internal/domain"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// gitlabPayload собирает тело Merge Request Hook
+func gitlabPayload(action string, draft, workInProgress bool) []byte {
+	replacer := strings.NewReplacer("ACTION", action, "DRAFT", boolString(draft), "WIP", boolString(workInProgress))
+	return []byte(replacer.Replace(`{
+		"object_kind": "merge_request",
+		"user": {"username": "octocat"},
+		"project": {"path_with_namespace": "avito-tech/reviewer-service"},
+		"object_attributes": {
+			"iid": 42,
+			"title": "Add search by skills",
+			"action": "ACTION",
+			"draft": DRAFT,
+			"work_in_progress": WIP
+		},
+		"labels": [{"title": "backend"}, {"title": "search"}]
+	}`))
+}
+
+func TestParseGitLabEvent(t *testing.T) {
+	tests := []struct {
+		name       string
+		eventName  string
+		body       []byte
+		wantAction string
+		// Пусто - событие не влияет на PR
+		wantForge string
+		wantErr   bool
+	}{
+		{name: "open", eventName: "Merge Request Hook", body: gitlabPayload("open", false, false), wantAction: "open", wantForge: domain.ForgeOpened},
+		{name: "open draft", eventName: "Merge Request Hook", body: gitlabPayload("open", true, false), wantAction: "open"},
+		{name: "open work in progress", eventName: "Merge Request Hook", body: gitlabPayload("open", false, true), wantAction: "open"},
+		{name: "update", eventName: "Merge Request Hook", body: gitlabPayload("update", false, false), wantAction: "update", wantForge: domain.ForgeOpened},
+		{name: "update draft", eventName: "Merge Request Hook", body: gitlabPayload("update", true, false), wantAction: "update"},
+		{name: "reopen", eventName: "Merge Request Hook", body: gitlabPayload("reopen", false, false), wantAction: "reopen", wantForge: domain.ForgeReopened},
+		{name: "merge", eventName: "Merge Request Hook", body: gitlabPayload("merge", false, false), wantAction: "merge", wantForge: domain.ForgeMerged},
+		{name: "close", eventName: "Merge Request Hook", body: gitlabPayload("close", false, false), wantAction: "close", wantForge: domain.ForgeClosed},
+		{name: "approved", eventName: "Merge Request Hook", body: gitlabPayload("approved", false, false), wantAction: "approved"},
+		{name: "other event", eventName: "Push Hook", body: []byte(`{"object_kind": "push"}`)},
+		{name: "invalid json", eventName: "Merge Request Hook", body: []byte(`{"object_kind": `), wantErr: true},
+		{name: "without project", eventName: "Merge Request Hook", body: []byte(`{"object_attributes": {"iid": 1, "action": "open"}}`), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action, event, err := ParseGitLabEvent(tt.eventName, tt.body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if action != tt.wantAction {
+				t.Errorf("action = %q, want %q", action, tt.wantAction)
+			}
+			if tt.wantForge == "" {
+				if event != nil {
+					t.Errorf("event = %+v, want nil", event)
+				}
+				return
+			}
+			if event == nil {
+				t.Fatal("event = nil")
+			}
+			if event.Action != tt.wantForge {
+				t.Errorf("forge action = %q, want %q", event.Action, tt.wantForge)
+			}
+			if event.PRID != "avito-tech/reviewer-service!42" || event.Name != "Add search by skills" || event.AuthorLogin != "octocat" {
+				t.Errorf("event = %+v, want MR fields from payload", event)
+			}
+			if !slices.Equal(event.Tags, []string{"backend", "search"}) {
+				t.Errorf("tags = %v, want labels", event.Tags)
+			}
+		})
+	}
+}
+
+func TestVerifyGitLabToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		want   bool
+	}{
+		{name: "valid", token: "gitlab-token", header: "gitlab-token", want: true},
+		{name: "missing header", token: "gitlab-token", header: ""},
+		{name: "other token", token: "gitlab-token", header: "gitlab-tokem"},
+		{name: "prefix", token: "gitlab-token", header: "gitlab"},
+		{name: "different case", token: "gitlab-token", header: "GITLAB-TOKEN"},
+		// Без токена вебхук не принимается, даже с пустым заголовком
+		{name: "empty token", token: "", header: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyGitLabToken(tt.token, tt.header); got != tt.want {
+				t.Errorf("VerifyGitLabToken = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGitLabPRID(t *testing.T) {
+	project, iid, err := ParseGitLabPRID(GitLabPRID("group/sub/project", 42))
+	if err != nil || project != "group/sub/project" || iid != 42 {
+		t.Errorf("ParseGitLabPRID = %q, %d, %v, want group/sub/project, 42", project, iid, err)
+	}
+	for _, prID := range []string{"avito-tech/reviewer-service#42", "!42", "group/project!", "group/project!0", "group/project!x"} {
+		if _, _, err := ParseGitLabPRID(prID); err == nil {
+			t.Errorf("ParseGitLabPRID(%q) returned no error", prID)
+		}
+	}
+}
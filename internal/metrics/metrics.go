@@ -0,0 +1,247 @@
+// Package metrics собирает метрики сервиса в формате Prometheus.
+package metrics
+
+import (
+	"avito-tech-internship/internal/domain"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const namespace = "reviewer_service"
+
+// Сбор gauge-метрик из БД не должен задерживать scrape надолго
+const collectTimeout = 5 * time.Second
+
+// Registry - собственный реестр, чтобы не зависеть от глобального состояния клиента
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, method and status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.With(Registry).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	dbQueryDuration = promauto.With(Registry).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Repository method duration.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"method"})
+
+	prCreated = promauto.With(Registry).NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pull_requests_created_total",
+		Help:      "Created pull requests.",
+	})
+
+	prMerged = promauto.With(Registry).NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pull_requests_merged_total",
+		Help:      "Merged pull requests.",
+	})
+
+	reviewersReassigned = promauto.With(Registry).NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reviewers_reassigned_total",
+		Help:      "Reviewer reassignments.",
+	})
+
+	noCandidate = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "no_candidate_total",
+		Help:      "Cases when no reviewer candidate was found, by operation.",
+	}, []string{"operation"})
+
+	webhookDeliveries = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_delivery_attempts_total",
+		Help:      "Webhook delivery attempts by resulting delivery status.",
+	}, []string{"status"})
+
+	outboxEvents = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "outbox_events_total",
+		Help:      "Outbox publish attempts by result.",
+	}, []string{"result"})
+
+	integrationWriteBacks = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "integration_writeback_total",
+		Help:      "Reviewer assignments written back to external systems by provider and result.",
+	}, []string{"provider", "result"})
+
+	chatNotifications = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "chat_notifications_total",
+		Help:      "Chat notifications by result.",
+	}, []string{"result"})
+
+	emailDigests = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "email_digests_total",
+		Help:      "Email digests by result.",
+	}, []string{"result"})
+
+	slaBreaches = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sla_breaches_total",
+		Help:      "Review SLA breaches detected by the scanner, by action taken.",
+	}, []string{"action"})
+
+	stalePRs = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "stale_prs_total",
+		Help:      "Pull requests marked stale or closed by the stale policy, by action.",
+	}, []string{"action"})
+
+	jobRuns = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "job_runs_total",
+		Help:      "Scheduled job runs executed by this replica, by job and status.",
+	}, []string{"job", "status"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Middleware считает запросы и их длительность по шаблону маршрута gin
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		httpRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+		httpDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler отдает метрики в текстовом формате Prometheus
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveDBQuery используется как defer metrics.ObserveDBQuery("Method", time.Now())
+func ObserveDBQuery(method string, start time.Time) {
+	dbQueryDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+func PRCreated() {
+	prCreated.Inc()
+}
+
+func PRMerged() {
+	prMerged.Inc()
+}
+
+func ReviewerReassigned() {
+	reviewersReassigned.Inc()
+}
+
+func NoCandidate(operation string) {
+	noCandidate.WithLabelValues(operation).Inc()
+}
+
+func WebhookDelivery(status string) {
+	webhookDeliveries.WithLabelValues(status).Inc()
+}
+
+func IntegrationWriteBack(provider, result string) {
+	integrationWriteBacks.WithLabelValues(provider, result).Inc()
+}
+
+func ChatNotification(result string) {
+	chatNotifications.WithLabelValues(result).Inc()
+}
+
+func EmailDigest(result string) {
+	emailDigests.WithLabelValues(result).Inc()
+}
+
+func SLABreach(action string) {
+	slaBreaches.WithLabelValues(action).Inc()
+}
+
+func StalePR(action string) {
+	stalePRs.WithLabelValues(action).Inc()
+}
+
+func JobRun(job, status string) {
+	jobRuns.WithLabelValues(job, status).Inc()
+}
+
+func OutboxEvent(result string) {
+	outboxEvents.WithLabelValues(result).Inc()
+}
+
+// TeamLoadSource - источник данных для gauge-метрик по командам
+type TeamLoadSource interface {
+	GetTeamLoadStats(ctx context.Context) ([]*domain.TeamLoadStats, error)
+}
+
+// RegisterTeamLoad регистрирует gauge открытых PR и открытых ревью по командам,
+// значения читаются из БД в момент сбора метрик
+func RegisterTeamLoad(source TeamLoadSource) {
+	Registry.MustRegister(&teamLoadCollector{source: source})
+}
+
+var (
+	openPRsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "open_pull_requests"),
+		"Open pull requests by author's team.",
+		[]string{"team"}, nil,
+	)
+	openReviewsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "open_reviews"),
+		"Open review assignments by reviewer's team.",
+		[]string{"team"}, nil,
+	)
+)
+
+type teamLoadCollector struct {
+	source TeamLoadSource
+}
+
+func (c *teamLoadCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- openPRsDesc
+	ch <- openReviewsDesc
+}
+
+func (c *teamLoadCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	stats, err := c.source.GetTeamLoadStats(ctx)
+	if err != nil {
+		slog.Error("failed to collect team load metrics", "error", err)
+		return
+	}
+	for _, stat := range stats {
+		ch <- prometheus.MustNewConstMetric(openPRsDesc, prometheus.GaugeValue, float64(stat.OpenPRCount), stat.TeamName)
+		ch <- prometheus.MustNewConstMetric(openReviewsDesc, prometheus.GaugeValue, float64(stat.OpenReviewCount), stat.TeamName)
+	}
+}
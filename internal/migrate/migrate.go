@@ -0,0 +1,243 @@
+// Package migrate применяет встроенные миграции схемы.
+// Версия хранится в schema_migrations в формате golang-migrate, поэтому базы,
+// размеченные контейнером migrate/migrate, продолжают работать без изменений.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// Ключ advisory lock, под которым реплики по очереди применяют миграции
+const lockKey int64 = 0x72657669657731
+
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+// Status - текущее состояние схемы
+type Status struct {
+	// 0 - миграции еще не применялись
+	Version int  `json:"version"`
+	Dirty   bool `json:"dirty"`
+	Latest  int  `json:"latest"`
+	// Миграции, которые еще не применены
+	Pending []string `json:"pending"`
+}
+
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []Migration
+}
+
+// New читает миграции из fsys. Ошибка означает, что набор файлов некорректен.
+func New(db *sqlx.DB, fsys fs.FS) (*Migrator, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", entry.Name(), err)
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has different names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.up = string(content)
+		} else {
+			m.down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Latest - версия последней встроенной миграции
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status читает версию схемы без блокировки
+func (m *Migrator) Status(ctx context.Context) (*Status, error) {
+	version, dirty, err := currentVersion(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+	status := &Status{Version: version, Dirty: dirty, Latest: m.Latest(), Pending: []string{}}
+	for _, migration := range m.migrations {
+		if migration.Version > version {
+			status.Pending = append(status.Pending, fmt.Sprintf("%03d_%s", migration.Version, migration.Name))
+		}
+	}
+	return status, nil
+}
+
+// Up применяет все недостающие миграции, каждую в своей транзакции
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.locked(ctx, func(conn *sqlx.Conn, version int) error {
+		for _, migration := range m.migrations {
+			if migration.Version <= version {
+				continue
+			}
+			if err := apply(ctx, conn, migration.up, migration.Version); err != nil {
+				return fmt.Errorf("migration %d_%s up: %w", migration.Version, migration.Name, err)
+			}
+			slog.Info("migration applied", "version", migration.Version, "name", migration.Name)
+		}
+		return nil
+	})
+}
+
+// Down откатывает steps последних примененных миграций
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.locked(ctx, func(conn *sqlx.Conn, version int) error {
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			migration := m.migrations[i]
+			if migration.Version > version {
+				continue
+			}
+			if migration.down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+			}
+			previous := 0
+			if i > 0 {
+				previous = m.migrations[i-1].Version
+			}
+			if err := apply(ctx, conn, migration.down, previous); err != nil {
+				return fmt.Errorf("migration %d_%s down: %w", migration.Version, migration.Name, err)
+			}
+			slog.Info("migration rolled back", "version", migration.Version, "name", migration.Name)
+			steps--
+		}
+		return nil
+	})
+}
+
+// locked выполняет fn под advisory lock на выделенном соединении, чтобы реплики не применяли миграции одновременно
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sqlx.Conn, version int) error) error {
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		// Блокировка снимается и при закрытии соединения, поэтому ошибку только логируем
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey); err != nil {
+			slog.Warn("could not release migration lock", "error", err)
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations
+		(
+			version BIGINT  NOT NULL PRIMARY KEY,
+			dirty   BOOLEAN NOT NULL
+		)`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	// Версию читаем уже под блокировкой - другая реплика могла успеть применить миграции
+	version, dirty, err := currentVersion(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("schema is dirty at version %d: fix the database manually and reset the dirty flag", version)
+	}
+	return fn(conn, version)
+}
+
+type queryer interface {
+	QueryRowxContext(ctx context.Context, query string, args ...any) *sqlx.Row
+}
+
+func currentVersion(ctx context.Context, q queryer) (int, bool, error) {
+	// До первого запуска таблицы версий еще нет
+	var exists bool
+	if err := q.QueryRowxContext(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists); err != nil {
+		return 0, false, fmt.Errorf("read schema version: %w", err)
+	}
+	if !exists {
+		return 0, false, nil
+	}
+
+	var version int
+	var dirty bool
+	err := q.QueryRowxContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("read schema version: %w", err)
+	}
+	return version, dirty, nil
+}
+
+// apply выполняет SQL миграции и записывает новую версию в одной транзакции:
+// при ошибке схема остается на прежней версии, а не в состоянии dirty
+func apply(ctx context.Context, conn *sqlx.Conn, query string, newVersion int) error {
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations"); err != nil {
+		return err
+	}
+	if newVersion > 0 {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, dirty) VALUES ($1, FALSE)", newVersion); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
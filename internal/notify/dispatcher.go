@@ -0,0 +1,112 @@
+package notify
+
+import (
+	"avito-tech-internship/internal/domain"
+	"avito-tech-internship/internal/metrics"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// DeliveryStore - очередь сообщений в чат, реализуется storage.PostgresRepository
+type DeliveryStore interface {
+	ClaimChatDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*domain.ChatDelivery, error)
+	RecordChatAttempt(ctx context.Context, id int64, attempt int, status string, lastError *string, retryIn time.Duration) error
+}
+
+type DispatcherConfig struct {
+	// Как часто проверять очередь сообщений
+	PollInterval time.Duration
+	BatchSize    int
+	// После MaxAttempts неудачных попыток сообщение больше не отправляется
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Таймаут одного запроса к чату
+	Timeout time.Duration
+}
+
+// Dispatcher отправляет сообщения из очереди с экспоненциальной задержкой между попытками
+type Dispatcher struct {
+	store  DeliveryStore
+	sender Sender
+	cfg    DispatcherConfig
+}
+
+func NewDispatcher(store DeliveryStore, sender Sender, cfg DispatcherConfig) *Dispatcher {
+	return &Dispatcher{store: store, sender: sender, cfg: cfg}
+}
+
+// Run отправляет сообщения из очереди до отмены ctx
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Пока очередь полная, забираем следующую пачку сразу
+		for d.dispatchBatch(ctx) == d.cfg.BatchSize && ctx.Err() == nil {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Dispatcher) dispatchBatch(ctx context.Context) int {
+	// Аренда с запасом на все запросы пачки, чтобы сообщение не взяла другая реплика
+	lease := d.cfg.Timeout*time.Duration(d.cfg.BatchSize) + d.cfg.PollInterval
+	deliveries, err := d.store.ClaimChatDeliveries(ctx, d.cfg.BatchSize, lease)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("could not claim chat deliveries", "error", err)
+		}
+		return 0
+	}
+	for _, delivery := range deliveries {
+		d.deliver(ctx, delivery)
+	}
+	return len(deliveries)
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery *domain.ChatDelivery) {
+	sendCtx, cancel := context.WithTimeout(ctx, d.cfg.Timeout)
+	err := d.sender.Send(sendCtx, delivery.WebhookURL, Message{Text: delivery.Text, Channel: delivery.Channel})
+	cancel()
+
+	attempt := delivery.Attempts + 1
+	status, retryIn := domain.DeliveryDelivered, time.Duration(0)
+	var lastError *string
+	switch {
+	case err == nil:
+		metrics.ChatNotification("sent")
+	case attempt >= d.cfg.MaxAttempts:
+		message := err.Error()
+		lastError, status = &message, domain.DeliveryDead
+		metrics.ChatNotification("dead")
+		slog.Error("chat notification dropped after max attempts", "delivery", delivery.ID, "event", delivery.EventType, "attempts", attempt, "error", err)
+	default:
+		message := err.Error()
+		lastError, status, retryIn = &message, domain.DeliveryPending, d.backoff(attempt)
+		metrics.ChatNotification("failed")
+		slog.Warn("could not send chat notification", "delivery", delivery.ID, "event", delivery.EventType, "attempt", attempt, "error", err)
+	}
+
+	// Запись результата не должна прерываться остановкой сервера, иначе сообщение уйдет повторно
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := d.store.RecordChatAttempt(recordCtx, delivery.ID, attempt, status, lastError, retryIn); err != nil {
+		slog.Error("could not record chat attempt", "delivery", delivery.ID, "error", err)
+	}
+}
+
+// backoff - задержка перед следующей попыткой: InitialBackoff * 2^(attempt-1), не больше MaxBackoff
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.cfg.InitialBackoff
+	for i := 1; i < attempt && delay < d.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.cfg.MaxBackoff)
+}
@@ -0,0 +1,193 @@
+package notify
+
+import (
+	"avito-tech-internship/internal/domain"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memoryQueue - очередь сообщений в памяти: сообщения в статусе pending считаются готовыми к отправке,
+// задержка между попытками проверяется по retryIn
+type memoryQueue struct {
+	mu       sync.Mutex
+	delivery domain.ChatDelivery
+	status   string
+	attempts []queueAttempt
+}
+
+type queueAttempt struct {
+	attempt   int
+	status    string
+	lastError *string
+	retryIn   time.Duration
+}
+
+func (q *memoryQueue) ClaimChatDeliveries(_ context.Context, limit int, _ time.Duration) ([]*domain.ChatDelivery, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.status != domain.DeliveryPending || limit < 1 {
+		return nil, nil
+	}
+	copied := q.delivery
+	return []*domain.ChatDelivery{&copied}, nil
+}
+
+func (q *memoryQueue) RecordChatAttempt(_ context.Context, id int64, attempt int, status string, lastError *string, retryIn time.Duration) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if id == q.delivery.ID {
+		q.delivery.Attempts = attempt
+		q.status = status
+	}
+	q.attempts = append(q.attempts, queueAttempt{attempt: attempt, status: status, lastError: lastError, retryIn: retryIn})
+	return nil
+}
+
+// slackServer отвечает 500 на первые failures запросов и сохраняет тела запросов
+func slackServer(t *testing.T, failures int32) (*httptest.Server, func() []Message) {
+	t.Helper()
+	var mu sync.Mutex
+	var messages []Message
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg Message
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("decode message: %v", err)
+		}
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("content type = %q", r.Header.Get("Content-Type"))
+		}
+		mu.Lock()
+		messages = append(messages, msg)
+		mu.Unlock()
+		if calls.Add(1) <= failures {
+			http.Error(w, "rollup_error", http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []Message {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(messages)
+	}
+}
+
+func newTestQueue(url string) *memoryQueue {
+	return &memoryQueue{
+		delivery: domain.ChatDelivery{
+			ID:         1,
+			EventID:    "evt_1",
+			EventType:  domain.EventReviewerAssigned,
+			WebhookURL: url,
+			Channel:    "#backend",
+			Text:       "@bob review pr-1",
+		},
+		status: domain.DeliveryPending,
+	}
+}
+
+func testDispatcherConfig() DispatcherConfig {
+	return DispatcherConfig{
+		PollInterval:   time.Second,
+		BatchSize:      10,
+		MaxAttempts:    4,
+		InitialBackoff: 10 * time.Second,
+		MaxBackoff:     25 * time.Second,
+		Timeout:        time.Second,
+	}
+}
+
+func TestDispatcherRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int32
+		want     []queueAttempt
+	}{
+		{
+			name: "sent first time",
+			want: []queueAttempt{{attempt: 1, status: domain.DeliveryDelivered}},
+		},
+		{
+			name:     "sent after retries with backoff",
+			failures: 2,
+			want: []queueAttempt{
+				{attempt: 1, status: domain.DeliveryPending, retryIn: 10 * time.Second},
+				{attempt: 2, status: domain.DeliveryPending, retryIn: 20 * time.Second},
+				{attempt: 3, status: domain.DeliveryDelivered},
+			},
+		},
+		{
+			name:     "dead after max attempts, backoff capped",
+			failures: 100,
+			want: []queueAttempt{
+				{attempt: 1, status: domain.DeliveryPending, retryIn: 10 * time.Second},
+				{attempt: 2, status: domain.DeliveryPending, retryIn: 20 * time.Second},
+				{attempt: 3, status: domain.DeliveryPending, retryIn: 25 * time.Second},
+				{attempt: 4, status: domain.DeliveryDead},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, messages := slackServer(t, tt.failures)
+			queue := newTestQueue(server.URL)
+			dispatcher := NewDispatcher(queue, NewWebhookSender(server.Client()), testDispatcherConfig())
+
+			for i := 0; i < 10 && queue.status == domain.DeliveryPending; i++ {
+				dispatcher.dispatchBatch(context.Background())
+			}
+
+			if len(queue.attempts) != len(tt.want) {
+				t.Fatalf("got %d attempts, want %d", len(queue.attempts), len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := queue.attempts[i]
+				if got.attempt != want.attempt || got.status != want.status || got.retryIn != want.retryIn {
+					t.Errorf("attempt %d = %d %s retry in %s, want %d %s retry in %s",
+						i+1, got.attempt, got.status, got.retryIn, want.attempt, want.status, want.retryIn)
+				}
+				if (got.lastError == nil) != (want.status == domain.DeliveryDelivered) {
+					t.Errorf("attempt %d last error = %v", i+1, got.lastError)
+				}
+			}
+
+			want := Message{Text: "@bob review pr-1", Channel: "#backend"}
+			for i, msg := range messages() {
+				if msg != want {
+					t.Errorf("request %d = %+v, want %+v", i+1, msg, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDispatcherTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	queue := newTestQueue(server.URL)
+	cfg := testDispatcherConfig()
+	cfg.Timeout = 20 * time.Millisecond
+	NewDispatcher(queue, NewWebhookSender(server.Client()), cfg).dispatchBatch(context.Background())
+
+	if len(queue.attempts) != 1 || queue.attempts[0].status != domain.DeliveryPending || queue.attempts[0].lastError == nil {
+		t.Fatalf("attempts = %+v, want one failed attempt", queue.attempts)
+	}
+}
@@ -0,0 +1,165 @@
+// Package notify отправляет уведомления о ревью в чат (incoming webhook Slack или Mattermost).
+// Notifier подключается к outbox relay как sink и только ставит сообщения в очередь,
+// отправляет их Dispatcher с повторами, поэтому чат не задерживает ни запросы к API, ни relay.
+package notify
+
+import (
+	"avito-tech-internship/internal/domain"
+	"avito-tech-internship/internal/metrics"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Store реализуется storage.PostgresRepository
+type Store interface {
+	GetPullRequestByID(ctx context.Context, prID string) (*domain.PullRequest, error)
+	GetNotificationRecipients(ctx context.Context, userIDs []string) ([]*domain.NotificationRecipient, error)
+	EnqueueChatDeliveries(ctx context.Context, deliveries []*domain.ChatDelivery) error
+}
+
+// Templates - шаблоны text/template для каждого вида уведомления, поля описаны в MessageData
+type Templates struct {
+	Assigned   string
+	Reassigned string
+	Merged     string
+}
+
+// MessageData - данные для шаблонов
+type MessageData struct {
+	PRID   string
+	PRName string
+	// Имя автора PR
+	Author string
+	// Упоминания получателей этого канала: "@alice @bob"
+	Mentions string
+	// Имя замененного ревьюера, только для Reassigned
+	OldReviewer string
+}
+
+type Notifier struct {
+	store      Store
+	assigned   *template.Template
+	reassigned *template.Template
+	merged     *template.Template
+}
+
+// NewNotifier разбирает шаблоны; конфигурация проверяет их при старте, поэтому ошибка здесь - ошибка программы
+func NewNotifier(store Store, templates Templates) *Notifier {
+	return &Notifier{
+		store:      store,
+		assigned:   template.Must(template.New("assigned").Parse(templates.Assigned)),
+		reassigned: template.Must(template.New("reassigned").Parse(templates.Reassigned)),
+		merged:     template.Must(template.New("merged").Parse(templates.Merged)),
+	}
+}
+
+func (n *Notifier) Name() string {
+	return "chat"
+}
+
+// Publish реализует outbox.Sink: ставит в очередь по одному сообщению на канал. Сообщение уникально
+// по событию и каналу, поэтому повтор события после ошибки БД не приводит к повторной отправке.
+func (n *Notifier) Publish(ctx context.Context, event *domain.OutboxEvent) error {
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(event.Payload, &envelope); err != nil {
+		return fmt.Errorf("decode event: %w", err)
+	}
+
+	switch event.EventType {
+	case domain.EventReviewerAssigned:
+		var data domain.ReviewerAssignedData
+		if err := json.Unmarshal(envelope.Data, &data); err != nil {
+			return fmt.Errorf("decode event: %w", err)
+		}
+		return n.notify(ctx, event, n.assigned, data.PRID, []string{data.ReviewerID}, "")
+	case domain.EventReviewerReplaced:
+		var data domain.ReviewerReplacedData
+		if err := json.Unmarshal(envelope.Data, &data); err != nil {
+			return fmt.Errorf("decode event: %w", err)
+		}
+		return n.notify(ctx, event, n.reassigned, data.PRID, []string{data.NewReviewerID}, data.OldReviewerID)
+	case domain.EventPRMerged:
+		var pr domain.PullRequest
+		if err := json.Unmarshal(envelope.Data, &pr); err != nil {
+			return fmt.Errorf("decode event: %w", err)
+		}
+		return n.notify(ctx, event, n.merged, pr.ID, pr.AssignedReviewers, "")
+	}
+	return nil
+}
+
+// notify ставит в очередь по одному сообщению в каждый канал получателей, отказавшиеся от уведомлений пропускаются
+func (n *Notifier) notify(ctx context.Context, event *domain.OutboxEvent, tmpl *template.Template, prID string, recipientIDs []string, oldReviewerID string) error {
+	if len(recipientIDs) == 0 {
+		return nil
+	}
+	pr, err := n.store.GetPullRequestByID(ctx, prID)
+	if err != nil {
+		if err.Error() == "pull request not found" {
+			return nil
+		}
+		return err
+	}
+
+	users, err := n.store.GetNotificationRecipients(ctx, append([]string{pr.AuthorId, oldReviewerID}, recipientIDs...))
+	if err != nil {
+		return err
+	}
+	names := make(map[string]string, len(users))
+	byID := make(map[string]*domain.NotificationRecipient, len(users))
+	for _, user := range users {
+		names[user.UserID] = user.Username
+		byID[user.UserID] = user
+	}
+
+	type channelKey struct{ url, channel string }
+	mentions := make(map[channelKey][]string)
+	for _, id := range recipientIDs {
+		user, ok := byID[id]
+		if !ok || !user.Enabled || user.WebhookURL == nil {
+			continue
+		}
+		key := channelKey{url: *user.WebhookURL}
+		if user.Channel != nil {
+			key.channel = *user.Channel
+		}
+		mentions[key] = append(mentions[key], "@"+user.Username)
+	}
+
+	deliveries := make([]*domain.ChatDelivery, 0, len(mentions))
+	for key, users := range mentions {
+		sort.Strings(users)
+		var text bytes.Buffer
+		err := tmpl.Execute(&text, MessageData{
+			PRID:        pr.ID,
+			PRName:      pr.Name,
+			Author:      names[pr.AuthorId],
+			Mentions:    strings.Join(users, " "),
+			OldReviewer: names[oldReviewerID],
+		})
+		if err != nil {
+			metrics.ChatNotification("failed")
+			slog.Error("could not render chat notification", "template", tmpl.Name(), "error", err)
+			continue
+		}
+		deliveries = append(deliveries, &domain.ChatDelivery{
+			EventID:    event.EventID,
+			EventType:  event.EventType,
+			WebhookURL: key.url,
+			Channel:    key.channel,
+			Text:       text.String(),
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return n.store.EnqueueChatDeliveries(ctx, deliveries)
+}
@@ -0,0 +1,219 @@
+package notify
+
+import (
+	"avito-tech-internship/internal/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// memoryStore - PR и получатели в памяти; канал получателя задается так же, как его выбирает
+// GetNotificationRecipients (основная команда или первая по имени)
+type memoryStore struct {
+	prs        map[string]*domain.PullRequest
+	recipients map[string]*domain.NotificationRecipient
+	prErr      error
+	enqueued   []*domain.ChatDelivery
+}
+
+func (s *memoryStore) GetPullRequestByID(_ context.Context, prID string) (*domain.PullRequest, error) {
+	if s.prErr != nil {
+		return nil, s.prErr
+	}
+	pr, ok := s.prs[prID]
+	if !ok {
+		return nil, errors.New("pull request not found")
+	}
+	return pr, nil
+}
+
+func (s *memoryStore) GetNotificationRecipients(_ context.Context, userIDs []string) ([]*domain.NotificationRecipient, error) {
+	var recipients []*domain.NotificationRecipient
+	for _, id := range userIDs {
+		if r, ok := s.recipients[id]; ok {
+			recipients = append(recipients, r)
+		}
+	}
+	return recipients, nil
+}
+
+func (s *memoryStore) EnqueueChatDeliveries(_ context.Context, deliveries []*domain.ChatDelivery) error {
+	s.enqueued = append(s.enqueued, deliveries...)
+	return nil
+}
+
+const (
+	backendHook  = "https://hooks.slack.example/backend"
+	frontendHook = "https://hooks.slack.example/frontend"
+)
+
+func recipient(userID, username string, enabled bool, webhookURL, channel string) *domain.NotificationRecipient {
+	r := &domain.NotificationRecipient{UserID: userID, Username: username, Enabled: enabled}
+	if webhookURL != "" {
+		r.WebhookURL = &webhookURL
+	}
+	if channel != "" {
+		r.Channel = &channel
+	}
+	return r
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		prs: map[string]*domain.PullRequest{
+			"pr-1": {ID: "pr-1", Name: "Add search", AuthorId: "u1", Status: "OPEN"},
+		},
+		recipients: map[string]*domain.NotificationRecipient{
+			"u1": recipient("u1", "alice", true, backendHook, "#backend"),
+			"u2": recipient("u2", "bob", true, backendHook, "#backend"),
+			"u3": recipient("u3", "carol", true, backendHook, "#backend"),
+			"u4": recipient("u4", "dave", true, frontendHook, ""),
+			// Отказался от уведомлений
+			"u5": recipient("u5", "erin", false, backendHook, "#backend"),
+			// Ни у одной команды пользователя нет канала
+			"u6": recipient("u6", "frank", true, "", ""),
+		},
+	}
+}
+
+var testTemplates = Templates{
+	Assigned:   `{{.Mentions}} review {{.PRID}} "{{.PRName}}" by {{.Author}}`,
+	Reassigned: `{{.Mentions}} replaced {{.OldReviewer}} on {{.PRID}}`,
+	Merged:     `{{.PRID}} merged, thanks {{.Mentions}}`,
+}
+
+func outboxEvent(t *testing.T, eventType string, data any) *domain.OutboxEvent {
+	t.Helper()
+	event := domain.NewEvent(eventType, data)
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &domain.OutboxEvent{EventID: event.ID, EventType: eventType, Payload: payload}
+}
+
+// delivered сводит сообщения к "url channel: text" в стабильном порядке
+func delivered(deliveries []*domain.ChatDelivery) []string {
+	var out []string
+	for _, d := range deliveries {
+		out = append(out, d.WebhookURL+" "+d.Channel+": "+d.Text)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestNotifierPublish(t *testing.T) {
+	tests := []struct {
+		name  string
+		event func(t *testing.T) *domain.OutboxEvent
+		want  []string
+	}{
+		{
+			name: "assigned",
+			event: func(t *testing.T) *domain.OutboxEvent {
+				return outboxEvent(t, domain.EventReviewerAssigned, domain.ReviewerAssignedData{PRID: "pr-1", AuthorID: "u1", ReviewerID: "u2"})
+			},
+			want: []string{backendHook + ` #backend: @bob review pr-1 "Add search" by alice`},
+		},
+		{
+			name: "reassigned",
+			event: func(t *testing.T) *domain.OutboxEvent {
+				return outboxEvent(t, domain.EventReviewerReplaced, domain.ReviewerReplacedData{PRID: "pr-1", OldReviewerID: "u2", NewReviewerID: "u3"})
+			},
+			want: []string{backendHook + " #backend: @carol replaced bob on pr-1"},
+		},
+		{
+			name: "merged mentions reviewers per channel",
+			event: func(t *testing.T) *domain.OutboxEvent {
+				pr := domain.PullRequest{ID: "pr-1", AuthorId: "u1", Status: "MERGED", AssignedReviewers: []string{"u3", "u2", "u4"}}
+				return outboxEvent(t, domain.EventPRMerged, pr)
+			},
+			want: []string{
+				backendHook + " #backend: pr-1 merged, thanks @bob @carol",
+				frontendHook + " : pr-1 merged, thanks @dave",
+			},
+		},
+		{
+			name: "opted out reviewer",
+			event: func(t *testing.T) *domain.OutboxEvent {
+				return outboxEvent(t, domain.EventReviewerAssigned, domain.ReviewerAssignedData{PRID: "pr-1", AuthorID: "u1", ReviewerID: "u5"})
+			},
+		},
+		{
+			name: "opted out reviewer among others",
+			event: func(t *testing.T) *domain.OutboxEvent {
+				pr := domain.PullRequest{ID: "pr-1", AuthorId: "u1", Status: "MERGED", AssignedReviewers: []string{"u2", "u5"}}
+				return outboxEvent(t, domain.EventPRMerged, pr)
+			},
+			want: []string{backendHook + " #backend: pr-1 merged, thanks @bob"},
+		},
+		{
+			name: "reviewer without team channel",
+			event: func(t *testing.T) *domain.OutboxEvent {
+				return outboxEvent(t, domain.EventReviewerAssigned, domain.ReviewerAssignedData{PRID: "pr-1", AuthorID: "u1", ReviewerID: "u6"})
+			},
+		},
+		{
+			name: "deleted pull request",
+			event: func(t *testing.T) *domain.OutboxEvent {
+				return outboxEvent(t, domain.EventReviewerAssigned, domain.ReviewerAssignedData{PRID: "pr-2", AuthorID: "u1", ReviewerID: "u2"})
+			},
+		},
+		{
+			name: "event without notification",
+			event: func(t *testing.T) *domain.OutboxEvent {
+				return outboxEvent(t, domain.EventPRCreated, domain.PullRequest{ID: "pr-1", AuthorId: "u1"})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore()
+			event := tt.event(t)
+			if err := NewNotifier(store, testTemplates).Publish(context.Background(), event); err != nil {
+				t.Fatalf("Publish: %v", err)
+			}
+			if got := delivered(store.enqueued); !slices.Equal(got, tt.want) {
+				t.Errorf("deliveries = %q, want %q", got, tt.want)
+			}
+			for _, d := range store.enqueued {
+				if d.EventID != event.EventID || d.EventType != event.EventType {
+					t.Errorf("delivery event = %s %s, want %s %s", d.EventID, d.EventType, event.EventID, event.EventType)
+				}
+			}
+		})
+	}
+}
+
+func TestNotifierStoreError(t *testing.T) {
+	store := newMemoryStore()
+	store.prErr = errors.New("pq: connection refused")
+	event := outboxEvent(t, domain.EventReviewerAssigned, domain.ReviewerAssignedData{PRID: "pr-1", AuthorID: "u1", ReviewerID: "u2"})
+
+	// Ошибка возвращается relay, и событие публикуется повторно
+	if err := NewNotifier(store, testTemplates).Publish(context.Background(), event); err == nil {
+		t.Fatal("Publish returned no error")
+	}
+	if len(store.enqueued) != 0 {
+		t.Errorf("enqueued %d deliveries on store error", len(store.enqueued))
+	}
+}
+
+func TestNotifierTemplateError(t *testing.T) {
+	store := newMemoryStore()
+	templates := testTemplates
+	// Поле есть в шаблоне, но не в MessageData: ошибка выполнения, а не разбора
+	templates.Assigned = `{{.Reviewer}} review {{.PRID}}`
+	event := outboxEvent(t, domain.EventReviewerAssigned, domain.ReviewerAssignedData{PRID: "pr-1", AuthorID: "u1", ReviewerID: "u2"})
+
+	if err := NewNotifier(store, templates).Publish(context.Background(), event); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if len(store.enqueued) != 0 {
+		t.Errorf("enqueued %q with broken template", delivered(store.enqueued))
+	}
+}
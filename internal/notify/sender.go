@@ -0,0 +1,54 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Message - тело incoming webhook, формат общий для Slack и Mattermost
+type Message struct {
+	Text string `json:"text"`
+	// Пустой - канал по умолчанию для webhook
+	Channel string `json:"channel,omitempty"`
+}
+
+// Sender отправляет сообщение на адрес incoming webhook
+type Sender interface {
+	Send(ctx context.Context, webhookURL string, msg Message) error
+}
+
+type WebhookSender struct {
+	client *http.Client
+}
+
+func NewWebhookSender(client *http.Client) *WebhookSender {
+	return &WebhookSender{client: client}
+}
+
+func (s *WebhookSender) Send(ctx context.Context, webhookURL string, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
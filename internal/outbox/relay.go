@@ -0,0 +1,164 @@
+// Package outbox публикует события из таблицы outbox во внешние системы (sinks).
+// События пишутся репозиторием в одной транзакции с изменением, поэтому не теряются при падении
+// процесса; relay доставляет их at-least-once, сохраняя порядок событий одного PR или пользователя.
+package outbox
+
+import (
+	"avito-tech-internship/internal/domain"
+	"avito-tech-internship/internal/metrics"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Sink публикует событие во внешнюю систему. Событие может прийти повторно
+// (например, если relay упал после публикации), поэтому получатели должны дедуплицировать по EventID.
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, event *domain.OutboxEvent) error
+}
+
+// Store реализуется storage.PostgresRepository
+type Store interface {
+	ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]*domain.OutboxEvent, error)
+	MarkOutboxPublished(ctx context.Context, ids []int64) error
+	RecordOutboxFailure(ctx context.Context, id int64, lastError string, dead bool, retryIn time.Duration) error
+	ReleaseOutboxEvents(ctx context.Context, ids []int64) error
+}
+
+type Config struct {
+	PollInterval time.Duration
+	BatchSize    int
+	// На сколько пачка закрепляется за relay: если реплика упадет, события вернутся в очередь после Lease
+	Lease time.Duration
+	// После MaxAttempts неудачных попыток событие больше не публикуется
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+type Relay struct {
+	store Store
+	sinks []Sink
+	cfg   Config
+}
+
+func NewRelay(store Store, cfg Config, sinks ...Sink) *Relay {
+	return &Relay{store: store, sinks: sinks, cfg: cfg}
+}
+
+// Run публикует события до отмены ctx
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Пока пачки полные и публикация идет, очередь не пуста - продолжаем без ожидания.
+		// Если ничего не опубликовано, ждем тика, чтобы не крутиться на недоступном sink
+		for ctx.Err() == nil {
+			claimed, published := r.processBatch(ctx)
+			if claimed < r.cfg.BatchSize || published == 0 {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Событие, которое не удалось опубликовать
+type failure struct {
+	event *domain.OutboxEvent
+	err   error
+}
+
+// processBatch забирает пачку, публикует ее вне транзакции и записывает результат.
+// Если событие не опубликовано, следующие события того же агрегата в пачке не отправляются,
+// а возвращаются в очередь, чтобы не нарушить порядок.
+func (r *Relay) processBatch(ctx context.Context) (claimed, published int) {
+	events, err := r.store.ClaimOutboxEvents(ctx, r.cfg.BatchSize, r.cfg.Lease)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("could not claim outbox events", "error", err)
+		}
+		return 0, 0
+	}
+	if len(events) == 0 {
+		return 0, 0
+	}
+
+	var publishedIDs, released []int64
+	var failures []failure
+	blocked := make(map[string]bool)
+	for _, event := range events {
+		if blocked[event.AggregateID] || ctx.Err() != nil {
+			released = append(released, event.ID)
+			continue
+		}
+		if err := r.publish(ctx, event); err != nil {
+			blocked[event.AggregateID] = true
+			failures = append(failures, failure{event: event, err: err})
+			continue
+		}
+		publishedIDs = append(publishedIDs, event.ID)
+		metrics.OutboxEvent("published")
+	}
+
+	// Результат записывается и при остановке сервера, иначе события будут ждать окончания аренды
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	if len(publishedIDs) > 0 {
+		if err := r.store.MarkOutboxPublished(recordCtx, publishedIDs); err != nil {
+			slog.Error("could not mark outbox events published", "error", err)
+		}
+	}
+	for _, f := range failures {
+		r.recordFailure(recordCtx, f.event, f.err)
+	}
+	if len(released) > 0 {
+		if err := r.store.ReleaseOutboxEvents(recordCtx, released); err != nil {
+			slog.Error("could not release outbox events", "error", err)
+		}
+	}
+	return len(events), len(publishedIDs)
+}
+
+// publish отправляет событие во все sinks по порядку
+func (r *Relay) publish(ctx context.Context, event *domain.OutboxEvent) error {
+	for _, sink := range r.sinks {
+		if err := sink.Publish(ctx, event); err != nil {
+			return fmt.Errorf("%s: %w", sink.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (r *Relay) recordFailure(ctx context.Context, event *domain.OutboxEvent, err error) {
+	attempt := event.Attempts + 1
+	dead := attempt >= r.cfg.MaxAttempts
+	if dead {
+		metrics.OutboxEvent("dead")
+		slog.Error("outbox event moved to dead state", "id", event.ID, "event", event.EventType, "attempts", attempt, "error", err)
+	} else {
+		metrics.OutboxEvent("failed")
+		slog.Warn("could not publish event", "id", event.ID, "event", event.EventType, "attempt", attempt, "error", err)
+	}
+	if err := r.store.RecordOutboxFailure(ctx, event.ID, err.Error(), dead, r.backoff(attempt)); err != nil {
+		slog.Error("could not record outbox failure", "id", event.ID, "error", err)
+	}
+}
+
+// backoff - задержка перед следующей попыткой: InitialBackoff * 2^(attempt-1), не больше MaxBackoff
+func (r *Relay) backoff(attempt int) time.Duration {
+	delay := r.cfg.InitialBackoff
+	for i := 1; i < attempt && delay < r.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, r.cfg.MaxBackoff)
+}
@@ -0,0 +1,320 @@
+package outbox
+
+import (
+	"avito-tech-internship/internal/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryOutbox повторяет семантику таблицы outbox в памяти со своими часами: события агрегата,
+// у которого есть более раннее арендованное или ждущее повтора событие, не выбираются
+type memoryOutbox struct {
+	mu   sync.Mutex
+	now  time.Time
+	rows []*outboxRow
+}
+
+type outboxRow struct {
+	event         domain.OutboxEvent
+	nextAttemptAt time.Time
+	published     bool
+	dead          bool
+	lastError     string
+}
+
+func newMemoryOutbox(events ...[2]string) *memoryOutbox {
+	s := &memoryOutbox{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	for i, e := range events {
+		id := int64(i + 1)
+		eventID := fmt.Sprintf("evt_%d", id)
+		s.rows = append(s.rows, &outboxRow{
+			event: domain.OutboxEvent{
+				ID:          id,
+				EventID:     eventID,
+				EventType:   e[1],
+				AggregateID: e[0],
+				Payload:     json.RawMessage(`{"id":"` + eventID + `"}`),
+			},
+			nextAttemptAt: s.now,
+		})
+	}
+	return s
+}
+
+func (s *memoryOutbox) advance(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = s.now.Add(d)
+}
+
+func (s *memoryOutbox) row(id int64) outboxRow {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.rows[id-1]
+}
+
+func (s *memoryOutbox) pending(r *outboxRow) bool {
+	return !r.published && !r.dead
+}
+
+func (s *memoryOutbox) ClaimOutboxEvents(_ context.Context, limit int, lease time.Duration) ([]*domain.OutboxEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Как и в SQL, условие проверяется по состоянию до аренды, поэтому в пачку попадают
+	// несколько событий одного агрегата
+	var selected []*outboxRow
+	for i, r := range s.rows {
+		if !s.pending(r) || r.nextAttemptAt.After(s.now) {
+			continue
+		}
+		blocked := slices.ContainsFunc(s.rows[:i], func(p *outboxRow) bool {
+			return p.event.AggregateID == r.event.AggregateID && s.pending(p) && p.nextAttemptAt.After(s.now)
+		})
+		if !blocked && len(selected) < limit {
+			selected = append(selected, r)
+		}
+	}
+
+	claimed := []*domain.OutboxEvent{}
+	for _, r := range selected {
+		r.nextAttemptAt = s.now.Add(lease)
+		copied := r.event
+		claimed = append(claimed, &copied)
+	}
+	return claimed, nil
+}
+
+func (s *memoryOutbox) MarkOutboxPublished(_ context.Context, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		s.rows[id-1].published = true
+		s.rows[id-1].lastError = ""
+	}
+	return nil
+}
+
+func (s *memoryOutbox) RecordOutboxFailure(_ context.Context, id int64, lastError string, dead bool, retryIn time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.rows[id-1]
+	r.event.Attempts++
+	r.lastError = lastError
+	r.nextAttemptAt = s.now.Add(retryIn)
+	r.dead = dead
+	return nil
+}
+
+func (s *memoryOutbox) ReleaseOutboxEvents(_ context.Context, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		if r := s.rows[id-1]; !r.published {
+			r.nextAttemptAt = s.now
+		}
+	}
+	return nil
+}
+
+// failingSink отклоняет события, для которых fail возвращает true
+type failingSink struct {
+	fail func(event *domain.OutboxEvent) bool
+}
+
+func (s *failingSink) Name() string {
+	return "failing"
+}
+
+func (s *failingSink) Publish(_ context.Context, event *domain.OutboxEvent) error {
+	if s.fail(event) {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+// subscribe собирает id событий, пришедших в шину
+func subscribe(t *testing.T, bus *MemoryBus) func() []string {
+	t.Helper()
+	var mu sync.Mutex
+	var received []string
+	unsubscribe := bus.Subscribe("reviewer.>", func(_ string, data []byte) {
+		var payload struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(data, &payload); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, payload.ID)
+	})
+	t.Cleanup(unsubscribe)
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(received)
+	}
+}
+
+func testRelayConfig() Config {
+	return Config{
+		PollInterval:   time.Second,
+		BatchSize:      10,
+		Lease:          time.Minute,
+		MaxAttempts:    3,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+	}
+}
+
+func TestRelayPublishesAggregateInOrder(t *testing.T) {
+	store := newMemoryOutbox(
+		[2]string{"pr-1", domain.EventPRCreated},
+		[2]string{"pr-2", domain.EventPRCreated},
+		[2]string{"pr-1", domain.EventReviewerAssigned},
+		[2]string{"pr-2", domain.EventPRMerged},
+		[2]string{"pr-1", domain.EventPRMerged},
+	)
+	bus := NewMemoryBus()
+	received := subscribe(t, bus)
+
+	cfg := testRelayConfig()
+	cfg.BatchSize = 2
+	relay := NewRelay(store, cfg, NewBusSink(bus, "reviewer."))
+
+	for i := 0; i < 5; i++ {
+		relay.processBatch(context.Background())
+	}
+
+	want := []string{"evt_1", "evt_2", "evt_3", "evt_4", "evt_5"}
+	if got := received(); !slices.Equal(got, want) {
+		t.Fatalf("received %v, want %v", got, want)
+	}
+	for id := int64(1); id <= 5; id++ {
+		if !store.row(id).published {
+			t.Errorf("event %d is not marked published", id)
+		}
+	}
+}
+
+func TestRelayBlocksAggregateAfterFailure(t *testing.T) {
+	store := newMemoryOutbox(
+		[2]string{"pr-1", domain.EventPRCreated},
+		[2]string{"pr-1", domain.EventReviewerAssigned},
+		[2]string{"pr-2", domain.EventPRCreated},
+	)
+	bus := NewMemoryBus()
+	received := subscribe(t, bus)
+
+	sinkDown := true
+	failing := &failingSink{fail: func(event *domain.OutboxEvent) bool {
+		return sinkDown && event.EventID == "evt_1"
+	}}
+	relay := NewRelay(store, testRelayConfig(), failing, NewBusSink(bus, "reviewer."))
+
+	// evt_1 не ушел: evt_2 того же PR ждет его, pr-2 публикуется
+	relay.processBatch(context.Background())
+	if got, want := received(), []string{"evt_3"}; !slices.Equal(got, want) {
+		t.Fatalf("received %v, want %v", got, want)
+	}
+	first := store.row(1)
+	if first.event.Attempts != 1 || first.lastError != "failing: connection refused" {
+		t.Errorf("evt_1 attempts = %d, last error = %q", first.event.Attempts, first.lastError)
+	}
+	if second := store.row(2); second.event.Attempts != 0 || second.published {
+		t.Errorf("evt_2 = %+v, want released without spending an attempt", second)
+	}
+
+	// До конца задержки агрегат заблокирован
+	relay.processBatch(context.Background())
+	if got := received(); len(got) != 1 {
+		t.Fatalf("received %v while pr-1 waits for retry", got)
+	}
+
+	sinkDown = false
+	store.advance(time.Second)
+	relay.processBatch(context.Background())
+	if got, want := received(), []string{"evt_3", "evt_1", "evt_2"}; !slices.Equal(got, want) {
+		t.Fatalf("received %v, want %v", got, want)
+	}
+}
+
+func TestRelayDeadEventUnblocksAggregate(t *testing.T) {
+	store := newMemoryOutbox(
+		[2]string{"pr-1", domain.EventPRCreated},
+		[2]string{"pr-1", domain.EventPRMerged},
+	)
+	bus := NewMemoryBus()
+	received := subscribe(t, bus)
+
+	failing := &failingSink{fail: func(event *domain.OutboxEvent) bool {
+		return event.EventID == "evt_1"
+	}}
+	cfg := testRelayConfig()
+	relay := NewRelay(store, cfg, failing, NewBusSink(bus, "reviewer."))
+
+	for attempt := 1; attempt <= cfg.MaxAttempts; attempt++ {
+		if got := received(); len(got) != 0 {
+			t.Fatalf("attempt %d: received %v before evt_1 is dead", attempt, got)
+		}
+		relay.processBatch(context.Background())
+		store.advance(cfg.MaxBackoff)
+	}
+
+	if first := store.row(1); !first.dead || first.event.Attempts != cfg.MaxAttempts {
+		t.Fatalf("evt_1 dead = %v after %d attempts, want dead after %d", first.dead, first.event.Attempts, cfg.MaxAttempts)
+	}
+
+	relay.processBatch(context.Background())
+	if got, want := received(), []string{"evt_2"}; !slices.Equal(got, want) {
+		t.Fatalf("received %v, want %v", got, want)
+	}
+	if store.row(1).published {
+		t.Error("dead event is marked published")
+	}
+}
+
+func TestRelayRedeliversAfterLeaseExpires(t *testing.T) {
+	store := newMemoryOutbox(
+		[2]string{"pr-1", domain.EventPRCreated},
+		[2]string{"pr-2", domain.EventPRCreated},
+	)
+	bus := NewMemoryBus()
+	received := subscribe(t, bus)
+	sink := NewBusSink(bus, "reviewer.")
+	cfg := testRelayConfig()
+
+	// Реплика забрала пачку, опубликовала первое событие и упала до записи результата
+	claimed, err := store.ClaimOutboxEvents(context.Background(), cfg.BatchSize, cfg.Lease)
+	if err != nil || len(claimed) != 2 {
+		t.Fatalf("claimed %d events, err %v", len(claimed), err)
+	}
+	if err := sink.Publish(context.Background(), claimed[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	relay := NewRelay(store, cfg, sink)
+
+	// Пока аренда не истекла, события не берутся повторно
+	if claimed, published := relay.processBatch(context.Background()); claimed != 0 || published != 0 {
+		t.Fatalf("claimed %d, published %d during lease", claimed, published)
+	}
+
+	store.advance(cfg.Lease)
+	relay.processBatch(context.Background())
+
+	// at-least-once: evt_1 пришел дважды с тем же id, получатель дедуплицирует
+	if got, want := received(), []string{"evt_1", "evt_1", "evt_2"}; !slices.Equal(got, want) {
+		t.Fatalf("received %v, want %v", got, want)
+	}
+	if !store.row(1).published || !store.row(2).published {
+		t.Error("events are not marked published after redelivery")
+	}
+}
@@ -0,0 +1,125 @@
+package outbox
+
+import (
+	"avito-tech-internship/internal/domain"
+	"context"
+	"io"
+	"strings"
+	"sync"
+)
+
+// WebhookStore ставит событие в очередь доставки вебхуков
+type WebhookStore interface {
+	EnqueueWebhookEvent(ctx context.Context, event *domain.OutboxEvent) error
+}
+
+// WebhookSink передает события подписчикам вебхуков
+type WebhookSink struct {
+	store WebhookStore
+}
+
+func NewWebhookSink(store WebhookStore) *WebhookSink {
+	return &WebhookSink{store: store}
+}
+
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *WebhookSink) Publish(ctx context.Context, event *domain.OutboxEvent) error {
+	return s.store.EnqueueWebhookEvent(ctx, event)
+}
+
+// WriterSink пишет события построчно в JSON, например в stdout для отладки
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (s *WriterSink) Name() string {
+	return "stdout"
+}
+
+func (s *WriterSink) Publish(_ context.Context, event *domain.OutboxEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	line := append(append([]byte{}, event.Payload...), '\n')
+	_, err := s.w.Write(line)
+	return err
+}
+
+// Publisher - подмножество клиента NATS (*nats.Conn удовлетворяет интерфейсу)
+type Publisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// BusSink публикует события в шину сообщений в subject <prefix><тип события>, например reviewer.pr.created
+type BusSink struct {
+	bus    Publisher
+	prefix string
+}
+
+func NewBusSink(bus Publisher, prefix string) *BusSink {
+	return &BusSink{bus: bus, prefix: prefix}
+}
+
+func (s *BusSink) Name() string {
+	return "bus"
+}
+
+func (s *BusSink) Publish(_ context.Context, event *domain.OutboxEvent) error {
+	return s.bus.Publish(s.prefix+event.EventType, event.Payload)
+}
+
+// MemoryBus - внутрипроцессная замена NATS: синхронно вызывает подписчиков.
+// Поддерживает точные subject и шаблоны с ">" в конце ("reviewer.>").
+type MemoryBus struct {
+	mu     sync.RWMutex
+	nextID int
+	subs   map[int]memorySubscription
+}
+
+type memorySubscription struct {
+	pattern string
+	handler func(subject string, data []byte)
+}
+
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{subs: make(map[int]memorySubscription)}
+}
+
+// Subscribe регистрирует обработчик и возвращает функцию отписки
+func (b *MemoryBus) Subscribe(pattern string, handler func(subject string, data []byte)) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.nextID
+	b.nextID++
+	b.subs[id] = memorySubscription{pattern: pattern, handler: handler}
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs, id)
+	}
+}
+
+func (b *MemoryBus) Publish(subject string, data []byte) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, sub := range b.subs {
+		if matchSubject(sub.pattern, subject) {
+			sub.handler(subject, data)
+		}
+	}
+	return nil
+}
+
+func matchSubject(pattern, subject string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, ">"); ok {
+		return strings.HasPrefix(subject, prefix)
+	}
+	return pattern == subject
+}
@@ -0,0 +1,249 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule - разобранное cron-выражение из пяти полей: минута, час, день месяца, месяц, день недели.
+// Поддерживаются *, списки, диапазоны, шаги (*/15, 1-5/2), имена месяцев и дней (JAN, MON),
+// макросы @hourly, @daily, @weekly, @monthly, @yearly и префикс CRON_TZ=<зона>.
+type Schedule struct {
+	spec   string
+	loc    *time.Location
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	// День месяца или недели задан как * - влияет на то, как они сочетаются
+	domStar bool
+	dowStar bool
+}
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = map[string]int{
+	"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+	"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+}
+
+var dayNames = map[string]int{
+	"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var fields = []field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: monthNames},
+	// 7 - тоже воскресенье
+	{name: "day of week", min: 0, max: 7, names: dayNames},
+}
+
+// Parse разбирает cron-выражение. Время считается в loc, если в выражении нет CRON_TZ=
+func Parse(spec string, loc *time.Location) (*Schedule, error) {
+	expr := strings.TrimSpace(spec)
+	if rest, ok := strings.CutPrefix(expr, "CRON_TZ="); ok {
+		zone, tail, _ := strings.Cut(rest, " ")
+		var err error
+		if loc, err = time.LoadLocation(zone); err != nil {
+			return nil, fmt.Errorf("unknown time zone %q", zone)
+		}
+		expr = strings.TrimSpace(tail)
+	}
+	if strings.HasPrefix(expr, "@") {
+		macro, ok := macros[expr]
+		if !ok {
+			return nil, fmt.Errorf("unknown macro %q", expr)
+		}
+		expr = macro
+	}
+
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("expected 5 fields, got %d", len(parts))
+	}
+	bits := make([]uint64, len(fields))
+	for i, part := range parts {
+		var err error
+		if bits[i], err = parseField(part, fields[i]); err != nil {
+			return nil, err
+		}
+	}
+	// Воскресенье как 7 приводим к 0
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = bits[4]&^(1<<7) | 1
+	}
+
+	return &Schedule{
+		spec:    spec,
+		loc:     loc,
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: strings.HasPrefix(parts[2], "*"),
+		dowStar: strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+func (s *Schedule) String() string {
+	return s.spec
+}
+
+// parseField разбирает одно поле в битовую маску допустимых значений
+func parseField(part string, f field) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(part, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s", stepPart, f.name)
+			}
+			step = n
+		}
+
+		var lo, hi int
+		switch {
+		case rangePart == "*":
+			lo, hi = f.min, f.max
+		case strings.Contains(rangePart, "-"):
+			from, to, _ := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = parseValue(from, f); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(to, f); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q in %s", rangePart, f.name)
+			}
+		default:
+			var err error
+			if lo, err = parseValue(rangePart, f); err != nil {
+				return 0, err
+			}
+			hi = lo
+			// "5/15" - с 5 до конца диапазона с шагом 15
+			if hasStep {
+				hi = f.max
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(s string, f field) (int, error) {
+	if v, ok := f.names[strings.ToUpper(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s %q, expected %d-%d", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next возвращает первый момент расписания строго после t или нулевое время,
+// если его нет в ближайшие пять лет (например, 30 февраля).
+// Переход на летнее время: несуществующее время (02:30 при переводе 02:00 -> 03:00) пропускается.
+// Переход на зимнее: повторяющийся час выполняется один раз, при первом проходе.
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := s.loc
+	t = t.In(loc).Truncate(time.Minute).Add(time.Minute)
+	yearLimit := t.Year() + 5
+
+wrap:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+	for s.month&(1<<uint(t.Month())) == 0 {
+		t = date(t.Year(), t.Month()+1, 1, 0, loc)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+	for !s.dayMatches(t) {
+		t = date(t.Year(), t.Month(), t.Day()+1, 0, loc)
+		if t.Day() == 1 {
+			goto wrap
+		}
+	}
+	for s.hour&(1<<uint(t.Hour())) == 0 {
+		t = date(t.Year(), t.Month(), t.Day(), t.Hour()+1, loc)
+		if t.Hour() == 0 {
+			goto wrap
+		}
+	}
+	for s.minute&(1<<uint(t.Minute())) == 0 {
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+	if _, repeated := firstPass(t); repeated {
+		t = t.Add(time.Minute)
+		goto wrap
+	}
+	return t
+}
+
+// date строит начало часа; если эти показания часов повторяются при переводе назад, берет первый проход
+func date(year int, month time.Month, day, hour int, loc *time.Location) time.Time {
+	t := time.Date(year, month, day, hour, 0, 0, 0, loc)
+	if earlier, repeated := firstPass(t); repeated {
+		return earlier
+	}
+	return t
+}
+
+// firstPass проверяет, что показания часов t уже были раньше, то есть t во втором проходе часа,
+// повторяющегося при переводе часов назад, и возвращает момент первого прохода
+func firstPass(t time.Time) (time.Time, bool) {
+	_, offset := t.Zone()
+	// Переводы часов не бывают чаще раза в несколько часов
+	_, before := t.Add(-6 * time.Hour).Zone()
+	if before <= offset {
+		return t, false
+	}
+	earlier := t.Add(-time.Duration(before-offset) * time.Second)
+	if earlier.Day() != t.Day() || earlier.Hour() != t.Hour() || earlier.Minute() != t.Minute() {
+		return t, false
+	}
+	return earlier, true
+}
+
+// dayMatches: если заданы и день месяца, и день недели, достаточно совпадения любого из них, как в cron
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
@@ -0,0 +1,143 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{spec: "*/15 * * * *"},
+		{spec: "0 9-18/3 * * MON-FRI"},
+		{spec: "0 0 1,15 JAN,jul *"},
+		{spec: "5/20 * * * *"},
+		{spec: "0 0 * * 7"},
+		{spec: "@daily"},
+		{spec: "  @hourly  "},
+		{spec: "CRON_TZ=Europe/Moscow 0 9 * * *"},
+		{spec: "CRON_TZ=Europe/Moscow @daily"},
+
+		{spec: "", wantErr: true},
+		{spec: "* * * *", wantErr: true},
+		{spec: "* * * * * *", wantErr: true},
+		{spec: "60 * * * *", wantErr: true},
+		{spec: "* 24 * * *", wantErr: true},
+		{spec: "* * 0 * *", wantErr: true},
+		{spec: "* * 32 * *", wantErr: true},
+		{spec: "* * * 13 *", wantErr: true},
+		{spec: "* * * * 8", wantErr: true},
+		{spec: "* * * FOO *", wantErr: true},
+		{spec: "a * * * *", wantErr: true},
+		{spec: "-5 * * * *", wantErr: true},
+		{spec: "*/0 * * * *", wantErr: true},
+		{spec: "*/x * * * *", wantErr: true},
+		{spec: "10-5 * * * *", wantErr: true},
+		{spec: "@every 5m", wantErr: true},
+		{spec: "CRON_TZ=Mars/Base 0 9 * * *", wantErr: true},
+		{spec: "CRON_TZ=UTC", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := Parse(tt.spec, time.UTC)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	berlin := mustLoad(t, "Europe/Berlin")
+	moscow := mustLoad(t, "Europe/Moscow")
+
+	// 2026-03-10 - вторник. В Берлине 2026-03-29 часы переводят 02:00 -> 03:00 (01:00Z),
+	// 2026-10-25 - 03:00 -> 02:00 (01:00Z)
+	tests := []struct {
+		name string
+		spec string
+		loc  *time.Location
+		from string
+		// Пустая строка - расписание не срабатывает
+		want string
+	}{
+		{name: "step", spec: "*/15 * * * *", from: "2026-03-10T10:07:30Z", want: "2026-03-10T10:15:00Z"},
+		{name: "strictly after", spec: "0 * * * *", from: "2026-03-10T10:00:00Z", want: "2026-03-10T11:00:00Z"},
+		{name: "step from value", spec: "5/20 * * * *", from: "2026-03-10T10:26:00Z", want: "2026-03-10T10:45:00Z"},
+		{name: "range with step", spec: "0 9-17/4 * * *", from: "2026-03-10T13:30:00Z", want: "2026-03-10T17:00:00Z"},
+		{name: "range with step wraps day", spec: "0 9-17/4 * * *", from: "2026-03-10T17:30:00Z", want: "2026-03-11T09:00:00Z"},
+		{name: "month list", spec: "0 0 1 JAN,JUL *", from: "2026-03-10T00:00:00Z", want: "2026-07-01T00:00:00Z"},
+		{name: "year wrap", spec: "0 0 1 1 *", from: "2026-12-31T23:59:00Z", want: "2027-01-01T00:00:00Z"},
+
+		{name: "day of week only", spec: "0 9 * * MON-FRI", from: "2026-03-13T10:00:00Z", want: "2026-03-16T09:00:00Z"},
+		{name: "day of month only", spec: "0 0 15 * *", from: "2026-03-16T00:00:00Z", want: "2026-04-15T00:00:00Z"},
+		{name: "both days, weekday first", spec: "0 0 13 * FRI", from: "2026-03-14T00:00:00Z", want: "2026-03-20T00:00:00Z"},
+		{name: "both days, month day first", spec: "0 0 1 * SUN", from: "2026-03-30T00:00:00Z", want: "2026-04-01T00:00:00Z"},
+		{name: "day of week with day of month star step", spec: "0 0 */1 * SUN", from: "2026-03-10T00:00:00Z", want: "2026-03-15T00:00:00Z"},
+
+		{name: "sunday as 7", spec: "0 0 * * 7", from: "2026-03-10T00:00:00Z", want: "2026-03-15T00:00:00Z"},
+		{name: "sunday as 0", spec: "0 0 * * 0", from: "2026-03-10T00:00:00Z", want: "2026-03-15T00:00:00Z"},
+		{name: "range up to 7", spec: "0 0 * * 5-7", from: "2026-03-14T01:00:00Z", want: "2026-03-15T00:00:00Z"},
+		{name: "weekly macro", spec: "@weekly", from: "2026-03-10T00:00:00Z", want: "2026-03-15T00:00:00Z"},
+
+		{name: "leap day", spec: "0 0 29 2 *", from: "2026-03-01T00:00:00Z", want: "2028-02-29T00:00:00Z"},
+		{name: "february 30", spec: "0 0 30 2 *", from: "2026-03-01T00:00:00Z", want: ""},
+		{name: "april 31", spec: "0 0 31 4 *", from: "2026-03-01T00:00:00Z", want: ""},
+
+		{name: "loc", spec: "0 9 * * *", loc: berlin, from: "2026-03-10T00:00:00Z", want: "2026-03-10T08:00:00Z"},
+		{name: "CRON_TZ", spec: "CRON_TZ=Europe/Moscow 0 9 * * *", from: "2026-03-10T05:00:00Z", want: "2026-03-10T06:00:00Z"},
+		{name: "CRON_TZ overrides loc", spec: "CRON_TZ=UTC 0 9 * * *", loc: moscow, from: "2026-03-10T05:00:00Z", want: "2026-03-10T09:00:00Z"},
+
+		{name: "spring forward skips missing time", spec: "30 2 * * *", loc: berlin, from: "2026-03-28T12:00:00Z", want: "2026-03-30T00:30:00Z"},
+		{name: "spring forward every 30 minutes", spec: "*/30 * * * *", loc: berlin, from: "2026-03-29T00:45:00Z", want: "2026-03-29T01:00:00Z"},
+		{name: "fall back first pass", spec: "30 2 * * *", loc: berlin, from: "2026-10-24T12:00:00Z", want: "2026-10-25T00:30:00Z"},
+		{name: "fall back repeated hour runs once", spec: "30 2 * * *", loc: berlin, from: "2026-10-25T00:30:00Z", want: "2026-10-26T01:30:00Z"},
+		{name: "fall back repeated hour from second pass", spec: "30 2 * * *", loc: berlin, from: "2026-10-25T01:10:00Z", want: "2026-10-26T01:30:00Z"},
+		{name: "fall back every 30 minutes", spec: "*/30 * * * *", loc: berlin, from: "2026-10-25T00:30:00Z", want: "2026-10-25T02:00:00Z"},
+		{name: "fall back hour start", spec: "0 2 * * *", loc: berlin, from: "2026-10-24T12:00:00Z", want: "2026-10-25T00:00:00Z"},
+		{name: "fall back after repeated hour", spec: "0 3 * * *", loc: berlin, from: "2026-10-24T12:00:00Z", want: "2026-10-25T02:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc := tt.loc
+			if loc == nil {
+				loc = time.UTC
+			}
+			s, err := Parse(tt.spec, loc)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.spec, err)
+			}
+			from, err := time.Parse(time.RFC3339, tt.from)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := s.Next(from)
+			if tt.want == "" {
+				if !got.IsZero() {
+					t.Fatalf("Next(%s) = %s, want no run", tt.from, got.UTC().Format(time.RFC3339))
+				}
+				return
+			}
+			if got.IsZero() {
+				t.Fatalf("Next(%s) = no run, want %s", tt.from, tt.want)
+			}
+			if g := got.UTC().Format(time.RFC3339); g != tt.want {
+				t.Fatalf("Next(%s) = %s, want %s", tt.from, g, tt.want)
+			}
+		})
+	}
+}
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("load %s: %v", name, err)
+	}
+	return loc
+}
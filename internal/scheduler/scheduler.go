@@ -0,0 +1,246 @@
+// Package scheduler запускает периодические задания по cron-расписанию.
+// Задание выполняет одна реплика: та, что взяла его advisory lock в Postgres, а запись запуска
+// с моментом расписания не дает другой реплике повторить тот же запуск после освобождения блокировки.
+package scheduler
+
+import (
+	"avito-tech-internship/internal/domain"
+	"avito-tech-internship/internal/metrics"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Сколько ждать записи результата запуска, если задание остановлено вместе с сервисом
+const finishTimeout = 5 * time.Second
+
+// Store реализуется storage.PostgresRepository
+type Store interface {
+	// AcquireJobLock берет блокировку задания без ожидания. release освобождает ее
+	AcquireJobLock(ctx context.Context, name string) (release func(), acquired bool, err error)
+	// StartJobRun записывает начало запуска. false - запуск этого момента расписания уже был
+	StartJobRun(ctx context.Context, run *domain.JobRun) (bool, error)
+	// FinishJobRun записывает статус, ошибку и длительность запуска
+	FinishJobRun(ctx context.Context, run *domain.JobRun) error
+	GetLastJobRuns(ctx context.Context) (map[string]*domain.JobRun, error)
+}
+
+type job struct {
+	name     string
+	schedule *Schedule
+	fn       func(ctx context.Context) error
+}
+
+type Scheduler struct {
+	store    Store
+	loc      *time.Location
+	instance string
+
+	mu    sync.Mutex
+	jobs  []*job
+	ctx   context.Context
+	wg    sync.WaitGroup
+	ended bool
+}
+
+// New создает планировщик, расписания без CRON_TZ= считаются в loc
+func New(store Store, loc *time.Location) *Scheduler {
+	instance, _ := os.Hostname()
+	return &Scheduler{store: store, loc: loc, instance: instance}
+}
+
+// Register добавляет задание. Вызывается до Run
+func (s *Scheduler) Register(name, spec string, fn func(ctx context.Context) error) error {
+	schedule, err := Parse(spec, s.loc)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", name, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.name == name {
+			return fmt.Errorf("job %s is already registered", name)
+		}
+	}
+	s.jobs = append(s.jobs, &job{name: name, schedule: schedule, fn: fn})
+	return nil
+}
+
+// Run запускает задания по расписанию до отмены ctx и ждет завершения текущих запусков
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	s.ctx = ctx
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.loop(ctx, j)
+		}()
+	}
+	s.mu.Unlock()
+
+	<-ctx.Done()
+	s.mu.Lock()
+	s.ended = true
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	for {
+		next := j.schedule.Next(time.Now())
+		if next.IsZero() {
+			slog.Warn("job schedule has no upcoming runs", "job", j.name, "schedule", j.schedule)
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		run, release, err := s.begin(ctx, j, domain.JobTriggerSchedule, &next)
+		if err != nil {
+			if errors.Is(err, errJobRunning) {
+				slog.Debug("job run skipped, handled by another replica", "job", j.name, "scheduled_for", next)
+			} else if ctx.Err() == nil {
+				slog.Error("could not start job", "job", j.name, "error", err)
+			}
+			continue
+		}
+		s.execute(ctx, j, run, release)
+	}
+}
+
+// Задание выполняет другая реплика или этот момент расписания уже обработан
+var errJobRunning = errors.New("JOB_RUNNING")
+
+// begin берет блокировку задания и записывает начало запуска
+func (s *Scheduler) begin(ctx context.Context, j *job, trigger string, scheduledFor *time.Time) (*domain.JobRun, func(), error) {
+	release, acquired, err := s.store.AcquireJobLock(ctx, j.name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !acquired {
+		return nil, nil, errJobRunning
+	}
+
+	run := &domain.JobRun{
+		JobName:      j.name,
+		Trigger:      trigger,
+		ScheduledFor: scheduledFor,
+		Status:       domain.JobRunRunning,
+		Instance:     s.instance,
+	}
+	started, err := s.store.StartJobRun(ctx, run)
+	if err != nil || !started {
+		release()
+		if err == nil {
+			err = errJobRunning
+		}
+		return nil, nil, err
+	}
+	return run, release, nil
+}
+
+// execute выполняет задание, записывает результат и освобождает блокировку
+func (s *Scheduler) execute(ctx context.Context, j *job, run *domain.JobRun, release func()) {
+	defer release()
+
+	slog.Info("job started", "job", j.name, "run", run.ID, "trigger", run.Trigger)
+	start := time.Now()
+	err := j.fn(ctx)
+
+	duration := time.Since(start).Milliseconds()
+	run.DurationMs = &duration
+	run.Status = domain.JobRunSucceeded
+	if err != nil {
+		message := err.Error()
+		run.Status = domain.JobRunFailed
+		run.Error = &message
+		slog.Error("job failed", "job", j.name, "run", run.ID, "duration_ms", duration, "error", err)
+	} else {
+		slog.Info("job finished", "job", j.name, "run", run.ID, "duration_ms", duration)
+	}
+	metrics.JobRun(j.name, run.Status)
+
+	finishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finishTimeout)
+	defer cancel()
+	if err := s.store.FinishJobRun(finishCtx, run); err != nil {
+		slog.Error("could not record job run", "job", j.name, "run", run.ID, "error", err)
+	}
+}
+
+// Trigger запускает задание вне расписания. Запуск идет в фоне, возвращается его запись.
+// JOB_RUNNING - задание сейчас выполняется на какой-то реплике
+func (s *Scheduler) Trigger(ctx context.Context, name string) (*domain.JobRun, error) {
+	j := s.find(name)
+	if j == nil {
+		return nil, errors.New("JOB_NOT_FOUND")
+	}
+
+	s.mu.Lock()
+	runCtx, ended := s.ctx, s.ended
+	s.mu.Unlock()
+	if runCtx == nil || ended {
+		return nil, errors.New("SCHEDULER_NOT_RUNNING")
+	}
+
+	run, release, err := s.begin(ctx, j, domain.JobTriggerManual, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		release()
+		return nil, errors.New("SCHEDULER_NOT_RUNNING")
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.execute(runCtx, j, run, release)
+	}()
+	return run, nil
+}
+
+// Jobs возвращает задания в порядке регистрации с ближайшим и последним запуском
+func (s *Scheduler) Jobs(ctx context.Context) ([]*domain.ScheduledJob, error) {
+	lastRuns, err := s.store.GetLastJobRuns(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	jobs := make([]*domain.ScheduledJob, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, &domain.ScheduledJob{
+			Name:      j.name,
+			Schedule:  j.schedule.String(),
+			NextRunAt: j.schedule.Next(now),
+			LastRun:   lastRuns[j.name],
+		})
+	}
+	return jobs, nil
+}
+
+func (s *Scheduler) find(name string) *job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.name == name {
+			return j
+		}
+	}
+	return nil
+}
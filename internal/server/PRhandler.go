@@ -2,9 +2,9 @@ package server
 
 import (
 	"avito-tech-internship/internal/domain"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"net/http"
-	"strings"
 )
 
 // PR handlers
@@ -16,15 +16,22 @@ func (h *Handler) CreatePullRequest(c *gin.Context) {
 		return
 	}
 
-	pr, err := h.service.CreatePullRequest(&req)
+	pr, err := h.service.CreatePullRequest(c.Request.Context(), &req)
 	if err != nil {
+		var capacityErr *domain.CapacityError
+		if errors.As(err, &capacityErr) {
+			writeErrorDetails(c, http.StatusConflict, "CAPACITY_EXHAUSTED", "all candidate reviewers reached their open reviews limit", capacityErr.Users)
+			return
+		}
 		switch err.Error() {
 		case "PR_EXISTS":
 			writeError(c, http.StatusConflict, "PR_EXISTS", "PR id already exists")
+		case "INVALID_TAG":
+			writeError(c, http.StatusBadRequest, "INVALID_TAG", "tag is too long")
 		case "author not found":
 			writeError(c, http.StatusNotFound, "NOT_FOUND", "author not found")
 		default:
-			writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			writeInternalError(c, err)
 		}
 		return
 	}
@@ -42,13 +49,17 @@ func (h *Handler) MergePullRequest(c *gin.Context) {
 		return
 	}
 
-	pr, err := h.service.MergePullRequest(req.PRID)
+	pr, err := h.service.MergePullRequest(c.Request.Context(), req.PRID)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if err.Error() == "PR_CLOSED" {
+			writeError(c, http.StatusConflict, "PR_CLOSED", "cannot merge closed PR")
+			return
+		}
+		if err.Error() == "pull request not found" {
 			writeError(c, http.StatusNotFound, "NOT_FOUND", "pull request not found")
 			return
 		}
-		writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeInternalError(c, err)
 		return
 	}
 
@@ -65,11 +76,18 @@ func (h *Handler) ReassignReviewer(c *gin.Context) {
 		return
 	}
 
-	pr, newReviewerID, err := h.service.ReassignReviewer(req.PRID, req.OldReviewerID)
+	pr, newReviewerID, err := h.service.ReassignReviewer(c.Request.Context(), req.PRID, req.OldReviewerID)
 	if err != nil {
+		var capacityErr *domain.CapacityError
+		if errors.As(err, &capacityErr) {
+			writeErrorDetails(c, http.StatusConflict, "CAPACITY_EXHAUSTED", "all candidate reviewers reached their open reviews limit", capacityErr.Users)
+			return
+		}
 		switch err.Error() {
 		case "PR_MERGED":
 			writeError(c, http.StatusConflict, "PR_MERGED", "cannot reassign on merged PR")
+		case "PR_CLOSED":
+			writeError(c, http.StatusConflict, "PR_CLOSED", "cannot reassign on closed PR")
 		case "NOT_ASSIGNED":
 			writeError(c, http.StatusConflict, "NOT_ASSIGNED", "reviewer is not assigned to this PR")
 		case "NO_CANDIDATE":
@@ -77,7 +95,7 @@ func (h *Handler) ReassignReviewer(c *gin.Context) {
 		case "pull request not found", "reviewer not found":
 			writeError(c, http.StatusNotFound, "NOT_FOUND", "resource not found")
 		default:
-			writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			writeInternalError(c, err)
 		}
 		return
 	}
@@ -95,13 +113,13 @@ func (h *Handler) GetUserReview(c *gin.Context) {
 		return
 	}
 
-	prs, err := h.service.GetUserAssignedPRs(userID)
+	prs, err := h.service.GetUserAssignedPRs(c.Request.Context(), userID)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if err.Error() == "NOT_FOUND" {
 			writeError(c, http.StatusNotFound, "NOT_FOUND", "user not found")
 			return
 		}
-		writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeInternalError(c, err)
 		return
 	}
 
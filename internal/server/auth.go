@@ -0,0 +1,44 @@
+package server
+
+import (
+	"crypto/subtle"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+// Служебные эндпоинты доступны без ключа - их опрашивают оркестратор и Prometheus.
+// Входящие вебхуки проверяются по подписи
+var publicPaths = map[string]bool{
+	"/health":                      true,
+	"/livez":                       true,
+	"/readyz":                      true,
+	"/metrics":                     true,
+	"/integrations/github/webhook": true,
+	"/integrations/gitlab/webhook": true,
+}
+
+// apiKeyAuth требует ключ в "Authorization: Bearer <key>" или "X-API-Key".
+// Без настроенных ключей аутентификация выключена.
+func apiKeyAuth(keys []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(keys) == 0 || publicPaths[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		provided := c.GetHeader("X-API-Key")
+		if bearer, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+			provided = strings.TrimSpace(bearer)
+		}
+
+		for _, key := range keys {
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(key)) == 1 {
+				c.Next()
+				return
+			}
+		}
+		writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "missing or invalid API key")
+		c.Abort()
+	}
+}
@@ -3,7 +3,9 @@ package server
 import (
 	"avito-tech-internship/internal/domain"
 	"avito-tech-internship/internal/service"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"log/slog"
@@ -26,17 +28,16 @@ func (h *Handler) AddNewUser(c *gin.Context) {
 		writeError(c, http.StatusBadRequest, "INVALID_JSON", fmt.Sprintf("Invalid JSON: %v", err))
 		return
 	}
-	createdUser, err := h.service.AddNewUser(&user)
+	createdUser, err := h.service.AddNewUser(c.Request.Context(), &user)
 	if err != nil {
-		if strings.Contains(err.Error(), "team not found") {
+		switch err.Error() {
+		case "team not found":
 			writeError(c, http.StatusNotFound, "TEAM_NOT_FOUND", "team not found")
-			return
-		}
-		if strings.Contains(err.Error(), "user already exists") {
+		case "user already exists":
 			writeError(c, http.StatusConflict, "USER_EXISTS", "user already exists")
-			return
+		default:
+			writeInternalError(c, err)
 		}
-		writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
 		return
 	}
 
@@ -50,13 +51,13 @@ func (h *Handler) GetUserByID(c *gin.Context) {
 		return
 	}
 
-	user, err := h.service.GetUserByID(userID)
+	user, err := h.service.GetUserByID(c.Request.Context(), userID)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if err.Error() == "NOT_FOUND" {
 			writeError(c, http.StatusNotFound, "NOT_FOUND", "user not found")
 			return
 		}
-		writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeInternalError(c, err)
 		return
 	}
 
@@ -77,13 +78,65 @@ func (h *Handler) SetUserActive(c *gin.Context) {
 		return
 	}
 
-	user, err := h.service.SetUserActive(req.UserID, req.IsActive)
+	user, err := h.service.SetUserActive(c.Request.Context(), req.UserID, req.IsActive)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if err.Error() == "user was not found" {
 			writeError(c, http.StatusNotFound, "NOT_FOUND", "user not found")
 			return
 		}
-		writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": user,
+	})
+}
+
+func (h *Handler) SetMaxOpenReviews(c *gin.Context) {
+	var req domain.SetMaxOpenReviewsRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+		return
+	}
+
+	user, err := h.service.SetMaxOpenReviews(c.Request.Context(), &req)
+	if err != nil {
+		switch {
+		case err.Error() == "INVALID_LIMIT":
+			writeError(c, http.StatusBadRequest, "INVALID_LIMIT", "max_open_reviews must be non-negative")
+		case err.Error() == "user was not found":
+			writeError(c, http.StatusNotFound, "NOT_FOUND", "user not found")
+		default:
+			writeInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": user,
+	})
+}
+
+func (h *Handler) AddUserSkills(c *gin.Context) {
+	var req domain.UserSkillsRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+		return
+	}
+
+	user, err := h.service.AddUserSkills(c.Request.Context(), &req)
+	if err != nil {
+		switch {
+		case err.Error() == "INVALID_TAG":
+			writeError(c, http.StatusBadRequest, "INVALID_TAG", "skill tag is too long")
+		case err.Error() == "NOT_FOUND":
+			writeError(c, http.StatusNotFound, "NOT_FOUND", "user not found")
+		default:
+			writeInternalError(c, err)
+		}
 		return
 	}
 
@@ -92,29 +145,142 @@ func (h *Handler) SetUserActive(c *gin.Context) {
 	})
 }
 
+func (h *Handler) RemoveUserSkills(c *gin.Context) {
+	var req domain.UserSkillsRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+		return
+	}
+
+	user, err := h.service.RemoveUserSkills(c.Request.Context(), &req)
+	if err != nil {
+		switch {
+		case err.Error() == "INVALID_TAG":
+			writeError(c, http.StatusBadRequest, "INVALID_TAG", "skill tag is too long")
+		case err.Error() == "NOT_FOUND":
+			writeError(c, http.StatusNotFound, "NOT_FOUND", "user not found")
+		default:
+			writeInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": user,
+	})
+}
+
+func (h *Handler) GetUserSkills(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		writeError(c, http.StatusBadRequest, "MISSING_PARAM", "user_id parameter is required")
+		return
+	}
+
+	skills, err := h.service.GetUserSkills(c.Request.Context(), userID)
+	if err != nil {
+		if err.Error() == "NOT_FOUND" {
+			writeError(c, http.StatusNotFound, "NOT_FOUND", "user not found")
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id": userID,
+		"skills":  skills,
+	})
+}
+
 func (h *Handler) createNewTeam(c *gin.Context) {
-	var team domain.Team
+	var req domain.CreateTeamRequest
 	body, _ := c.GetRawData()
-	if err := json.Unmarshal(body, &team); err != nil {
+	if err := json.Unmarshal(body, &req); err != nil {
 		writeError(c, http.StatusBadRequest, "INVALID_JSON", fmt.Sprintf("Invalid JSON: %v", err))
 		return
 	}
-	err := h.service.CreateNewTeam(&team)
+	team, results, err := h.service.CreateNewTeam(c.Request.Context(), &req)
 	if err != nil {
-		if err.Error() == "TEAM_EXISTS" {
+		switch err.Error() {
+		case "TEAM_EXISTS":
 			writeError(c, http.StatusBadRequest, "TEAM_EXISTS", "team_name already exists")
-			return
+		case "INVALID_MODE":
+			writeError(c, http.StatusBadRequest, "INVALID_MODE", "mode must be one of: fail, move, skip, join")
+		case "DUPLICATE_MEMBER":
+			writeError(c, http.StatusBadRequest, "DUPLICATE_MEMBER", "members contain duplicate user_id")
+		case "parent team not found":
+			writeError(c, http.StatusNotFound, "NOT_FOUND", "parent team not found")
+		case "MEMBERS_CONFLICT":
+			var conflicts []*domain.TeamMemberResult
+			for _, result := range results {
+				if result.Result == domain.MemberConflict {
+					conflicts = append(conflicts, result)
+				}
+			}
+			writeErrorDetails(c, http.StatusConflict, "MEMBERS_CONFLICT", "some users already belong to another team", conflicts)
+		default:
+			writeInternalError(c, err)
 		}
-		writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
 		return
 	}
 
-	response := domain.Team{
-		TeamName: team.TeamName,
-		Members:  team.Members,
+	c.JSON(http.StatusOK, gin.H{
+		"team":    team,
+		"members": results,
+	})
+}
+
+func (h *Handler) AddTeamMember(c *gin.Context) {
+	var req domain.TeamMemberRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+		return
+	}
+
+	user, err := h.service.AddTeamMember(c.Request.Context(), &req)
+	if err != nil {
+		switch err.Error() {
+		case "INVALID_SHARE":
+			writeError(c, http.StatusBadRequest, "INVALID_SHARE", "review_share must be in (0, 1]")
+		case "team not found", "user not found":
+			writeError(c, http.StatusNotFound, "NOT_FOUND", err.Error())
+		default:
+			writeInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": user,
+	})
+}
+
+func (h *Handler) RemoveTeamMember(c *gin.Context) {
+	var req domain.TeamMemberRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+		return
+	}
+
+	user, err := h.service.RemoveTeamMember(c.Request.Context(), &req)
+	if err != nil {
+		switch err.Error() {
+		case "PRIMARY_TEAM":
+			writeError(c, http.StatusConflict, "PRIMARY_TEAM", "cannot remove user from primary team, use team move instead")
+		case "team not found", "membership not found":
+			writeError(c, http.StatusNotFound, "NOT_FOUND", err.Error())
+		default:
+			writeInternalError(c, err)
+		}
+		return
 	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"team": response,
+		"user": user,
 	})
 }
 
@@ -125,9 +291,13 @@ func (h *Handler) GetTeamByName(c *gin.Context) {
 		return
 	}
 
-	team, err := h.service.GetTeamByName(teamName)
+	team, err := h.service.GetTeamByName(c.Request.Context(), teamName)
 	if err != nil {
-		writeError(c, http.StatusNotFound, "NOT_FOUND", "resource not found")
+		if err.Error() == "team not found" {
+			writeError(c, http.StatusNotFound, "NOT_FOUND", "resource not found")
+			return
+		}
+		writeInternalError(c, err)
 		return
 	}
 
@@ -135,25 +305,174 @@ func (h *Handler) GetTeamByName(c *gin.Context) {
 
 }
 
+func (h *Handler) SetTeamCodeowners(c *gin.Context) {
+	var req domain.TeamCodeownersRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+		return
+	}
+
+	err := h.service.SetTeamCodeowners(c.Request.Context(), &req)
+	if err != nil {
+		switch {
+		case strings.HasPrefix(err.Error(), "INVALID_CODEOWNERS"):
+			writeError(c, http.StatusBadRequest, "INVALID_CODEOWNERS", err.Error())
+		case err.Error() == "team not found":
+			writeError(c, http.StatusNotFound, "NOT_FOUND", "team not found")
+		default:
+			writeInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"team_name": req.TeamName,
+		"content":   req.Content,
+	})
+}
+
+func (h *Handler) GetTeamCodeowners(c *gin.Context) {
+	teamName := c.Param("teamName")
+
+	content, err := h.service.GetTeamCodeowners(c.Request.Context(), teamName)
+	if err != nil {
+		if err.Error() == "codeowners not found" {
+			writeError(c, http.StatusNotFound, "NOT_FOUND", "codeowners not found")
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"team_name": teamName,
+		"content":   content,
+	})
+}
+
+func (h *Handler) SetPairingHistoryDepth(c *gin.Context) {
+	var req domain.SetPairingDepthRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+		return
+	}
+
+	depth, err := h.service.SetPairingHistoryDepth(c.Request.Context(), &req)
+	if err != nil {
+		switch err.Error() {
+		case "INVALID_DEPTH":
+			writeError(c, http.StatusBadRequest, "INVALID_DEPTH", "depth must be between 0 and 100")
+		case "team not found":
+			writeError(c, http.StatusNotFound, "NOT_FOUND", "team not found")
+		default:
+			writeInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"team_name": req.TeamName,
+		"depth":     depth,
+	})
+}
+
+func (h *Handler) SetTeamParent(c *gin.Context) {
+	var req domain.SetTeamParentRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+		return
+	}
+
+	team, err := h.service.SetTeamParent(c.Request.Context(), &req)
+	if err != nil {
+		switch err.Error() {
+		case "TEAM_CYCLE":
+			writeError(c, http.StatusConflict, "TEAM_CYCLE", "team cannot be a descendant of itself")
+		case "team not found", "parent team not found":
+			writeError(c, http.StatusNotFound, "NOT_FOUND", err.Error())
+		default:
+			writeInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"team": team,
+	})
+}
+
+func (h *Handler) GetTeamTree(c *gin.Context) {
+	tree, err := h.service.GetTeamTree(c.Request.Context())
+	if err != nil {
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"teams": tree,
+	})
+}
+
 func (h *Handler) GetStats(c *gin.Context) {
-	stats, err := h.service.GetStats()
+	stats, err := h.service.GetStats(c.Request.Context())
 	if err != nil {
-		writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeInternalError(c, err)
+		return
 	}
 	c.JSON(http.StatusOK, gin.H{
 		"stats": stats,
 	})
 }
 
+func (h *Handler) GetPairingStats(c *gin.Context) {
+	teamName := c.Query("team_name")
+	if teamName == "" {
+		writeError(c, http.StatusBadRequest, "MISSING_PARAM", "team_name parameter is required")
+		return
+	}
+
+	matrix, err := h.service.GetPairingStats(c.Request.Context(), teamName)
+	if err != nil {
+		if err.Error() == "team not found" {
+			writeError(c, http.StatusNotFound, "NOT_FOUND", "team not found")
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"pairings": matrix,
+	})
+}
+
+// writeInternalError отвечает 504, если запрос не уложился в отведенное время, иначе 500
+func writeInternalError(c *gin.Context, err error) {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(c.Request.Context().Err(), context.DeadlineExceeded) {
+		writeError(c, http.StatusGatewayTimeout, "TIMEOUT", "request timed out")
+		return
+	}
+	writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+}
+
 func writeError(c *gin.Context, status int, code, message string) {
+	writeErrorDetails(c, status, code, message, nil)
+}
+
+func writeErrorDetails(c *gin.Context, status int, code, message string, details any) {
 	errResponse := struct {
 		Error struct {
 			Code    string `json:"code"`
 			Message string `json:"message"`
+			Details any    `json:"details,omitempty"`
 		} `json:"error"`
 	}{}
 	errResponse.Error.Message = message
 	errResponse.Error.Code = code
-	slog.ErrorContext(c, message, errResponse)
+	errResponse.Error.Details = details
+	slog.ErrorContext(c, message, "response", errResponse)
 	c.JSON(status, errResponse)
 }
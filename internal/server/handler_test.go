@@ -0,0 +1,124 @@
+package server
+
+import (
+	"avito-tech-internship/internal/domain"
+	"avito-tech-internship/internal/service"
+	"avito-tech-internship/internal/storage"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowRepo отвечает на запросы записи так же, как PostgresRepository: с block запрос
+// ждет отмены контекста и возвращает ее причину, иначе возвращает err
+type slowRepo struct {
+	storage.Repository
+
+	block bool
+	err   error
+}
+
+func (r *slowRepo) wait(ctx context.Context) error {
+	if r.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return r.err
+}
+
+func (r *slowRepo) AddNewUser(ctx context.Context, _ *domain.User) (*domain.User, error) {
+	return nil, r.wait(ctx)
+}
+
+func (r *slowRepo) GetPullRequestByID(ctx context.Context, _ string) (*domain.PullRequest, error) {
+	return nil, r.wait(ctx)
+}
+
+func TestWritePathErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		repo       *slowRepo
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:       "add user past deadline",
+			path:       "/users/addNew",
+			body:       `{"user_id": "u1", "username": "Alice", "team_name": "backend"}`,
+			repo:       &slowRepo{block: true},
+			wantStatus: http.StatusGatewayTimeout,
+			wantCode:   "TIMEOUT",
+		},
+		{
+			name:       "add user to unknown team",
+			path:       "/users/addNew",
+			body:       `{"user_id": "u1", "username": "Alice", "team_name": "backend"}`,
+			repo:       &slowRepo{err: errors.New("team not found")},
+			wantStatus: http.StatusNotFound,
+			wantCode:   "TEAM_NOT_FOUND",
+		},
+		{
+			name:       "add user on database error",
+			path:       "/users/addNew",
+			body:       `{"user_id": "u1", "username": "Alice", "team_name": "backend"}`,
+			repo:       &slowRepo{err: errors.New("pq: connection refused")},
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   "INTERNAL_ERROR",
+		},
+		{
+			name:       "merge past deadline",
+			path:       "/pullRequest/merge",
+			body:       `{"pull_request_id": "pr-1"}`,
+			repo:       &slowRepo{block: true},
+			wantStatus: http.StatusGatewayTimeout,
+			wantCode:   "TIMEOUT",
+		},
+		{
+			name:       "merge unknown pull request",
+			path:       "/pullRequest/merge",
+			body:       `{"pull_request_id": "pr-1"}`,
+			repo:       &slowRepo{err: errors.New("pull request not found")},
+			wantStatus: http.StatusNotFound,
+			wantCode:   "NOT_FOUND",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(service.NewService(tt.repo, service.Config{ReviewersCount: 2}))
+			router := gin.New()
+			router.Use(requestTimeout(20 * time.Millisecond))
+			router.POST("/users/addNew", handler.AddNewUser)
+			router.POST("/pullRequest/merge", handler.MergePullRequest)
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			var response struct {
+				Error struct {
+					Code string `json:"code"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if response.Error.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", response.Error.Code, tt.wantCode)
+			}
+		})
+	}
+}
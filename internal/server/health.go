@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// Предельное время всех проверок готовности вместе
+const probeTimeout = 2 * time.Second
+
+const (
+	checkOK   = "ok"
+	checkFail = "fail"
+)
+
+type readinessCheck struct {
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Details any    `json:"details,omitempty"`
+}
+
+// livez отвечает, пока процесс жив и обслуживает запросы. Зависимости не проверяются,
+// чтобы недоступная БД не приводила к перезапуску контейнера.
+func (s *Server) livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "alive"})
+}
+
+// readyz проверяет, можно ли направлять на экземпляр трафик
+func (s *Server) readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), probeTimeout)
+	defer cancel()
+
+	checks := map[string]readinessCheck{
+		"shutdown":        s.checkShutdown(),
+		"database":        s.checkDatabase(ctx),
+		"migrations":      s.checkMigrations(ctx),
+		"background_jobs": s.checkBackgroundJobs(),
+		"db_pool":         s.checkDBPool(),
+	}
+
+	status, code := "ready", http.StatusOK
+	for _, check := range checks {
+		if check.Status != checkOK {
+			status, code = "not_ready", http.StatusServiceUnavailable
+			break
+		}
+	}
+	c.JSON(code, gin.H{"status": status, "checks": checks})
+}
+
+func (s *Server) checkShutdown() readinessCheck {
+	if s.shuttingDown.Load() {
+		return readinessCheck{Status: checkFail, Error: "server is shutting down"}
+	}
+	return readinessCheck{Status: checkOK}
+}
+
+func (s *Server) checkDatabase(ctx context.Context) readinessCheck {
+	start := time.Now()
+	if err := s.db.PingContext(ctx); err != nil {
+		return readinessCheck{Status: checkFail, Error: err.Error()}
+	}
+	return readinessCheck{
+		Status:  checkOK,
+		Details: gin.H{"latency_ms": time.Since(start).Milliseconds()},
+	}
+}
+
+// Версия схемы должна совпадать с последней встроенной миграцией
+func (s *Server) checkMigrations(ctx context.Context) readinessCheck {
+	status, err := s.migrator.Status(ctx)
+	if err != nil {
+		return readinessCheck{Status: checkFail, Error: err.Error()}
+	}
+
+	check := readinessCheck{Status: checkOK, Details: status}
+	switch {
+	case status.Dirty:
+		check.Status, check.Error = checkFail, fmt.Sprintf("migration %d failed and left the schema dirty", status.Version)
+	case status.Version != status.Latest:
+		check.Status, check.Error = checkFail, fmt.Sprintf("schema version %d, expected %d", status.Version, status.Latest)
+	}
+	return check
+}
+
+// Задача, завершившаяся до остановки сервера, значит что часть функций не работает
+func (s *Server) checkBackgroundJobs() readinessCheck {
+	jobs := s.jobs.Status()
+	check := readinessCheck{Status: checkOK, Details: gin.H{"jobs": jobs}}
+	if s.shuttingDown.Load() {
+		return check
+	}
+	for _, job := range jobs {
+		if !job.Running {
+			check.Status, check.Error = checkFail, fmt.Sprintf("background job %s stopped", job.Name)
+			break
+		}
+	}
+	return check
+}
+
+// Все соединения пула заняты - новые запросы будут ждать свободного соединения
+func (s *Server) checkDBPool() readinessCheck {
+	stats := s.db.Stats()
+	check := readinessCheck{
+		Status: checkOK,
+		Details: gin.H{
+			"max_open":      stats.MaxOpenConnections,
+			"open":          stats.OpenConnections,
+			"in_use":        stats.InUse,
+			"idle":          stats.Idle,
+			"wait_count":    stats.WaitCount,
+			"wait_duration": stats.WaitDuration.String(),
+		},
+	}
+	if stats.MaxOpenConnections > 0 && stats.InUse >= stats.MaxOpenConnections {
+		check.Status, check.Error = checkFail, "connection pool is saturated"
+	}
+	return check
+}
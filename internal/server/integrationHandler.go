@@ -0,0 +1,192 @@
+package server
+
+import (
+	"avito-tech-internship/internal/domain"
+	"avito-tech-internship/internal/integration"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// GitHub ограничивает тело вебхука 25 МБ
+const maxForgeWebhookBody = 25 << 20
+
+// GitHubWebhook принимает вебхуки GitHub. Аутентификация - подпись X-Hub-Signature-256 на secret,
+// поэтому эндпоинт не требует API-ключа
+func (h *Handler) GitHubWebhook(secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxForgeWebhookBody))
+		if err != nil {
+			writeError(c, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+			return
+		}
+		if !integration.VerifyGitHubSignature(secret, body, c.GetHeader("X-Hub-Signature-256")) {
+			writeError(c, http.StatusUnauthorized, "INVALID_SIGNATURE", "X-Hub-Signature-256 does not match payload")
+			return
+		}
+
+		eventName := c.GetHeader("X-GitHub-Event")
+		deliveryID := c.GetHeader("X-GitHub-Delivery")
+		if eventName == "" || deliveryID == "" {
+			writeError(c, http.StatusBadRequest, "MISSING_PARAM", "X-GitHub-Event and X-GitHub-Delivery headers are required")
+			return
+		}
+		// GitHub шлет ping при создании вебхука
+		if eventName == "ping" {
+			c.JSON(http.StatusOK, gin.H{"result": "pong"})
+			return
+		}
+
+		action, event, err := integration.ParseGitHubEvent(eventName, body)
+		if err != nil {
+			writeError(c, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+			return
+		}
+
+		delivery := &domain.IntegrationDelivery{
+			Provider:   domain.ProviderGitHub,
+			DeliveryID: deliveryID,
+			Event:      eventName,
+			Action:     action,
+		}
+		h.handleForgeEvent(c, delivery, event)
+	}
+}
+
+// GitLabWebhook принимает Merge Request Hook из GitLab, аутентификация - секретный токен в X-Gitlab-Token
+func (h *Handler) GitLabWebhook(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !integration.VerifyGitLabToken(token, c.GetHeader("X-Gitlab-Token")) {
+			writeError(c, http.StatusUnauthorized, "INVALID_TOKEN", "X-Gitlab-Token does not match")
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxForgeWebhookBody))
+		if err != nil {
+			writeError(c, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+			return
+		}
+
+		// Idempotency-Key одинаков во всех повторах доставки, X-Gitlab-Event-UUID - в старых версиях GitLab
+		eventName := c.GetHeader("X-Gitlab-Event")
+		deliveryID := c.GetHeader("Idempotency-Key")
+		if deliveryID == "" {
+			deliveryID = c.GetHeader("X-Gitlab-Event-UUID")
+		}
+		if eventName == "" || deliveryID == "" {
+			writeError(c, http.StatusBadRequest, "MISSING_PARAM", "X-Gitlab-Event and X-Gitlab-Event-UUID headers are required")
+			return
+		}
+
+		action, event, err := integration.ParseGitLabEvent(eventName, body)
+		if err != nil {
+			writeError(c, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+			return
+		}
+
+		delivery := &domain.IntegrationDelivery{
+			Provider:   domain.ProviderGitLab,
+			DeliveryID: deliveryID,
+			Event:      eventName,
+			Action:     action,
+		}
+		h.handleForgeEvent(c, delivery, event)
+	}
+}
+
+func (h *Handler) handleForgeEvent(c *gin.Context, delivery *domain.IntegrationDelivery, event *domain.ForgePREvent) {
+	delivery, err := h.service.HandleForgeEvent(c.Request.Context(), delivery, event)
+	if err != nil {
+		var capacityErr *domain.CapacityError
+		if errors.As(err, &capacityErr) {
+			writeErrorDetails(c, http.StatusConflict, "CAPACITY_EXHAUSTED", "all candidate reviewers reached their open reviews limit", capacityErr.Users)
+			return
+		}
+		if login, ok := strings.CutPrefix(err.Error(), "UNMAPPED_USER: "); ok {
+			writeError(c, http.StatusUnprocessableEntity, "UNMAPPED_USER", "no user mapping for login "+login)
+			return
+		}
+		if err.Error() == "INVALID_TAG" {
+			writeError(c, http.StatusBadRequest, "INVALID_TAG", "tag is too long")
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"delivery": delivery,
+	})
+}
+
+func (h *Handler) SetExternalUserMapping(c *gin.Context) {
+	var req domain.ExternalUserMappingRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+		return
+	}
+
+	mapping, err := h.service.SetExternalUserMapping(c.Request.Context(), &req)
+	if err != nil {
+		switch err.Error() {
+		case "INVALID_PROVIDER":
+			writeErrorDetails(c, http.StatusBadRequest, "INVALID_PROVIDER", "unknown provider", domain.Providers)
+		case "INVALID_USER":
+			writeError(c, http.StatusBadRequest, "INVALID_INPUT", "user_id is required")
+		case "user not found":
+			writeError(c, http.StatusNotFound, "NOT_FOUND", "user not found")
+		default:
+			writeInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"mapping": mapping,
+	})
+}
+
+func (h *Handler) DeleteExternalUserMapping(c *gin.Context) {
+	var req domain.ExternalUserMappingRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+		return
+	}
+
+	err := h.service.DeleteExternalUserMapping(c.Request.Context(), &req)
+	if err != nil {
+		switch err.Error() {
+		case "INVALID_PROVIDER":
+			writeErrorDetails(c, http.StatusBadRequest, "INVALID_PROVIDER", "unknown provider", domain.Providers)
+		case "mapping not found":
+			writeError(c, http.StatusNotFound, "NOT_FOUND", "mapping not found")
+		default:
+			writeInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"provider":       req.Provider,
+		"external_login": req.ExternalLogin,
+	})
+}
+
+func (h *Handler) GetExternalUserMappings(c *gin.Context) {
+	mappings, err := h.service.GetExternalUserMappings(c.Request.Context(), c.Query("provider"))
+	if err != nil {
+		if err.Error() == "INVALID_PROVIDER" {
+			writeErrorDetails(c, http.StatusBadRequest, "INVALID_PROVIDER", "unknown provider", domain.Providers)
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"mappings": mappings,
+	})
+}
@@ -0,0 +1,424 @@
+package server
+
+import (
+	"avito-tech-internship/internal/domain"
+	"avito-tech-internship/internal/service"
+	"avito-tech-internship/internal/storage"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const githubSecret = "github-webhook-secret-for-tests"
+
+// Идентификатор PR из записанных вебхуков http/github/*.json
+const githubPRID = "avito-tech/reviewer-service#42"
+
+// forgeRepo - хранилище в памяти для сценариев вебхуков. Остальные методы storage.Repository
+// в этих сценариях не вызываются, обращение к ним завершит тест паникой
+type forgeRepo struct {
+	storage.Repository
+
+	mu         sync.Mutex
+	prs        map[string]*domain.PullRequest
+	deliveries map[string]*domain.IntegrationDelivery
+	// provider/login -> user_id
+	users      map[string]string
+	teamIDs    map[string][]string
+	candidates []domain.ReviewerCandidate
+	created    int
+}
+
+func newForgeRepo() *forgeRepo {
+	return &forgeRepo{
+		prs:        make(map[string]*domain.PullRequest),
+		deliveries: make(map[string]*domain.IntegrationDelivery),
+		users:      map[string]string{"github/octocat": "u1", "github/hubot": "u2", "github/monalisa": "u3"},
+		teamIDs:    map[string][]string{"u1": {"backend"}},
+		candidates: []domain.ReviewerCandidate{
+			{User: domain.User{UserId: "u2", Username: "hubot", IsActive: true}, ReviewShare: 1},
+			{User: domain.User{UserId: "u3", Username: "monalisa", IsActive: true}, ReviewShare: 1},
+		},
+	}
+}
+
+func (r *forgeRepo) GetIntegrationDelivery(_ context.Context, provider, deliveryID string) (*domain.IntegrationDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delivery, ok := r.deliveries[provider+"/"+deliveryID]
+	if !ok {
+		return nil, errors.New("delivery not found")
+	}
+	copied := *delivery
+	return &copied, nil
+}
+
+func (r *forgeRepo) RecordIntegrationDelivery(_ context.Context, delivery *domain.IntegrationDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *delivery
+	r.deliveries[delivery.Provider+"/"+delivery.DeliveryID] = &copied
+	return nil
+}
+
+func (r *forgeRepo) ResolveExternalUser(_ context.Context, provider, externalLogin string) (string, error) {
+	userID, ok := r.users[provider+"/"+externalLogin]
+	if !ok {
+		return "", errors.New("mapping not found")
+	}
+	return userID, nil
+}
+
+func (r *forgeRepo) GetUserTeamIDs(_ context.Context, userID string) ([]string, error) {
+	teamIDs, ok := r.teamIDs[userID]
+	if !ok {
+		return nil, errors.New("user not found")
+	}
+	return teamIDs, nil
+}
+
+func (r *forgeRepo) GetActiveTeamMembers(_ context.Context, _ []string, excludeUserID string) ([]domain.ReviewerCandidate, error) {
+	var members []domain.ReviewerCandidate
+	for _, candidate := range r.candidates {
+		if candidate.UserId != excludeUserID {
+			members = append(members, candidate)
+		}
+	}
+	return members, nil
+}
+
+func (r *forgeRepo) PRExists(_ context.Context, prID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.prs[prID]
+	return ok, nil
+}
+
+func (r *forgeRepo) CreatePullRequest(_ context.Context, pr *domain.PullRequest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *pr
+	copied.AssignedReviewers = slices.Clone(pr.AssignedReviewers)
+	r.prs[pr.ID] = &copied
+	r.created++
+	return nil
+}
+
+func (r *forgeRepo) GetPullRequestByID(_ context.Context, prID string) (*domain.PullRequest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pr, ok := r.prs[prID]
+	if !ok {
+		return nil, errors.New("pull request not found")
+	}
+	copied := *pr
+	copied.AssignedReviewers = slices.Clone(pr.AssignedReviewers)
+	return &copied, nil
+}
+
+func (r *forgeRepo) setStatus(prID, status string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pr, ok := r.prs[prID]
+	if !ok {
+		return errors.New("pull request not found")
+	}
+	pr.Status = status
+	return nil
+}
+
+func (r *forgeRepo) MergePullRequest(_ context.Context, prID string) error {
+	return r.setStatus(prID, "MERGED")
+}
+
+func (r *forgeRepo) ClosePullRequest(_ context.Context, prID string) error {
+	return r.setStatus(prID, "CLOSED")
+}
+
+func (r *forgeRepo) ReopenPullRequest(_ context.Context, prID string) error {
+	return r.setStatus(prID, "OPEN")
+}
+
+func (r *forgeRepo) GetPRReviewers(_ context.Context, prID string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pr, ok := r.prs[prID]
+	if !ok {
+		return nil, nil
+	}
+	return slices.Clone(pr.AssignedReviewers), nil
+}
+
+func (r *forgeRepo) GetExternalLogins(_ context.Context, provider string, userIDs []string) (map[string]string, error) {
+	logins := make(map[string]string)
+	for key, userID := range r.users {
+		if login, ok := strings.CutPrefix(key, provider+"/"); ok && slices.Contains(userIDs, userID) {
+			logins[userID] = login
+		}
+	}
+	return logins, nil
+}
+
+// fakeReviewerWriter запоминает обратную запись ревьюеров вместо вызова API внешней системы
+type fakeReviewerWriter struct {
+	mu    sync.Mutex
+	err   error
+	calls map[string][]string
+}
+
+func (w *fakeReviewerWriter) SetReviewers(_ context.Context, prID string, logins []string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.calls == nil {
+		w.calls = make(map[string][]string)
+	}
+	w.calls[prID] = slices.Clone(logins)
+	return w.err
+}
+
+func newGitHubRouter(repo storage.Repository) (*gin.Engine, *service.Service) {
+	gin.SetMode(gin.TestMode)
+	svc := service.NewService(repo, service.Config{ReviewersCount: 2})
+	router := gin.New()
+	router.POST("/integrations/github/webhook", NewHandler(svc).GitHubWebhook(githubSecret))
+	return router, svc
+}
+
+// loadGitHubPayload читает записанный вебхук из http/github
+func loadGitHubPayload(t *testing.T, name string) []byte {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("..", "..", "http", "github", name))
+	if err != nil {
+		t.Fatalf("read payload: %v", err)
+	}
+	return body
+}
+
+func signGitHub(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// sendGitHub отправляет вебхук так же, как http/github/replay.sh
+func sendGitHub(router http.Handler, deliveryID string, body []byte, signature string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/integrations/github/webhook", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", "pull_request")
+	req.Header.Set("X-GitHub-Delivery", deliveryID)
+	if signature != "" {
+		req.Header.Set("X-Hub-Signature-256", signature)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func decodeDelivery(t *testing.T, w *httptest.ResponseRecorder) *domain.IntegrationDelivery {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Delivery *domain.IntegrationDelivery `json:"delivery"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Delivery == nil {
+		t.Fatalf("decode response %s: %v", w.Body, err)
+	}
+	return resp.Delivery
+}
+
+func TestGitHubWebhookReplay(t *testing.T) {
+	repo := newForgeRepo()
+	router, _ := newGitHubRouter(repo)
+
+	steps := []struct {
+		payload    string
+		deliveryID string
+		wantAction string
+		wantResult string
+		wantStatus string
+		duplicate  bool
+	}{
+		{"pull_request_opened.json", "d-1", "opened", domain.IntegrationCreated, "OPEN", false},
+		// Повтор доставки GitHub с тем же X-GitHub-Delivery
+		{"pull_request_opened.json", "d-1", "opened", domain.IntegrationCreated, "OPEN", true},
+		// Новые коммиты не меняют PR в сервисе
+		{"pull_request_synchronize.json", "d-2", "synchronize", domain.IntegrationIgnored, "OPEN", false},
+		{"pull_request_closed_merged.json", "d-3", "closed", domain.IntegrationMerged, "MERGED", false},
+		{"pull_request_closed_merged.json", "d-3", "closed", domain.IntegrationMerged, "MERGED", true},
+	}
+
+	for _, step := range steps {
+		body := loadGitHubPayload(t, step.payload)
+		delivery := decodeDelivery(t, sendGitHub(router, step.deliveryID, body, signGitHub(githubSecret, body)))
+
+		if delivery.Action != step.wantAction || delivery.Result != step.wantResult || delivery.Duplicate != step.duplicate {
+			t.Errorf("%s (%s): action=%s result=%s duplicate=%v, want action=%s result=%s duplicate=%v",
+				step.payload, step.deliveryID, delivery.Action, delivery.Result, delivery.Duplicate,
+				step.wantAction, step.wantResult, step.duplicate)
+		}
+		// Проигнорированное событие не привязывается к PR
+		if step.wantResult == domain.IntegrationIgnored {
+			if delivery.PRID != nil {
+				t.Errorf("%s: pull_request_id = %s, want none", step.payload, *delivery.PRID)
+			}
+		} else if delivery.PRID == nil || *delivery.PRID != githubPRID {
+			t.Errorf("%s: pull_request_id = %v, want %s", step.payload, delivery.PRID, githubPRID)
+		}
+		pr, err := repo.GetPullRequestByID(context.Background(), githubPRID)
+		if err != nil {
+			t.Fatalf("%s: %v", step.payload, err)
+		}
+		if pr.Status != step.wantStatus {
+			t.Errorf("%s: PR status = %s, want %s", step.payload, pr.Status, step.wantStatus)
+		}
+	}
+
+	if repo.created != 1 {
+		t.Errorf("PR created %d times, want 1", repo.created)
+	}
+	pr, _ := repo.GetPullRequestByID(context.Background(), githubPRID)
+	if pr.AuthorId != "u1" || pr.Name != "Add search by skills" || pr.Provider != domain.ProviderGitHub {
+		t.Errorf("PR = %+v, want author u1, title from payload and provider github", pr)
+	}
+	if len(pr.AssignedReviewers) != 2 || slices.Contains(pr.AssignedReviewers, "u1") {
+		t.Errorf("reviewers = %v, want two reviewers without the author", pr.AssignedReviewers)
+	}
+}
+
+func TestGitHubWebhookClosed(t *testing.T) {
+	repo := newForgeRepo()
+	router, _ := newGitHubRouter(repo)
+
+	opened := loadGitHubPayload(t, "pull_request_opened.json")
+	decodeDelivery(t, sendGitHub(router, "d-1", opened, signGitHub(githubSecret, opened)))
+
+	closed := loadGitHubPayload(t, "pull_request_closed.json")
+	delivery := decodeDelivery(t, sendGitHub(router, "d-2", closed, signGitHub(githubSecret, closed)))
+	if delivery.Result != domain.IntegrationClosed {
+		t.Errorf("result = %s, want %s", delivery.Result, domain.IntegrationClosed)
+	}
+	if pr, _ := repo.GetPullRequestByID(context.Background(), githubPRID); pr.Status != "CLOSED" {
+		t.Errorf("PR status = %s, want CLOSED", pr.Status)
+	}
+
+	// Мердж после закрытия в сервисе открывает PR заново и мерджит его
+	merged := loadGitHubPayload(t, "pull_request_closed_merged.json")
+	delivery = decodeDelivery(t, sendGitHub(router, "d-3", merged, signGitHub(githubSecret, merged)))
+	if delivery.Result != domain.IntegrationMerged {
+		t.Errorf("result = %s, want %s", delivery.Result, domain.IntegrationMerged)
+	}
+}
+
+func TestGitHubWebhookSignature(t *testing.T) {
+	body := loadGitHubPayload(t, "pull_request_opened.json")
+	tampered := bytes.Replace(body, []byte("Add search by skills"), []byte("Drop all tables"), 1)
+
+	tests := []struct {
+		name      string
+		body      []byte
+		signature string
+	}{
+		{"missing", body, ""},
+		{"wrong secret", body, signGitHub("another-secret", body)},
+		{"tampered body", tampered, signGitHub(githubSecret, body)},
+		{"without prefix", body, signGitHub(githubSecret, body)[len("sha256="):]},
+		{"not hex", body, "sha256=zz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newForgeRepo()
+			router, _ := newGitHubRouter(repo)
+
+			w := sendGitHub(router, "d-1", tt.body, tt.signature)
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want 401: %s", w.Code, w.Body)
+			}
+			var resp struct {
+				Error struct {
+					Code string `json:"code"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Error.Code != "INVALID_SIGNATURE" {
+				t.Errorf("error = %s, want INVALID_SIGNATURE", w.Body)
+			}
+			if len(repo.prs) != 0 || len(repo.deliveries) != 0 {
+				t.Errorf("rejected webhook changed state: prs=%d deliveries=%d", len(repo.prs), len(repo.deliveries))
+			}
+		})
+	}
+}
+
+func TestGitHubWebhookWritesBackReviewers(t *testing.T) {
+	repo := newForgeRepo()
+	// u4 без привязки к логину GitHub: назначается, но в обратную запись не попадает
+	repo.candidates = []domain.ReviewerCandidate{
+		{User: domain.User{UserId: "u2", Username: "hubot", IsActive: true}, ReviewShare: 1},
+		{User: domain.User{UserId: "u4", Username: "unmapped", IsActive: true}, ReviewShare: 1},
+	}
+	router, svc := newGitHubRouter(repo)
+	writer := &fakeReviewerWriter{}
+	svc.SetReviewerWriter(domain.ProviderGitHub, writer)
+
+	body := loadGitHubPayload(t, "pull_request_opened.json")
+	delivery := decodeDelivery(t, sendGitHub(router, "d-1", body, signGitHub(githubSecret, body)))
+	if delivery.Result != domain.IntegrationCreated {
+		t.Fatalf("result = %s, want %s", delivery.Result, domain.IntegrationCreated)
+	}
+
+	pr, _ := repo.GetPullRequestByID(context.Background(), githubPRID)
+	if got := slices.Sorted(slices.Values(pr.AssignedReviewers)); !slices.Equal(got, []string{"u2", "u4"}) {
+		t.Fatalf("reviewers = %v, want [u2 u4]", got)
+	}
+	logins, ok := writer.calls[githubPRID]
+	if !ok {
+		t.Fatalf("reviewers were not written back, calls: %v", writer.calls)
+	}
+	if !slices.Equal(logins, []string{"hubot"}) {
+		t.Errorf("written back logins = %v, want [hubot]", logins)
+	}
+
+	// Повтор доставки не применяется и не пишет ревьюеров заново
+	delete(writer.calls, githubPRID)
+	decodeDelivery(t, sendGitHub(router, "d-1", body, signGitHub(githubSecret, body)))
+	if len(writer.calls) != 0 {
+		t.Errorf("duplicate delivery wrote back reviewers: %v", writer.calls)
+	}
+}
+
+func TestGitHubWebhookWriteBackFailure(t *testing.T) {
+	repo := newForgeRepo()
+	router, svc := newGitHubRouter(repo)
+	writer := &fakeReviewerWriter{err: errors.New("api unavailable")}
+	svc.SetReviewerWriter(domain.ProviderGitHub, writer)
+
+	body := loadGitHubPayload(t, "pull_request_opened.json")
+	delivery := decodeDelivery(t, sendGitHub(router, "d-1", body, signGitHub(githubSecret, body)))
+	if delivery.Result != domain.IntegrationCreated {
+		t.Errorf("result = %s, want %s", delivery.Result, domain.IntegrationCreated)
+	}
+	if _, ok := writer.calls[githubPRID]; !ok {
+		t.Error("writer was not called")
+	}
+	if _, err := repo.GetPullRequestByID(context.Background(), githubPRID); err != nil {
+		t.Errorf("PR was not created: %v", err)
+	}
+	// Доставка записана, поэтому повтор вебхука не создаст PR второй раз
+	if _, err := repo.GetIntegrationDelivery(context.Background(), domain.ProviderGitHub, "d-1"); err != nil {
+		t.Errorf("delivery was not recorded: %v", err)
+	}
+}
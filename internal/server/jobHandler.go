@@ -0,0 +1,74 @@
+package server
+
+import (
+	"avito-tech-internship/internal/domain"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+func (h *Handler) GetJobs(c *gin.Context) {
+	jobs, err := h.service.GetJobs(c.Request.Context())
+	if err != nil {
+		if err.Error() == "SCHEDULER_DISABLED" {
+			writeError(c, http.StatusServiceUnavailable, "SCHEDULER_DISABLED", "scheduler is disabled on this instance")
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"jobs": jobs,
+	})
+}
+
+func (h *Handler) TriggerJob(c *gin.Context) {
+	var req domain.TriggerJobRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+		return
+	}
+
+	run, err := h.service.TriggerJob(c.Request.Context(), req.Name)
+	if err != nil {
+		switch err.Error() {
+		case "JOB_NOT_FOUND":
+			writeError(c, http.StatusNotFound, "JOB_NOT_FOUND", "job not found")
+		case "JOB_RUNNING":
+			writeError(c, http.StatusConflict, "JOB_RUNNING", "job is already running")
+		case "SCHEDULER_DISABLED", "SCHEDULER_NOT_RUNNING":
+			writeError(c, http.StatusServiceUnavailable, err.Error(), "scheduler is not running on this instance")
+		default:
+			writeInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"run": run,
+	})
+}
+
+func (h *Handler) GetJobRuns(c *gin.Context) {
+	limit := 0
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			writeError(c, http.StatusBadRequest, "INVALID_INPUT", "limit must be a non-negative integer")
+			return
+		}
+		limit = parsed
+	}
+
+	runs, err := h.service.GetJobRuns(c.Request.Context(), c.Query("job"), limit)
+	if err != nil {
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"runs": runs,
+	})
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"avito-tech-internship/internal/domain"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+func (h *Handler) SetUserNotifications(c *gin.Context) {
+	var req domain.SetUserNotificationsRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+		return
+	}
+
+	err := h.service.SetUserNotifications(c.Request.Context(), &req)
+	if err != nil {
+		if err.Error() == "user not found" {
+			writeError(c, http.StatusNotFound, "NOT_FOUND", "user not found")
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":            req.UserID,
+		"chat_notifications": *req.Enabled,
+	})
+}
+
+func (h *Handler) SetTeamNotificationChannel(c *gin.Context) {
+	var req domain.SetTeamChannelRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+		return
+	}
+
+	channel, err := h.service.SetTeamNotificationChannel(c.Request.Context(), &req)
+	if err != nil {
+		switch err.Error() {
+		case "INVALID_URL":
+			writeError(c, http.StatusBadRequest, "INVALID_URL", "webhook_url must be an absolute http or https URL")
+		case "team not found":
+			writeError(c, http.StatusNotFound, "TEAM_NOT_FOUND", "team not found")
+		default:
+			writeInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"channel": channel,
+	})
+}
+
+func (h *Handler) GetTeamNotificationChannel(c *gin.Context) {
+	channel, err := h.service.GetTeamNotificationChannel(c.Request.Context(), c.Param("teamName"))
+	if err != nil {
+		if err.Error() == "channel not found" {
+			writeError(c, http.StatusNotFound, "NOT_FOUND", "team has no notification channel")
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"channel": channel,
+	})
+}
+
+func (h *Handler) SetUserEmail(c *gin.Context) {
+	var req domain.SetUserEmailRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+		return
+	}
+
+	err := h.service.SetUserEmail(c.Request.Context(), &req)
+	if err != nil {
+		switch err.Error() {
+		case "INVALID_EMAIL":
+			writeError(c, http.StatusBadRequest, "INVALID_EMAIL", "email must be a plain address like user@example.com")
+		case "user not found":
+			writeError(c, http.StatusNotFound, "NOT_FOUND", "user not found")
+		default:
+			writeInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id": req.UserID,
+		"email":   req.Email,
+		"digest":  req.Email != "" && (req.Digest == nil || *req.Digest),
+	})
+}
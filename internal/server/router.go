@@ -1,24 +1,62 @@
 package server
 
 import (
+	"avito-tech-internship/internal/background"
+	"avito-tech-internship/internal/config"
+	"avito-tech-internship/internal/digest"
+	"avito-tech-internship/internal/domain"
+	"avito-tech-internship/internal/eventstream"
+	"avito-tech-internship/internal/integration"
+	"avito-tech-internship/internal/metrics"
+	"avito-tech-internship/internal/migrate"
+	"avito-tech-internship/internal/notify"
+	"avito-tech-internship/internal/outbox"
+	"avito-tech-internship/internal/scheduler"
 	"avito-tech-internship/internal/service"
 	"avito-tech-internship/internal/storage"
+	"avito-tech-internship/internal/webhook"
+	"context"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"log/slog"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
 )
 
 type Server struct {
 	router *gin.Engine
 	db     *sqlx.DB
+	cfg    *config.Config
+	// Для сверки версии схемы в /readyz
+	migrator *migrate.Migrator
+	// Фоновые задачи, которые нужно дождаться при остановке
+	jobs *background.Group
+	// Выставляется при остановке, после этого /readyz отвечает 503
+	shuttingDown atomic.Bool
+	// Внутрипроцессная шина событий из outbox (sink bus)
+	bus *outbox.MemoryBus
+	// Отменяется в начале остановки, чтобы закрыть потоки /events/stream - иначе Shutdown ждал бы их до таймаута
+	streams     context.Context
+	stopStreams context.CancelFunc
 }
 
-func NewServer(db *sqlx.DB) *Server {
+func NewServer(db *sqlx.DB, cfg *config.Config, migrator *migrate.Migrator) *Server {
 	s := &Server{
-		router: gin.Default(),
-		db:     db,
+		router:   gin.Default(),
+		db:       db,
+		cfg:      cfg,
+		migrator: migrator,
+		jobs:     background.NewGroup(),
+		bus:      outbox.NewMemoryBus(),
 	}
+	s.streams, s.stopStreams = context.WithCancel(context.Background())
+	s.router.Use(otelgin.Middleware(cfg.Tracing.ServiceName), metrics.Middleware(), requestTimeout(cfg.Server.RequestTimeout), apiKeyAuth(cfg.Auth.APIKeys))
 	slog.Info("server initialized")
 	s.setupRouter()
 	return s
@@ -26,13 +64,38 @@ func NewServer(db *sqlx.DB) *Server {
 
 func (s *Server) setupRouter() {
 	repository := storage.NewPostgresRepository(s.db)
-	appService := service.NewService(repository)
+	metrics.RegisterTeamLoad(repository)
+	appService := service.NewService(repository, service.Config{
+		ReviewersCount:   s.cfg.Reviewers.Count,
+		SkillMatching:    s.cfg.Features.SkillMatching,
+		Codeowners:       s.cfg.Features.Codeowners,
+		PairingHistory:   s.cfg.Features.PairingHistory,
+		TeamEscalation:   s.cfg.Features.TeamEscalation,
+		ReviewSLA:        s.cfg.SLA.Default,
+		StaleMarkAfter:   s.cfg.Stale.MarkAfter,
+		StaleCloseAfter:  s.cfg.Stale.CloseAfter,
+		HistoryRetention: s.cfg.Scheduler.HistoryRetention,
+	})
+	if cfg := s.cfg.Integrations.GitLab; cfg.WriteBack {
+		appService.SetReviewerWriter(domain.ProviderGitLab, integration.NewGitLabClient(cfg.URL, cfg.APIToken, &http.Client{Timeout: cfg.Timeout}))
+	}
 	httpHandler := NewHandler(appService)
 
 	teams := s.router.Group("/team")
 	{
 		teams.POST("/add", httpHandler.createNewTeam)
 		teams.GET("/get/:teamName", httpHandler.GetTeamByName)
+		teams.POST("/addMember", httpHandler.AddTeamMember)
+		teams.POST("/removeMember", httpHandler.RemoveTeamMember)
+		teams.POST("/setParent", httpHandler.SetTeamParent)
+		teams.GET("/tree", httpHandler.GetTeamTree)
+		teams.POST("/setCodeowners", httpHandler.SetTeamCodeowners)
+		teams.GET("/codeowners/:teamName", httpHandler.GetTeamCodeowners)
+		teams.POST("/setPairingDepth", httpHandler.SetPairingHistoryDepth)
+		teams.POST("/setNotificationChannel", httpHandler.SetTeamNotificationChannel)
+		teams.GET("/notificationChannel/:teamName", httpHandler.GetTeamNotificationChannel)
+		teams.POST("/setReviewSLA", httpHandler.SetTeamReviewSLA)
+		teams.GET("/reviewSLA/:teamName", httpHandler.GetTeamReviewSLA)
 	}
 
 	users := s.router.Group("/users")
@@ -40,6 +103,13 @@ func (s *Server) setupRouter() {
 		users.POST("/addNew", httpHandler.AddNewUser)
 		users.GET("/getById/:id", httpHandler.GetUserByID)
 		users.POST("/setIsActive", httpHandler.SetUserActive)
+		users.POST("/setMaxOpenReviews", httpHandler.SetMaxOpenReviews)
+		users.POST("/addSkills", httpHandler.AddUserSkills)
+		users.POST("/removeSkills", httpHandler.RemoveUserSkills)
+		users.GET("/getSkills", httpHandler.GetUserSkills)
+		users.GET("/getReview", httpHandler.GetUserReview)
+		users.POST("/setNotifications", httpHandler.SetUserNotifications)
+		users.POST("/setEmail", httpHandler.SetUserEmail)
 	}
 
 	pullRequest := s.router.Group("/pullRequest")
@@ -47,15 +117,162 @@ func (s *Server) setupRouter() {
 		pullRequest.POST("/create", httpHandler.CreatePullRequest)
 		pullRequest.POST("/merge", httpHandler.MergePullRequest)
 		pullRequest.POST("/reassign", httpHandler.ReassignReviewer)
+		pullRequest.POST("/respond", httpHandler.RespondToReview)
+		pullRequest.GET("/overdue", httpHandler.GetOverdueReviews)
+		pullRequest.POST("/setStaleExempt", httpHandler.SetStaleExempt)
+		pullRequest.GET("/stale/dryRun", httpHandler.GetStaleDryRun)
 	}
 
 	stats := s.router.Group("/stats")
 	{
 		stats.GET("getAllStats", httpHandler.GetStats)
+		stats.GET("pairings", httpHandler.GetPairingStats)
+		stats.GET("sla", httpHandler.GetSLAStats)
+	}
+
+	webhooks := s.router.Group("/webhooks")
+	{
+		webhooks.POST("/subscribe", httpHandler.CreateWebhookSubscription)
+		webhooks.GET("/list", httpHandler.GetWebhookSubscriptions)
+		webhooks.POST("/unsubscribe", httpHandler.DeleteWebhookSubscription)
+		webhooks.GET("/deliveries", httpHandler.GetWebhookDeliveries)
+		webhooks.GET("/deliveries/:id", httpHandler.GetWebhookDelivery)
+		webhooks.GET("/deadLetters", httpHandler.GetWebhookDeadLetters)
+		webhooks.POST("/redeliver", httpHandler.RedeliverWebhook)
+	}
+
+	integrations := s.router.Group("/integrations")
+	{
+		if secret := s.cfg.Integrations.GitHub.WebhookSecret; secret != "" {
+			integrations.POST("/github/webhook", httpHandler.GitHubWebhook(secret))
+		}
+		if token := s.cfg.Integrations.GitLab.WebhookToken; token != "" {
+			integrations.POST("/gitlab/webhook", httpHandler.GitLabWebhook(token))
+		}
+		integrations.POST("/mappings/set", httpHandler.SetExternalUserMapping)
+		integrations.GET("/mappings/list", httpHandler.GetExternalUserMappings)
+		integrations.POST("/mappings/delete", httpHandler.DeleteExternalUserMapping)
+	}
+
+	if cfg := s.cfg.Webhooks; cfg.Enabled {
+		dispatcher := webhook.NewDispatcher(repository, &http.Client{}, webhook.Config{
+			PollInterval:   cfg.PollInterval,
+			BatchSize:      cfg.BatchSize,
+			MaxAttempts:    cfg.MaxAttempts,
+			InitialBackoff: cfg.InitialBackoff,
+			MaxBackoff:     cfg.MaxBackoff,
+			Timeout:        cfg.Timeout,
+		})
+		s.jobs.Go("webhook-dispatcher", dispatcher.Run)
+	}
+
+	if cfg := s.cfg.Outbox; len(cfg.Sinks) > 0 || s.cfg.Notifications.Enabled {
+		sinks := make([]outbox.Sink, 0, len(cfg.Sinks)+1)
+		for _, name := range cfg.Sinks {
+			switch name {
+			case "webhook":
+				sinks = append(sinks, outbox.NewWebhookSink(repository))
+			case "stdout":
+				sinks = append(sinks, outbox.NewWriterSink(os.Stdout))
+			case "bus":
+				sinks = append(sinks, outbox.NewBusSink(s.bus, cfg.BusSubjectPrefix))
+			}
+		}
+		if notifications := s.cfg.Notifications; notifications.Enabled {
+			sinks = append(sinks, notify.NewNotifier(repository, notify.Templates{
+				Assigned:   notifications.Templates.Assigned,
+				Reassigned: notifications.Templates.Reassigned,
+				Merged:     notifications.Templates.Merged,
+			}))
+		}
+		relay := outbox.NewRelay(repository, outbox.Config{
+			PollInterval:   cfg.PollInterval,
+			BatchSize:      cfg.BatchSize,
+			Lease:          cfg.Lease,
+			MaxAttempts:    cfg.MaxAttempts,
+			InitialBackoff: cfg.InitialBackoff,
+			MaxBackoff:     cfg.MaxBackoff,
+		}, sinks...)
+		s.jobs.Go("outbox-relay", relay.Run)
+	}
+
+	if cfg := s.cfg.Notifications; cfg.Enabled {
+		dispatcher := notify.NewDispatcher(repository, notify.NewWebhookSender(&http.Client{}), notify.DispatcherConfig{
+			PollInterval:   cfg.PollInterval,
+			BatchSize:      cfg.BatchSize,
+			MaxAttempts:    cfg.MaxAttempts,
+			InitialBackoff: cfg.InitialBackoff,
+			MaxBackoff:     cfg.MaxBackoff,
+			Timeout:        cfg.Timeout,
+		})
+		s.jobs.Go("chat-dispatcher", dispatcher.Run)
+	}
+
+	if cfg := s.cfg.Scheduler; cfg.Enabled {
+		location, _ := time.LoadLocation(cfg.Timezone)
+		jobScheduler := scheduler.New(repository, location)
+		register := func(name, spec string, fn func(ctx context.Context) error) {
+			// Расписания проверены при загрузке конфигурации
+			if err := jobScheduler.Register(name, spec, fn); err != nil {
+				slog.Error("could not register job", "job", name, "error", err)
+			}
+		}
+
+		if spec := s.cfg.SLA.Schedule; spec != "" {
+			register("sla-scanner", spec, appService.ScanReviewSLA)
+		}
+		if stale := s.cfg.Stale; stale.Enabled {
+			register("stale-prs", stale.Schedule, appService.ScanStalePullRequests)
+		}
+		if digestCfg := s.cfg.Digest; digestCfg.Enabled {
+			digestLocation, _ := time.LoadLocation(digestCfg.Timezone)
+			mailer := digest.NewSMTPMailer(digest.SMTPConfig{
+				Addr:     digestCfg.SMTP.Addr,
+				Username: digestCfg.SMTP.Username,
+				Password: digestCfg.SMTP.Password,
+				From:     digestCfg.SMTP.From,
+				Timeout:  digestCfg.SMTP.Timeout,
+			})
+			job := digest.NewJob(repository, mailer, digest.Config{
+				Location: digestLocation,
+				Subject:  digestCfg.Subject,
+				Body:     digestCfg.Body,
+			})
+			at, _ := time.Parse("15:04", digestCfg.SendAt)
+			register("email-digest", fmt.Sprintf("CRON_TZ=%s %d %d * * *", digestCfg.Timezone, at.Minute(), at.Hour()), job.Run)
+		}
+		if spec := cfg.CleanupSchedule; spec != "" {
+			register("cleanup", spec, appService.CleanupHistory)
+		}
+
+		appService.SetScheduler(jobScheduler)
+		s.jobs.Go("scheduler", jobScheduler.Run)
+	} else if s.cfg.SLA.Schedule != "" || s.cfg.Stale.Enabled || s.cfg.Digest.Enabled {
+		slog.Warn("periodic jobs are configured but the scheduler is disabled in this instance, set SCHEDULER_ENABLED=true to run them")
+	}
+
+	if cfg := s.cfg.Events; cfg.StreamEnabled {
+		watcher := eventstream.NewWatcher(repository, cfg.PollInterval)
+		// С sink bus потоки просыпаются сразу после публикации события relay этой реплики
+		s.bus.Subscribe(s.cfg.Outbox.BusSubjectPrefix+">", func(string, []byte) { watcher.Wake() })
+		s.jobs.Go("event-stream-watcher", watcher.Run)
+		s.router.GET("/events/stream", httpHandler.StreamEvents(watcher, cfg.HeartbeatInterval, s.streams.Done()))
 	}
 
+	admin := s.router.Group("/admin")
+	{
+		admin.GET("/jobs", httpHandler.GetJobs)
+		admin.POST("/jobs/trigger", httpHandler.TriggerJob)
+		admin.GET("/jobs/runs", httpHandler.GetJobRuns)
+	}
+
+	s.router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	s.router.GET("/livez", s.livez)
+	s.router.GET("/readyz", s.readyz)
+
 	s.router.GET("/health", func(c *gin.Context) {
-		if err := s.db.Ping(); err != nil {
+		if err := s.db.PingContext(c.Request.Context()); err != nil {
 			c.JSON(500, gin.H{"status": "unhealthy"})
 			return
 		}
@@ -63,6 +280,76 @@ func (s *Server) setupRouter() {
 	})
 }
 
-func (s *Server) Start() {
-	s.router.Run(":8080")
+// Долгие потоки, на которые не действует server.request_timeout
+var streamingPaths = map[string]bool{
+	"/events/stream": true,
+}
+
+// requestTimeout ограничивает контекст запроса: по истечении времени запросы в БД отменяются,
+// а хендлер отвечает 504 с кодом TIMEOUT
+func requestTimeout(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if timeout <= 0 || streamingPaths[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// Run обслуживает запросы до отмены ctx (SIGTERM/SIGINT), затем перестает принимать
+// новые соединения, дожидается текущих запросов и фоновых задач.
+// Пул соединений с БД закрывает вызывающий после возврата из Run.
+func (s *Server) Run(ctx context.Context) error {
+	cfg := s.cfg.Server
+	httpServer := &http.Server{
+		Addr:              cfg.ListenAddr,
+		Handler:           s.router,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+	httpServer.RegisterOnShutdown(s.stopStreams)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("server listening", "addr", cfg.ListenAddr)
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		// Сервер не запустился (например, порт занят) - останавливаем фоновые задачи и выходим
+		stopCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		_ = s.jobs.Stop(stopCtx)
+		return fmt.Errorf("http server: %w", err)
+	case <-ctx.Done():
+	}
+
+	// Сначала снимаем готовность, чтобы балансировщик успел перестать слать запросы
+	s.shuttingDown.Store(true)
+	if cfg.ShutdownDelay > 0 {
+		slog.Info("readiness set to not ready, waiting before shutdown", "delay", cfg.ShutdownDelay)
+		time.Sleep(cfg.ShutdownDelay)
+	}
+
+	slog.Info("shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	var shutdownErr error
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		shutdownErr = fmt.Errorf("http server shutdown: %w", err)
+	}
+	if err := s.jobs.Stop(shutdownCtx); err != nil {
+		shutdownErr = errors.Join(shutdownErr, fmt.Errorf("background jobs shutdown: %w", err))
+	}
+	slog.Info("server stopped")
+	return shutdownErr
 }
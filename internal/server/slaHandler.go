@@ -0,0 +1,118 @@
+package server
+
+import (
+	"avito-tech-internship/internal/domain"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+const defaultSLAStatsDays = 30
+
+func (h *Handler) SetTeamReviewSLA(c *gin.Context) {
+	var req domain.SetTeamReviewSLARequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+		return
+	}
+
+	sla, err := h.service.SetTeamReviewSLA(c.Request.Context(), &req)
+	if err != nil {
+		switch err.Error() {
+		case "INVALID_SLA":
+			writeError(c, http.StatusBadRequest, "INVALID_SLA", "sla must be a duration between 1m and 720h, e.g. \"24h\"")
+		case "team not found":
+			writeError(c, http.StatusNotFound, "TEAM_NOT_FOUND", "team not found")
+		default:
+			writeInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"review_sla": sla,
+	})
+}
+
+func (h *Handler) GetTeamReviewSLA(c *gin.Context) {
+	sla, err := h.service.GetTeamReviewSLA(c.Request.Context(), c.Param("teamName"))
+	if err != nil {
+		if err.Error() == "team not found" {
+			writeError(c, http.StatusNotFound, "TEAM_NOT_FOUND", "team not found")
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"review_sla": sla,
+	})
+}
+
+func (h *Handler) RespondToReview(c *gin.Context) {
+	var req domain.RespondReviewRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+		return
+	}
+
+	pr, err := h.service.RespondToReview(c.Request.Context(), &req)
+	if err != nil {
+		switch err.Error() {
+		case "PR_NOT_OPEN":
+			writeError(c, http.StatusConflict, "PR_NOT_OPEN", "pull request is not open")
+		case "reviewer not assigned to this PR":
+			writeError(c, http.StatusConflict, "NOT_ASSIGNED", "reviewer is not assigned to this PR")
+		case "pull request not found":
+			writeError(c, http.StatusNotFound, "NOT_FOUND", "pull request not found")
+		default:
+			writeInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"pr": pr,
+	})
+}
+
+func (h *Handler) GetOverdueReviews(c *gin.Context) {
+	reviews, err := h.service.GetOverdueReviews(c.Request.Context(), c.Query("team_name"))
+	if err != nil {
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"overdue": reviews,
+	})
+}
+
+func (h *Handler) GetSLAStats(c *gin.Context) {
+	days := defaultSLAStatsDays
+	if raw := c.Query("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			writeError(c, http.StatusBadRequest, "INVALID_PERIOD", "days must be an integer between 1 and 365")
+			return
+		}
+		days = parsed
+	}
+
+	stats, err := h.service.GetSLAStats(c.Request.Context(), days)
+	if err != nil {
+		if err.Error() == "INVALID_PERIOD" {
+			writeError(c, http.StatusBadRequest, "INVALID_PERIOD", "days must be an integer between 1 and 365")
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sla": stats,
+	})
+}
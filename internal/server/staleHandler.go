@@ -0,0 +1,47 @@
+package server
+
+import (
+	"avito-tech-internship/internal/domain"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+func (h *Handler) SetStaleExempt(c *gin.Context) {
+	var req domain.SetStaleExemptRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+		return
+	}
+
+	pr, err := h.service.SetStaleExempt(c.Request.Context(), &req)
+	if err != nil {
+		switch err.Error() {
+		case "NOT_AUTHOR":
+			writeError(c, http.StatusForbidden, "NOT_AUTHOR", "only the author can exempt a PR")
+		case "PR_NOT_OPEN":
+			writeError(c, http.StatusConflict, "PR_NOT_OPEN", "pull request is not open")
+		case "pull request not found":
+			writeError(c, http.StatusNotFound, "NOT_FOUND", "pull request not found")
+		default:
+			writeInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"pr": pr,
+	})
+}
+
+func (h *Handler) GetStaleDryRun(c *gin.Context) {
+	report, err := h.service.GetStaleDryRun(c.Request.Context())
+	if err != nil {
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"stale": report,
+	})
+}
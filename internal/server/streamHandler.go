@@ -0,0 +1,161 @@
+package server
+
+import (
+	"avito-tech-internship/internal/domain"
+	"avito-tech-internship/internal/eventstream"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Через сколько перечитать журнал, если чтение остановилось на пропуске в id
+const streamPendingRetry = 500 * time.Millisecond
+
+// Событие SSE, которое получает клиент, если события после его Last-Event-ID уже удалены из журнала
+const streamResetEvent = "stream.reset"
+
+// StreamEvents отдает события PR в формате Server-Sent Events. id события SSE - id записи outbox,
+// поэтому после переподключения с Last-Event-ID поток продолжается без пропусков.
+// Поток закрывается при отключении клиента или при закрытии done (остановка сервера)
+func (h *Handler) StreamEvents(watcher *eventstream.Watcher, heartbeat time.Duration, done <-chan struct{}) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := domain.StreamFilter{
+			TeamName: c.Query("team_name"),
+			UserID:   c.Query("user_id"),
+		}
+		if types := c.Query("types"); types != "" {
+			filter.Types = strings.Split(types, ",")
+		}
+
+		var lastEventID *int64
+		raw := c.GetHeader("Last-Event-ID")
+		if raw == "" {
+			raw = c.Query("last_event_id")
+		}
+		if raw != "" {
+			id, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				writeError(c, http.StatusBadRequest, "INVALID_EVENT_ID", "Last-Event-ID must be an event id from this stream")
+				return
+			}
+			lastEventID = &id
+		}
+
+		ctx := c.Request.Context()
+		start, err := h.service.OpenEventStream(ctx, filter, lastEventID)
+		if err != nil {
+			switch err.Error() {
+			case "INVALID_EVENTS":
+				writeErrorDetails(c, http.StatusBadRequest, "INVALID_EVENTS", "types must be a comma-separated list of known event types", domain.EventTypes)
+			case "INVALID_EVENT_ID":
+				writeError(c, http.StatusBadRequest, "INVALID_EVENT_ID", "Last-Event-ID must be an event id from this stream")
+			case "team not found":
+				writeError(c, http.StatusNotFound, "TEAM_NOT_FOUND", "team not found")
+			case "NOT_FOUND":
+				writeError(c, http.StatusNotFound, "NOT_FOUND", "user not found")
+			default:
+				writeInternalError(c, err)
+			}
+			return
+		}
+		cursor := start.Cursor
+
+		wake, unsubscribe := watcher.Subscribe()
+		defer unsubscribe()
+
+		// Поток живет дольше server.write_timeout
+		if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+			slog.Warn("could not disable write deadline for event stream", "error", err)
+		}
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		// Иначе nginx буферизует ответ
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		if _, err := c.Writer.WriteString(": connected\n\n"); err != nil {
+			return
+		}
+		if start.Reset {
+			// События после Last-Event-ID удалены очисткой: клиент должен перечитать состояние через API.
+			// id события сдвигает Last-Event-ID клиента, чтобы при переподключении сброс не повторялся
+			c.Render(-1, sse.Event{
+				Id:    strconv.FormatInt(cursor, 10),
+				Event: streamResetEvent,
+				Data:  gin.H{"last_event_id": *lastEventID},
+			})
+		}
+		c.Writer.Flush()
+
+		// id, который клиент пришлет в Last-Event-ID при переподключении
+		sentID := cursor
+		if lastEventID != nil && !start.Reset {
+			sentID = *lastEventID
+		}
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+
+		var retry <-chan time.Time
+		read := true
+		for {
+			if read {
+				page, err := h.service.ReadEventLog(ctx, cursor, filter)
+				if err != nil {
+					if ctx.Err() == nil {
+						slog.Error("could not read event log", "cursor", cursor, "error", err)
+					}
+					return
+				}
+				for _, event := range page.Events {
+					c.Render(-1, sse.Event{
+						Id:    strconv.FormatInt(event.ID, 10),
+						Event: event.EventType,
+						Data:  event.Payload,
+					})
+				}
+				if len(page.Events) > 0 {
+					c.Writer.Flush()
+					sentID = page.Events[len(page.Events)-1].ID
+				}
+				cursor = page.Cursor
+
+				retry = nil
+				if page.Pending {
+					retry = time.After(streamPendingRetry)
+				}
+				if read = page.More; read {
+					continue
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			case <-wake:
+				read = true
+			case <-retry:
+				read = true
+			case <-ticker.C:
+				// Комментарий не виден клиенту, но не дает прокси закрыть соединение.
+				// Если после последнего отправленного события журнал прочитан дальше (события не прошли фильтр),
+				// id без данных сдвигает Last-Event-ID клиента, не создавая события
+				message := ": heartbeat\n\n"
+				if cursor > sentID {
+					message = ": heartbeat\nid: " + strconv.FormatInt(cursor, 10) + "\n\n"
+					sentID = cursor
+				}
+				if _, err := c.Writer.WriteString(message); err != nil {
+					return
+				}
+				c.Writer.Flush()
+			}
+		}
+	}
+}
@@ -0,0 +1,434 @@
+package server
+
+import (
+	"avito-tech-internship/internal/domain"
+	"avito-tech-internship/internal/eventstream"
+	"avito-tech-internship/internal/service"
+	"avito-tech-internship/internal/storage"
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type loggedEvent struct {
+	id           int64
+	eventType    string
+	participants []string
+}
+
+// streamRepo - журнал outbox в памяти. Остальные методы storage.Repository
+// в этих сценариях не вызываются, обращение к ним завершит тест паникой
+type streamRepo struct {
+	storage.Repository
+
+	mu     sync.Mutex
+	events []loggedEvent
+	// id следующего события, как последовательность outbox
+	next  int64
+	teams map[string][]string
+	users map[string]bool
+}
+
+func newStreamRepo() *streamRepo {
+	return &streamRepo{
+		next:  1,
+		teams: map[string][]string{"backend": {"u3"}},
+		users: map[string]bool{"u1": true, "u2": true, "u3": true},
+	}
+}
+
+func (r *streamRepo) add(eventType string, participants ...string) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := r.next
+	r.next++
+	r.events = append(r.events, loggedEvent{id: id, eventType: eventType, participants: participants})
+	return id
+}
+
+// purge удаляет события с id меньше before, как очистка истории
+func (r *streamRepo) purge(before int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = slices.DeleteFunc(r.events, func(e loggedEvent) bool { return e.id < before })
+}
+
+func (r *streamRepo) GetUserByID(_ context.Context, userID string) (*domain.User, error) {
+	if !r.users[userID] {
+		return nil, sql.ErrNoRows
+	}
+	return &domain.User{UserId: userID}, nil
+}
+
+func (r *streamRepo) GetTeamByName(_ context.Context, teamName string) (*domain.Team, error) {
+	if _, ok := r.teams[teamName]; !ok {
+		return nil, errors.New("team not found")
+	}
+	return &domain.Team{TeamName: teamName}, nil
+}
+
+func (r *streamRepo) GetLatestOutboxID(context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.events) == 0 {
+		return 0, nil
+	}
+	return r.events[len(r.events)-1].id, nil
+}
+
+func (r *streamRepo) GetOutboxIDBounds(context.Context) (int64, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.events) == 0 {
+		return r.next, r.next, nil
+	}
+	return r.events[0].id, r.next, nil
+}
+
+func (r *streamRepo) GetStreamEvents(_ context.Context, afterID int64, filter domain.StreamFilter, limit int, _ time.Duration) ([]*domain.StreamEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := []*domain.StreamEvent{}
+	for _, e := range r.events {
+		if e.id <= afterID {
+			continue
+		}
+		if len(events) == limit {
+			break
+		}
+		matches := len(filter.Types) == 0 || slices.Contains(filter.Types, e.eventType)
+		if filter.UserID != "" {
+			matches = matches && slices.Contains(e.participants, filter.UserID)
+		}
+		if filter.TeamName != "" {
+			matches = matches && slices.ContainsFunc(e.participants, func(userID string) bool {
+				return slices.Contains(r.teams[filter.TeamName], userID)
+			})
+		}
+		events = append(events, &domain.StreamEvent{
+			ID:        e.id,
+			EventType: e.eventType,
+			Payload:   json.RawMessage(fmt.Sprintf(`{"id":"evt-%d"}`, e.id)),
+			Matches:   matches,
+			Settled:   true,
+		})
+	}
+	return events, nil
+}
+
+// sseFrame - один блок потока до пустой строки
+type sseFrame struct {
+	id      string
+	event   string
+	data    string
+	comment string
+}
+
+type streamClient struct {
+	frames chan sseFrame
+}
+
+// streamTestServer запускает /events/stream с heartbeat и будильником watcher, которого тест будит сам
+func streamTestServer(t *testing.T, repo *streamRepo, heartbeat time.Duration) (*httptest.Server, *eventstream.Watcher) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	watcher := eventstream.NewWatcher(repo, time.Hour)
+	done := make(chan struct{})
+	handler := NewHandler(service.NewService(repo, service.Config{ReviewersCount: 2}))
+	router := gin.New()
+	router.GET("/events/stream", handler.StreamEvents(watcher, heartbeat, done))
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	// Выполняется раньше server.Close: открытые потоки закрываются, как при остановке сервиса
+	t.Cleanup(func() { close(done) })
+	return server, watcher
+}
+
+func openStream(t *testing.T, url, lastEventID string) *streamClient {
+	t.Helper()
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		request.Header.Set("Last-Event-ID", lastEventID)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	t.Cleanup(func() { response.Body.Close() })
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", response.StatusCode)
+	}
+
+	client := &streamClient{frames: make(chan sseFrame, 100)}
+	go func() {
+		scanner := bufio.NewScanner(response.Body)
+		var frame sseFrame
+		for scanner.Scan() {
+			line := scanner.Text()
+			if line == "" {
+				client.frames <- frame
+				frame = sseFrame{}
+				continue
+			}
+			name, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+			switch name {
+			case "":
+				frame.comment = value
+			case "id":
+				frame.id = value
+			case "event":
+				frame.event = value
+			case "data":
+				frame.data = value
+			}
+		}
+		close(client.frames)
+	}()
+
+	if frame := client.next(t); frame.comment != "connected" {
+		t.Fatalf("first frame = %+v, want connected comment", frame)
+	}
+	return client
+}
+
+func (c *streamClient) next(t *testing.T) sseFrame {
+	t.Helper()
+	select {
+	case frame, ok := <-c.frames:
+		if !ok {
+			t.Fatal("stream closed")
+		}
+		return frame
+	case <-time.After(2 * time.Second):
+		t.Fatal("no frame within 2s")
+	}
+	return sseFrame{}
+}
+
+// eventIDs читает события до первого heartbeat и возвращает их id
+func (c *streamClient) eventIDs(t *testing.T) []string {
+	t.Helper()
+	ids := []string{}
+	for {
+		frame := c.next(t)
+		if frame.comment == "heartbeat" {
+			return ids
+		}
+		ids = append(ids, frame.id)
+	}
+}
+
+func TestStreamEventsResume(t *testing.T) {
+	repo := newStreamRepo()
+	for range 3 {
+		repo.add(domain.EventPRCreated, "u1")
+	}
+	server, watcher := streamTestServer(t, repo, time.Hour)
+
+	stream := openStream(t, server.URL+"/events/stream", "1")
+	for _, want := range []string{"2", "3"} {
+		frame := stream.next(t)
+		if frame.id != want || frame.event != domain.EventPRCreated {
+			t.Fatalf("frame = %+v, want %s event %s", frame, domain.EventPRCreated, want)
+		}
+		if wantData := `{"id":"evt-` + want + `"}`; frame.data != wantData {
+			t.Errorf("data = %s, want %s", frame.data, wantData)
+		}
+	}
+
+	repo.add(domain.EventPRMerged, "u1")
+	watcher.Wake()
+	if frame := stream.next(t); frame.id != "4" || frame.event != domain.EventPRMerged {
+		t.Fatalf("frame after wake = %+v, want pr.merged 4", frame)
+	}
+}
+
+func TestStreamEventsStartsWithNewEvents(t *testing.T) {
+	repo := newStreamRepo()
+	repo.add(domain.EventPRCreated, "u1")
+	repo.add(domain.EventPRMerged, "u1")
+	server, watcher := streamTestServer(t, repo, time.Hour)
+
+	stream := openStream(t, server.URL+"/events/stream", "")
+	repo.add(domain.EventPRReopened, "u1")
+	watcher.Wake()
+	if frame := stream.next(t); frame.id != "3" {
+		t.Fatalf("frame = %+v, want only the new event 3", frame)
+	}
+}
+
+func TestStreamEventsFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{name: "no filter", query: "", want: []string{"1", "2", "3", "4", "5"}},
+		{name: "types", query: "types=pr.merged,user.deactivated", want: []string{"3", "4", "5"}},
+		{name: "user", query: "user_id=u2", want: []string{"1", "3"}},
+		{name: "team", query: "team_name=backend", want: []string{"2", "5"}},
+		{name: "types and user", query: "types=pr.merged&user_id=u2", want: []string{"3"}},
+		{name: "nothing matches", query: "types=pr.stale", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newStreamRepo()
+			repo.add(domain.EventPRCreated, "u1", "u2")
+			repo.add(domain.EventReviewerAssigned, "u1", "u3")
+			repo.add(domain.EventPRMerged, "u1", "u2")
+			repo.add(domain.EventPRMerged, "u4", "u5")
+			repo.add(domain.EventUserDeactivated, "u3")
+			server, _ := streamTestServer(t, repo, 50*time.Millisecond)
+
+			stream := openStream(t, server.URL+"/events/stream?last_event_id=0&"+tt.query, "")
+			if got := stream.eventIDs(t); !slices.Equal(got, tt.want) {
+				t.Errorf("events = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamEventsHeartbeat(t *testing.T) {
+	repo := newStreamRepo()
+	repo.add(domain.EventPRCreated, "u1")
+	repo.add(domain.EventPRMerged, "u1")
+	repo.add(domain.EventPRCreated, "u1")
+	server, watcher := streamTestServer(t, repo, 50*time.Millisecond)
+
+	stream := openStream(t, server.URL+"/events/stream?types=pr.merged", "0")
+	if frame := stream.next(t); frame.id != "2" {
+		t.Fatalf("frame = %+v, want event 2", frame)
+	}
+	// Событие 3 не прошло фильтр, heartbeat сдвигает Last-Event-ID клиента на него
+	if frame := stream.next(t); frame.comment != "heartbeat" || frame.id != "3" || frame.data != "" {
+		t.Fatalf("frame = %+v, want heartbeat with id 3", frame)
+	}
+	if frame := stream.next(t); frame.comment != "heartbeat" || frame.id != "" {
+		t.Fatalf("frame = %+v, want heartbeat without id", frame)
+	}
+
+	repo.add(domain.EventPRMerged, "u1")
+	watcher.Wake()
+	for {
+		frame := stream.next(t)
+		if frame.comment == "heartbeat" {
+			continue
+		}
+		if frame.id != "4" {
+			t.Fatalf("frame = %+v, want event 4", frame)
+		}
+		break
+	}
+}
+
+func TestStreamEventsReset(t *testing.T) {
+	tests := []struct {
+		name        string
+		lastEventID string
+		wantReset   bool
+		want        []string
+	}{
+		{name: "resume from the first retained event", lastEventID: "4", want: []string{"5", "6"}},
+		{name: "resume right before the retained events", lastEventID: "3", want: []string{"4", "5", "6"}},
+		{name: "events after id were purged", lastEventID: "2", wantReset: true, want: []string{}},
+		{name: "id from another journal", lastEventID: "100", wantReset: true, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newStreamRepo()
+			for range 6 {
+				repo.add(domain.EventPRCreated, "u1")
+			}
+			repo.purge(4)
+			server, watcher := streamTestServer(t, repo, 50*time.Millisecond)
+
+			stream := openStream(t, server.URL+"/events/stream", tt.lastEventID)
+			if tt.wantReset {
+				frame := stream.next(t)
+				if frame.event != streamResetEvent || frame.id != "6" {
+					t.Fatalf("frame = %+v, want %s with id 6", frame, streamResetEvent)
+				}
+				if want := `{"last_event_id":` + tt.lastEventID + `}`; frame.data != want {
+					t.Errorf("reset data = %s, want %s", frame.data, want)
+				}
+			}
+			if got := stream.eventIDs(t); !slices.Equal(got, tt.want) {
+				t.Fatalf("events = %v, want %v", got, tt.want)
+			}
+
+			repo.add(domain.EventPRMerged, "u1")
+			watcher.Wake()
+			if got := stream.eventIDs(t); !slices.Equal(got, []string{"7"}) {
+				t.Errorf("events after reconnect point = %v, want [7]", got)
+			}
+		})
+	}
+}
+
+func TestStreamEventsErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name        string
+		query       string
+		lastEventID string
+		wantStatus  int
+		wantCode    string
+	}{
+		{name: "unknown type", query: "types=pr.created,pr.unknown", wantStatus: http.StatusBadRequest, wantCode: "INVALID_EVENTS"},
+		{name: "unknown team", query: "team_name=frontend", wantStatus: http.StatusNotFound, wantCode: "TEAM_NOT_FOUND"},
+		{name: "unknown user", query: "user_id=u9", wantStatus: http.StatusNotFound, wantCode: "NOT_FOUND"},
+		{name: "not a number", lastEventID: "abc", wantStatus: http.StatusBadRequest, wantCode: "INVALID_EVENT_ID"},
+		{name: "negative id", lastEventID: "-1", wantStatus: http.StatusBadRequest, wantCode: "INVALID_EVENT_ID"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newStreamRepo()
+			handler := NewHandler(service.NewService(repo, service.Config{ReviewersCount: 2}))
+			router := gin.New()
+			router.GET("/events/stream", handler.StreamEvents(eventstream.NewWatcher(repo, time.Hour), time.Hour, nil))
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, "/events/stream?"+tt.query, nil)
+			if tt.lastEventID != "" {
+				request.Header.Set("Last-Event-ID", tt.lastEventID)
+			}
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			var response struct {
+				Error struct {
+					Code string `json:"code"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if response.Error.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", response.Error.Code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"avito-tech-internship/internal/domain"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+func (h *Handler) CreateWebhookSubscription(c *gin.Context) {
+	var req domain.CreateWebhookRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+		return
+	}
+
+	sub, err := h.service.CreateWebhookSubscription(c.Request.Context(), &req)
+	if err != nil {
+		switch err.Error() {
+		case "INVALID_URL":
+			writeError(c, http.StatusBadRequest, "INVALID_URL", "url must be an absolute http or https URL")
+		case "INVALID_EVENTS":
+			writeErrorDetails(c, http.StatusBadRequest, "INVALID_EVENTS", "events must be a non-empty list of known event types", domain.EventTypes)
+		default:
+			writeInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"subscription": sub,
+	})
+}
+
+func (h *Handler) GetWebhookSubscriptions(c *gin.Context) {
+	subs, err := h.service.GetWebhookSubscriptions(c.Request.Context())
+	if err != nil {
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"subscriptions": subs,
+	})
+}
+
+func (h *Handler) DeleteWebhookSubscription(c *gin.Context) {
+	var req domain.WebhookIDRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+		return
+	}
+
+	err := h.service.DeleteWebhookSubscription(c.Request.Context(), req.ID)
+	if err != nil {
+		if err.Error() == "subscription not found" {
+			writeError(c, http.StatusNotFound, "NOT_FOUND", "subscription not found")
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id": req.ID,
+	})
+}
+
+func (h *Handler) GetWebhookDeliveries(c *gin.Context) {
+	filter := domain.WebhookDeliveryFilter{
+		SubscriptionID: c.Query("subscription_id"),
+		Status:         c.Query("status"),
+	}
+	h.listWebhookDeliveries(c, filter)
+}
+
+// Dead-letter список - доставки, исчерпавшие попытки
+func (h *Handler) GetWebhookDeadLetters(c *gin.Context) {
+	filter := domain.WebhookDeliveryFilter{
+		SubscriptionID: c.Query("subscription_id"),
+		Status:         domain.DeliveryDead,
+	}
+	h.listWebhookDeliveries(c, filter)
+}
+
+func (h *Handler) listWebhookDeliveries(c *gin.Context, filter domain.WebhookDeliveryFilter) {
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			writeError(c, http.StatusBadRequest, "INVALID_INPUT", "limit must be a number")
+			return
+		}
+		filter.Limit = n
+	}
+
+	deliveries, err := h.service.GetWebhookDeliveries(c.Request.Context(), filter)
+	if err != nil {
+		if err.Error() == "INVALID_STATUS" {
+			writeError(c, http.StatusBadRequest, "INVALID_STATUS", "status must be one of: pending, delivered, dead")
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries": deliveries,
+	})
+}
+
+func (h *Handler) GetWebhookDelivery(c *gin.Context) {
+	delivery, err := h.service.GetWebhookDelivery(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if err.Error() == "delivery not found" {
+			writeError(c, http.StatusNotFound, "NOT_FOUND", "delivery not found")
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"delivery": delivery,
+	})
+}
+
+func (h *Handler) RedeliverWebhook(c *gin.Context) {
+	var req domain.WebhookIDRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+		return
+	}
+
+	delivery, err := h.service.RedeliverWebhook(c.Request.Context(), req.ID)
+	if err != nil {
+		if err.Error() == "delivery not found" {
+			writeError(c, http.StatusNotFound, "NOT_FOUND", "delivery not found")
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"delivery": delivery,
+	})
+}
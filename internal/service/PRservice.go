@@ -1,15 +1,29 @@
 package service
 
 import (
+	"avito-tech-internship/internal/codeowners"
 	"avito-tech-internship/internal/domain"
+	"avito-tech-internship/internal/metrics"
+	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"math"
 	"math/rand"
+	"path"
+	"slices"
+	"sort"
+	"strings"
 )
 
-func (s *Service) CreatePullRequest(req *domain.CreatePRRequest) (*domain.PullRequest, error) {
+const maxTagLength = 64
+
+func (s *Service) CreatePullRequest(ctx context.Context, req *domain.CreatePRRequest) (*domain.PullRequest, error) {
+	ctx, span := tracer.Start(ctx, "Service.CreatePullRequest")
+	defer span.End()
+
 	// Проверяем существование PR
-	exists, err := s.repo.PRExists(req.PRID)
+	exists, err := s.repo.PRExists(ctx, req.PRID)
 	if err != nil {
 		return nil, err
 	}
@@ -17,46 +31,74 @@ func (s *Service) CreatePullRequest(req *domain.CreatePRRequest) (*domain.PullRe
 		return nil, errors.New("PR_EXISTS")
 	}
 
-	// Проверяем существование автора и получаем его команду
-	authorTeamID, err := s.repo.GetAuthorTeam(req.AuthorID)
+	// Проверяем существование автора и получаем его команды
+	authorTeamIDs, err := s.repo.GetUserTeamIDs(ctx, req.AuthorID)
 	if err != nil {
-		return nil, fmt.Errorf("author not found")
+		if err.Error() == "user not found" {
+			return nil, fmt.Errorf("author not found")
+		}
+		return nil, err
 	}
 
-	// Создаем PR
-	pr := &domain.PullRequest{
-		ID:       req.PRID,
-		Name:     req.Name,
-		AuthorId: req.AuthorID,
-		Status:   "OPEN",
+	// Теги для подбора ревьюеров по навыкам
+	tags, err := normalizeTags(append(slices.Clone(req.Tags), tagsFromPaths(req.ChangedFiles)...))
+	if err != nil {
+		return nil, err
 	}
 
-	err = s.repo.CreatePullRequest(pr)
+	// Владельцы измененных файлов назначаются обязательными ревьюерами
+	requiredReviewers, unresolvedOwners, err := s.resolveCodeowners(ctx, authorTeamIDs, req.AuthorID, req.ChangedFiles)
 	if err != nil {
 		return nil, err
 	}
 
-	// Назначаем ревьюеров
-	reviewers, err := s.assignReviewers(authorTeamID, req.AuthorID)
+	// Подбираем ревьюеров до создания PR, чтобы при исчерпанных лимитах PR не создавался
+	reviewers, err := s.assignReviewers(ctx, assignment{
+		prID:     req.PRID,
+		authorID: req.AuthorID,
+		teamIDs:  authorTeamIDs,
+		tags:     tags,
+		required: requiredReviewers,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Сохраняем ревьюеров
-	if len(reviewers) > 0 {
-		err = s.repo.AssignReviewers(pr.ID, reviewers)
-		if err != nil {
-			return nil, err
-		}
+	// Создаем PR вместе с ревьюерами и событиями в одной транзакции
+	pr := &domain.PullRequest{
+		ID:                req.PRID,
+		Name:              req.Name,
+		AuthorId:          req.AuthorID,
+		Status:            "OPEN",
+		AssignedReviewers: reviewers,
+		Provider:          req.Provider,
+	}
+
+	err = s.repo.CreatePullRequest(ctx, pr)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.PRCreated()
+	if len(reviewers) == 0 {
+		metrics.NoCandidate("create")
 	}
 
 	// Возвращаем созданный PR с ревьюерами
-	return s.repo.GetPullRequestByID(pr.ID)
+	created, err := s.repo.GetPullRequestByID(ctx, pr.ID)
+	if err != nil {
+		return nil, err
+	}
+	created.UnresolvedOwners = unresolvedOwners
+	return created, nil
 }
 
-func (s *Service) MergePullRequest(prID string) (*domain.PullRequest, error) {
+func (s *Service) MergePullRequest(ctx context.Context, prID string) (*domain.PullRequest, error) {
+	ctx, span := tracer.Start(ctx, "Service.MergePullRequest")
+	defer span.End()
+
 	// Получаем текущее состояние PR
-	pr, err := s.repo.GetPullRequestByID(prID)
+	pr, err := s.repo.GetPullRequestByID(ctx, prID)
 	if err != nil {
 		return nil, err
 	}
@@ -65,19 +107,73 @@ func (s *Service) MergePullRequest(prID string) (*domain.PullRequest, error) {
 	if pr.Status == "MERGED" {
 		return pr, nil
 	}
+	// Закрытый PR сначала нужно открыть заново
+	if pr.Status == "CLOSED" {
+		return nil, errors.New("PR_CLOSED")
+	}
 
 	// Мерджим PR
-	err = s.repo.MergePullRequest(prID)
+	err = s.repo.MergePullRequest(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+	metrics.PRMerged()
+
+	return s.repo.GetPullRequestByID(ctx, prID)
+}
+
+// ClosePullRequest закрывает PR без мерджа. Повторное закрытие возвращает PR как есть
+func (s *Service) ClosePullRequest(ctx context.Context, prID string) (*domain.PullRequest, error) {
+	ctx, span := tracer.Start(ctx, "Service.ClosePullRequest")
+	defer span.End()
+
+	pr, err := s.repo.GetPullRequestByID(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+	switch pr.Status {
+	case "CLOSED":
+		return pr, nil
+	case "MERGED":
+		return nil, errors.New("PR_MERGED")
+	}
+
+	err = s.repo.ClosePullRequest(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetPullRequestByID(ctx, prID)
+}
+
+// ReopenPullRequest открывает закрытый PR с прежними ревьюерами. Открытый PR возвращается как есть
+func (s *Service) ReopenPullRequest(ctx context.Context, prID string) (*domain.PullRequest, error) {
+	ctx, span := tracer.Start(ctx, "Service.ReopenPullRequest")
+	defer span.End()
+
+	pr, err := s.repo.GetPullRequestByID(ctx, prID)
 	if err != nil {
 		return nil, err
 	}
+	switch pr.Status {
+	case "OPEN":
+		return pr, nil
+	case "MERGED":
+		return nil, errors.New("PR_MERGED")
+	}
 
-	return s.repo.GetPullRequestByID(prID)
+	err = s.repo.ReopenPullRequest(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetPullRequestByID(ctx, prID)
 }
 
-func (s *Service) ReassignReviewer(prID, oldReviewerID string) (*domain.PullRequest, string, error) {
+func (s *Service) ReassignReviewer(ctx context.Context, prID, oldReviewerID string) (*domain.PullRequest, string, error) {
+	ctx, span := tracer.Start(ctx, "Service.ReassignReviewer")
+	defer span.End()
+
 	// Получаем PR
-	pr, err := s.repo.GetPullRequestByID(prID)
+	pr, err := s.repo.GetPullRequestByID(ctx, prID)
 	if err != nil {
 		return nil, "", err
 	}
@@ -86,6 +182,9 @@ func (s *Service) ReassignReviewer(prID, oldReviewerID string) (*domain.PullRequ
 	if pr.Status == "MERGED" {
 		return nil, "", errors.New("PR_MERGED")
 	}
+	if pr.Status == "CLOSED" {
+		return nil, "", errors.New("PR_CLOSED")
+	}
 
 	// Проверяем что старый ревьюер назначен на этот PR
 	isAssigned := false
@@ -99,64 +198,251 @@ func (s *Service) ReassignReviewer(prID, oldReviewerID string) (*domain.PullRequ
 		return nil, "", errors.New("NOT_ASSIGNED")
 	}
 
-	// Получаем команду старого ревьюера
-	oldReviewerTeamID, err := s.repo.GetAuthorTeam(oldReviewerID)
+	// Получаем команды старого ревьюера
+	oldReviewerTeamIDs, err := s.repo.GetUserTeamIDs(ctx, oldReviewerID)
 	if err != nil {
-		return nil, "", fmt.Errorf("reviewer not found")
+		if err.Error() == "user not found" {
+			return nil, "", fmt.Errorf("reviewer not found")
+		}
+		return nil, "", err
 	}
 
-	// Ищем нового ревьюера из той же команды
+	// Ищем нового ревьюера из тех же команд
 	excludeIDs := append(pr.AssignedReviewers, pr.AuthorId)
-	newReviewer, err := s.repo.GetRandomActiveTeamMember(oldReviewerTeamID, excludeIDs)
+	var newReviewer *domain.User
+	saturated := make(map[string]*domain.ReviewerLoad)
+	err = s.escalate(ctx, oldReviewerTeamIDs, func(ctx context.Context, teamIDs []string) (bool, error) {
+		newReviewer, err = s.repo.GetRandomActiveTeamMember(ctx, teamIDs, excludeIDs)
+		if newReviewer != nil || err != nil {
+			return newReviewer != nil, err
+		}
+		// Запоминаем кандидатов, пропущенных из-за лимита, чтобы вернуть их загрузку
+		candidates, err := s.repo.GetActiveTeamMembers(ctx, teamIDs, pr.AuthorId)
+		for _, candidate := range candidates {
+			if slices.Contains(excludeIDs, candidate.UserId) {
+				continue
+			}
+			if candidate.MaxOpenReviews != nil && candidate.OpenReviews >= *candidate.MaxOpenReviews {
+				saturated[candidate.UserId] = &domain.ReviewerLoad{
+					UserID:         candidate.UserId,
+					OpenReviews:    candidate.OpenReviews,
+					MaxOpenReviews: *candidate.MaxOpenReviews,
+				}
+			}
+		}
+		return false, err
+	})
 	if err != nil {
 		return nil, "", err
 	}
 	if newReviewer == nil {
+		metrics.NoCandidate("reassign")
+		// Кандидаты есть, но все на пределе - сообщаем их загрузку, как при создании PR
+		if len(saturated) > 0 {
+			return nil, "", capacityError(saturated)
+		}
 		return nil, "", errors.New("NO_CANDIDATE")
 	}
 
 	// Заменяем ревьюера
-	err = s.repo.ReplaceReviewer(prID, oldReviewerID, newReviewer.UserId)
+	err = s.repo.ReplaceReviewer(ctx, prID, oldReviewerID, newReviewer.UserId)
 	if err != nil {
 		return nil, "", err
 	}
 
 	// Возвращаем обновленный PR
-	updatedPR, err := s.repo.GetPullRequestByID(prID)
+	metrics.ReviewerReassigned()
+	if len(s.reviewerWriters) > 0 {
+		// Замена уже сохранена, поэтому ошибка обратной записи не влияет на ответ
+		if provider, err := s.repo.GetPRProvider(ctx, prID); err != nil {
+			slog.Error("could not resolve PR provider", "pr", prID, "error", err)
+		} else {
+			s.writeBackReviewers(ctx, provider, prID)
+		}
+	}
+
+	updatedPR, err := s.repo.GetPullRequestByID(ctx, prID)
 	return updatedPR, newReviewer.UserId, err
 }
 
-func (s *Service) GetUserAssignedPRs(userID string) ([]domain.PullRequestShort, error) {
+func (s *Service) GetUserAssignedPRs(ctx context.Context, userID string) ([]domain.PullRequestShort, error) {
+	ctx, span := tracer.Start(ctx, "Service.GetUserAssignedPRs")
+	defer span.End()
+
 	// Проверяем что пользователь существует
-	_, err := s.repo.GetUserByID(userID)
-	if err != nil {
+	if err := s.checkUserExists(ctx, userID); err != nil {
 		return nil, err
 	}
-	return s.repo.GetUserAssignedPRs(userID)
+	return s.repo.GetUserAssignedPRs(ctx, userID)
+}
+
+// Поиск кандидатов с эскалацией по иерархии команд: сначала свои команды,
+// затем родительские, затем соседние (дочерние для родителя), и так вверх по дереву.
+// find возвращает true, если кандидаты найдены и искать дальше не нужно.
+func (s *Service) escalate(ctx context.Context, teamIDs []string, find func(ctx context.Context, teamIDs []string) (bool, error)) error {
+	ctx, span := tracer.Start(ctx, "Service.escalate")
+	defer span.End()
+
+	visited := make(map[string]bool)
+	unvisited := func(ids []string) []string {
+		var result []string
+		for _, id := range ids {
+			if !visited[id] {
+				visited[id] = true
+				result = append(result, id)
+			}
+		}
+		return result
+	}
+
+	current := unvisited(teamIDs)
+	if found, err := find(ctx, current); err != nil || found || !s.cfg.TeamEscalation {
+		return err
+	}
+
+	for len(current) > 0 {
+		parentIDs, err := s.repo.GetParentTeamIDs(ctx, current)
+		if err != nil {
+			return err
+		}
+		parents := unvisited(parentIDs)
+		if len(parents) == 0 {
+			return nil
+		}
+		if found, err := find(ctx, parents); err != nil || found {
+			return err
+		}
+
+		siblingIDs, err := s.repo.GetChildTeamIDs(ctx, parents)
+		if err != nil {
+			return err
+		}
+		if siblings := unvisited(siblingIDs); len(siblings) > 0 {
+			if found, err := find(ctx, siblings); err != nil || found {
+				return err
+			}
+		}
+		current = parents
+	}
+	return nil
+}
+
+// Параметры подбора ревьюеров для PR
+type assignment struct {
+	prID     string
+	authorID string
+	teamIDs  []string
+	// Теги PR для подбора по навыкам
+	tags []string
+	// Обязательные ревьюеры (владельцы кода), оставшиеся места заполняются обычным способом
+	required []string
 }
 
 // Вспомогательный метод для назначения ревьюеров
-func (s *Service) assignReviewers(teamID, excludeUserID string) ([]string, error) {
-	members, err := s.repo.GetActiveTeamMembers(teamID, excludeUserID)
+func (s *Service) assignReviewers(ctx context.Context, a assignment) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "Service.assignReviewers")
+	defer span.End()
+
+	reviewerIDs := append([]string{}, a.required...)
+	if len(reviewerIDs) >= s.cfg.ReviewersCount {
+		return reviewerIDs, nil
+	}
+
+	isRequired := make(map[string]bool, len(a.required))
+	for _, id := range a.required {
+		isRequired[id] = true
+	}
+
+	var members []domain.ReviewerCandidate
+	saturated := make(map[string]*domain.ReviewerLoad)
+	err := s.escalate(ctx, a.teamIDs, func(ctx context.Context, teamIDs []string) (bool, error) {
+		candidates, err := s.repo.GetActiveTeamMembers(ctx, teamIDs, a.authorID)
+		members = members[:0]
+		for _, candidate := range candidates {
+			if isRequired[candidate.UserId] {
+				continue
+			}
+			// Пропускаем тех, кто уже взял максимум открытых ревью
+			if candidate.MaxOpenReviews != nil && candidate.OpenReviews >= *candidate.MaxOpenReviews {
+				saturated[candidate.UserId] = &domain.ReviewerLoad{
+					UserID:         candidate.UserId,
+					OpenReviews:    candidate.OpenReviews,
+					MaxOpenReviews: *candidate.MaxOpenReviews,
+				}
+				continue
+			}
+			members = append(members, candidate)
+		}
+		return len(members) > 0, err
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	// Если нет доступных ревьюеров
 	if len(members) == 0 {
-		return []string{}, nil
+		// Кандидаты есть, но все на пределе - сообщаем вместо назначения сверх лимита
+		if len(reviewerIDs) == 0 && len(saturated) > 0 {
+			return nil, capacityError(saturated)
+		}
+		return reviewerIDs, nil
+	}
+
+	// Штраф за частые пары: кто ревьюил последние N PR автора, выбирается реже
+	depth := 0
+	if s.cfg.PairingHistory {
+		depth, err = s.repo.GetPairingHistoryDepth(ctx, a.teamIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if depth > 0 {
+		pairings, err := s.repo.GetRecentPairings(ctx, a.authorID, a.prID, depth)
+		if err != nil {
+			return nil, err
+		}
+		for i := range members {
+			members[i].RecentPairings = pairings[members[i].UserId]
+		}
 	}
 
-	// Перемешиваем для случайного выбора
-	shuffled := make([]domain.User, len(members))
+	// Взвешенное перемешивание (Efraimidis-Spirakis): ключ -ln(u)/w,
+	// участники с большей долей ревью и меньшим числом недавних пар чаще оказываются в начале
+	keys := make(map[string]float64, len(members))
+	shuffled := make([]domain.ReviewerCandidate, len(members))
 	copy(shuffled, members)
-	rand.Shuffle(len(shuffled), func(i, j int) {
-		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	for _, member := range shuffled {
+		weight := member.ReviewShare
+		if weight <= 0 {
+			weight = 1
+		}
+		weight /= float64(1 + member.RecentPairings)
+		keys[member.UserId] = -math.Log(1-rand.Float64()) / weight
+	}
+	sort.Slice(shuffled, func(i, j int) bool {
+		return keys[shuffled[i].UserId] < keys[shuffled[j].UserId]
 	})
 
-	// Выбираем до 2 ревьюеров
-	var reviewerIDs []string
-	maxReviewers := min(2, len(shuffled))
+	// Ревьюеры с подходящими навыками идут первыми, без совпадений - обычный случайный выбор
+	if s.cfg.SkillMatching && len(a.tags) > 0 {
+		userIDs := make([]string, len(shuffled))
+		for i, member := range shuffled {
+			userIDs[i] = member.UserId
+		}
+		matches, err := s.repo.GetSkillMatches(ctx, userIDs, a.tags)
+		if err != nil {
+			return nil, err
+		}
+		for i := range shuffled {
+			shuffled[i].SkillMatches = matches[shuffled[i].UserId]
+		}
+		sort.SliceStable(shuffled, func(i, j int) bool {
+			return shuffled[i].SkillMatches > shuffled[j].SkillMatches
+		})
+	}
+
+	// Добираем до нужного числа ревьюеров
+	maxReviewers := min(s.cfg.ReviewersCount-len(reviewerIDs), len(shuffled))
 	for i := 0; i < maxReviewers; i++ {
 		reviewerIDs = append(reviewerIDs, shuffled[i].UserId)
 	}
@@ -164,6 +450,173 @@ func (s *Service) assignReviewers(teamID, excludeUserID string) ([]string, error
 	return reviewerIDs, nil
 }
 
+// Владельцы измененных файлов по CODEOWNERS команд автора, не больше ReviewersCount.
+// Пользователи берутся как есть (если активны и не достигли лимита ревью), от команды-владельца выбирается один случайный активный участник.
+// Владельцы, которых нельзя назначить ревьюерами (email, неизвестная команда), возвращаются отдельно как нераспознанные.
+func (s *Service) resolveCodeowners(ctx context.Context, teamIDs []string, authorID string, changedFiles []string) ([]string, []string, error) {
+	ctx, span := tracer.Start(ctx, "Service.resolveCodeowners")
+	defer span.End()
+
+	if !s.cfg.Codeowners || len(changedFiles) == 0 {
+		return nil, nil, nil
+	}
+
+	contents, err := s.repo.GetCodeownersByTeamIDs(ctx, teamIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var userIDs, teamNames, unresolved []string
+	for _, content := range contents {
+		ruleset, err := codeowners.Parse(content)
+		if err != nil {
+			// Файлы проверяются при загрузке, сюда попасть не должны
+			slog.Warn("skipping invalid codeowners", "error", err)
+			continue
+		}
+		for _, owner := range ruleset.Owners(changedFiles) {
+			switch owner.Kind {
+			case codeowners.OwnerUser:
+				userIDs = append(userIDs, owner.Name)
+			case codeowners.OwnerTeam:
+				teamNames = append(teamNames, owner.Name)
+			case codeowners.OwnerEmail:
+				// Пользователи не связаны с email, назначить такого владельца нельзя
+				unresolved = appendUnique(unresolved, owner.Name)
+			}
+		}
+	}
+
+	chosen := map[string]bool{authorID: true}
+	var owners []string
+
+	availableIDs, err := s.repo.GetAvailableUserIDs(ctx, userIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, id := range availableIDs {
+		if !chosen[id] {
+			chosen[id] = true
+			owners = append(owners, id)
+		}
+	}
+
+	for _, teamName := range teamNames {
+		if len(owners) >= s.cfg.ReviewersCount {
+			break
+		}
+		teamID, err := s.repo.GetTeamIDByName(ctx, teamName)
+		if err != nil {
+			slog.Warn("codeowners team not found", "team", teamName)
+			unresolved = appendUnique(unresolved, "@"+teamName)
+			continue
+		}
+		exclude := make([]string, 0, len(chosen))
+		for id := range chosen {
+			exclude = append(exclude, id)
+		}
+		member, err := s.repo.GetRandomActiveTeamMember(ctx, []string{teamID}, exclude)
+		if err != nil {
+			return nil, nil, err
+		}
+		if member != nil {
+			chosen[member.UserId] = true
+			owners = append(owners, member.UserId)
+		}
+	}
+
+	// Владельцев больше, чем мест ревьюеров - берем первых по порядку правил
+	if len(owners) > s.cfg.ReviewersCount {
+		owners = owners[:s.cfg.ReviewersCount]
+	}
+
+	return owners, unresolved, nil
+}
+
+// Ошибка исчерпанных лимитов с загрузкой кандидатов, отсортированных по ID
+func capacityError(saturated map[string]*domain.ReviewerLoad) error {
+	loads := make([]*domain.ReviewerLoad, 0, len(saturated))
+	for _, load := range saturated {
+		loads = append(loads, load)
+	}
+	sort.Slice(loads, func(i, j int) bool {
+		return loads[i].UserID < loads[j].UserID
+	})
+	return &domain.CapacityError{Users: loads}
+}
+
+func appendUnique(values []string, value string) []string {
+	if slices.Contains(values, value) {
+		return values
+	}
+	return append(values, value)
+}
+
+// Явное соответствие путей навыкам: директория в пути или расширение файла -> тег.
+// Остальные директории и расширения тегами не считаются
+var (
+	dirSkillTags = map[string]string{
+		"migrations": "db",
+		"storage":    "db",
+		"frontend":   "frontend",
+		"web":        "frontend",
+		"deploy":     "devops",
+		".github":    "devops",
+	}
+	extSkillTags = map[string]string{
+		"sql":   "db",
+		"go":    "go",
+		"ts":    "frontend",
+		"tsx":   "frontend",
+		"js":    "frontend",
+		"css":   "frontend",
+		"html":  "frontend",
+		"proto": "api",
+	}
+)
+
+// Теги из путей файлов по dirSkillTags и extSkillTags,
+// например "internal/storage/db.go" -> db, go
+func tagsFromPaths(paths []string) []string {
+	var tags []string
+	for _, p := range paths {
+		p = strings.Trim(path.Clean(strings.ReplaceAll(p, "\\", "/")), "/")
+		if p == "" || p == "." {
+			continue
+		}
+		dir, file := path.Split(p)
+		for _, segment := range strings.Split(strings.Trim(dir, "/"), "/") {
+			if tag, ok := dirSkillTags[strings.ToLower(segment)]; ok {
+				tags = append(tags, tag)
+			}
+		}
+		if tag, ok := extSkillTags[strings.ToLower(strings.TrimPrefix(path.Ext(file), "."))]; ok {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// Приводим теги к нижнему регистру и убираем дубликаты
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+		if len(tag) > maxTagLength {
+			return nil, errors.New("INVALID_TAG")
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			result = append(result, tag)
+		}
+	}
+	return result, nil
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"avito-tech-internship/internal/domain"
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	eventStreamBatchSize = 100
+	// Сколько ждать транзакцию, занявшую пропущенный id outbox, прежде чем считать id пустым
+	eventStreamSettleAfter = 5 * time.Second
+)
+
+// OpenEventStream проверяет фильтр и возвращает место, с которого начинается поток:
+// lastEventID, если клиент продолжает поток, иначе последнее событие - тогда придут только новые.
+// Если событий после lastEventID в журнале уже нет, поток тоже начинается с новых событий и отмечается Reset
+func (s *Service) OpenEventStream(ctx context.Context, filter domain.StreamFilter, lastEventID *int64) (*domain.EventStreamStart, error) {
+	ctx, span := tracer.Start(ctx, "Service.OpenEventStream")
+	defer span.End()
+
+	for _, eventType := range filter.Types {
+		if !domain.IsEventType(eventType) {
+			return nil, errors.New("INVALID_EVENTS")
+		}
+	}
+	if filter.UserID != "" {
+		if err := s.checkUserExists(ctx, filter.UserID); err != nil {
+			return nil, err
+		}
+	}
+	if filter.TeamName != "" {
+		if _, err := s.repo.GetTeamByName(ctx, filter.TeamName); err != nil {
+			return nil, err
+		}
+	}
+
+	if lastEventID != nil {
+		if *lastEventID < 0 {
+			return nil, errors.New("INVALID_EVENT_ID")
+		}
+		first, next, err := s.repo.GetOutboxIDBounds(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// Пропуск перед first может остаться и от отката транзакции, но тогда клиент не видел
+		// событий с начала журнала дольше HISTORY_RETENTION, и перечитать состояние безопаснее
+		if *lastEventID+1 >= first && *lastEventID < next {
+			return &domain.EventStreamStart{Cursor: *lastEventID}, nil
+		}
+	}
+
+	latest, err := s.repo.GetLatestOutboxID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.EventStreamStart{Cursor: latest, Reset: lastEventID != nil}, nil
+}
+
+// ReadEventLog читает журнал событий outbox после cursor. События читаются строго по порядку id:
+// если перед следующим событием пропуск, чтение останавливается, пока пропуск не заполнится
+// или событие после него не станет старше eventStreamSettleAfter (откат транзакции тоже оставляет пропуск)
+func (s *Service) ReadEventLog(ctx context.Context, cursor int64, filter domain.StreamFilter) (*domain.EventLogPage, error) {
+	ctx, span := tracer.Start(ctx, "Service.ReadEventLog")
+	defer span.End()
+
+	events, err := s.repo.GetStreamEvents(ctx, cursor, filter, eventStreamBatchSize, eventStreamSettleAfter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &domain.EventLogPage{Cursor: cursor}
+	for _, event := range events {
+		// Пропуск перед самым первым событием журнала не ждем: ранние события могли быть удалены очисткой
+		if page.Cursor > 0 && event.ID != page.Cursor+1 && !event.Settled {
+			page.Pending = true
+			break
+		}
+		page.Cursor = event.ID
+		if event.Matches {
+			page.Events = append(page.Events, event)
+		}
+	}
+	page.More = !page.Pending && len(events) == eventStreamBatchSize
+	return page, nil
+}
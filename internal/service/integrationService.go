@@ -0,0 +1,214 @@
+package service
+
+import (
+	"avito-tech-internship/internal/domain"
+	"avito-tech-internship/internal/metrics"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// ReviewerWriter записывает назначенных ревьюеров обратно во внешнюю систему
+// (integration.GitLabClient или фейк в тестах)
+type ReviewerWriter interface {
+	// SetReviewers заменяет ревьюеров PR пользователями с логинами logins
+	SetReviewers(ctx context.Context, prID string, logins []string) error
+}
+
+// SetReviewerWriter включает обратную запись ревьюеров для PR, пришедших от provider
+func (s *Service) SetReviewerWriter(provider string, writer ReviewerWriter) {
+	if s.reviewerWriters == nil {
+		s.reviewerWriters = make(map[string]ReviewerWriter)
+	}
+	s.reviewerWriters[provider] = writer
+}
+
+// HandleForgeEvent применяет событие PR из внешней системы. delivery содержит провайдера, id доставки,
+// событие и действие; event nil - вебхук только записывается как ignored.
+// Доставка с уже обработанным id не применяется повторно, возвращается сохраненный результат.
+// При ошибке доставка не записывается, чтобы ее можно было отправить заново.
+func (s *Service) HandleForgeEvent(ctx context.Context, delivery *domain.IntegrationDelivery, event *domain.ForgePREvent) (*domain.IntegrationDelivery, error) {
+	ctx, span := tracer.Start(ctx, "Service.HandleForgeEvent")
+	defer span.End()
+
+	previous, err := s.repo.GetIntegrationDelivery(ctx, delivery.Provider, delivery.DeliveryID)
+	if err == nil {
+		previous.Duplicate = true
+		return previous, nil
+	}
+	if err.Error() != "delivery not found" {
+		return nil, err
+	}
+
+	delivery.Result = domain.IntegrationIgnored
+	if event != nil {
+		delivery.PRID = &event.PRID
+		delivery.Result, err = s.applyForgeEvent(ctx, delivery.Provider, event)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	slog.Info("forge event processed", "provider", delivery.Provider, "delivery", delivery.DeliveryID,
+		"event", delivery.Event, "action", delivery.Action, "result", delivery.Result)
+	if err := s.repo.RecordIntegrationDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+func (s *Service) applyForgeEvent(ctx context.Context, provider string, event *domain.ForgePREvent) (string, error) {
+	pr, err := s.repo.GetPullRequestByID(ctx, event.PRID)
+	if err != nil && err.Error() != "pull request not found" {
+		return "", err
+	}
+	// PR, которого нет в сервисе (например, смерджен сразу из черновика), закрывать и мерджить не нужно
+	if pr == nil && (event.Action == domain.ForgeMerged || event.Action == domain.ForgeClosed) {
+		return domain.IntegrationIgnored, nil
+	}
+
+	switch event.Action {
+	case domain.ForgeOpened, domain.ForgeReopened:
+		if pr == nil {
+			return s.createForgePR(ctx, provider, event)
+		}
+		if pr.Status != "CLOSED" {
+			return domain.IntegrationIgnored, nil
+		}
+		if _, err := s.ReopenPullRequest(ctx, pr.ID); err != nil {
+			return "", err
+		}
+		return domain.IntegrationReopened, nil
+	case domain.ForgeMerged:
+		if pr.Status == "MERGED" {
+			return domain.IntegrationIgnored, nil
+		}
+		// Во внешней системе PR уже смерджен, поэтому закрытый в сервисе PR сначала открываем
+		if pr.Status == "CLOSED" {
+			if _, err := s.ReopenPullRequest(ctx, pr.ID); err != nil {
+				return "", err
+			}
+		}
+		if _, err := s.MergePullRequest(ctx, pr.ID); err != nil {
+			return "", err
+		}
+		return domain.IntegrationMerged, nil
+	case domain.ForgeClosed:
+		if pr.Status != "OPEN" {
+			return domain.IntegrationIgnored, nil
+		}
+		if _, err := s.ClosePullRequest(ctx, pr.ID); err != nil {
+			return "", err
+		}
+		return domain.IntegrationClosed, nil
+	}
+	return domain.IntegrationIgnored, nil
+}
+
+func (s *Service) createForgePR(ctx context.Context, provider string, event *domain.ForgePREvent) (string, error) {
+	authorID, err := s.repo.ResolveExternalUser(ctx, provider, event.AuthorLogin)
+	if err != nil {
+		if err.Error() == "mapping not found" {
+			return "", fmt.Errorf("UNMAPPED_USER: %s", event.AuthorLogin)
+		}
+		return "", err
+	}
+
+	_, err = s.CreatePullRequest(ctx, &domain.CreatePRRequest{
+		PRID:         event.PRID,
+		Name:         event.Name,
+		AuthorID:     authorID,
+		Tags:         event.Tags,
+		ChangedFiles: event.ChangedFiles,
+		Provider:     provider,
+	})
+	// PR успел создать параллельный вебхук
+	if err != nil && err.Error() == "PR_EXISTS" {
+		return domain.IntegrationIgnored, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	s.writeBackReviewers(ctx, provider, event.PRID)
+	return domain.IntegrationCreated, nil
+}
+
+// writeBackReviewers передает текущих ревьюеров PR во внешнюю систему, если для провайдера настроен клиент.
+// Ошибки только логируются: изменение в сервисе уже сохранено, а повтор вебхука его не применит
+func (s *Service) writeBackReviewers(ctx context.Context, provider, prID string) {
+	writer, ok := s.reviewerWriters[provider]
+	if !ok {
+		return
+	}
+
+	err := func() error {
+		reviewers, err := s.repo.GetPRReviewers(ctx, prID)
+		if err != nil {
+			return err
+		}
+		logins, err := s.repo.GetExternalLogins(ctx, provider, reviewers)
+		if err != nil {
+			return err
+		}
+		mapped := make([]string, 0, len(reviewers))
+		for _, reviewerID := range reviewers {
+			login, ok := logins[reviewerID]
+			if !ok {
+				slog.Warn("reviewer has no external login, skipped in write-back", "provider", provider, "pr", prID, "user", reviewerID)
+				continue
+			}
+			mapped = append(mapped, login)
+		}
+		return writer.SetReviewers(ctx, prID, mapped)
+	}()
+	if err != nil {
+		metrics.IntegrationWriteBack(provider, "failed")
+		slog.Error("could not write back reviewers", "provider", provider, "pr", prID, "error", err)
+		return
+	}
+	metrics.IntegrationWriteBack(provider, "ok")
+}
+
+// Mappings
+func (s *Service) SetExternalUserMapping(ctx context.Context, req *domain.ExternalUserMappingRequest) (*domain.ExternalUserMapping, error) {
+	ctx, span := tracer.Start(ctx, "Service.SetExternalUserMapping")
+	defer span.End()
+
+	if !domain.IsProvider(req.Provider) {
+		return nil, errors.New("INVALID_PROVIDER")
+	}
+	if req.UserID == "" {
+		return nil, errors.New("INVALID_USER")
+	}
+	mapping := &domain.ExternalUserMapping{
+		Provider:      req.Provider,
+		ExternalLogin: strings.TrimSpace(req.ExternalLogin),
+		UserID:        req.UserID,
+	}
+	if err := s.repo.SetExternalUserMapping(ctx, mapping); err != nil {
+		return nil, err
+	}
+	return mapping, nil
+}
+
+func (s *Service) DeleteExternalUserMapping(ctx context.Context, req *domain.ExternalUserMappingRequest) error {
+	ctx, span := tracer.Start(ctx, "Service.DeleteExternalUserMapping")
+	defer span.End()
+
+	if !domain.IsProvider(req.Provider) {
+		return errors.New("INVALID_PROVIDER")
+	}
+	return s.repo.DeleteExternalUserMapping(ctx, req.Provider, strings.TrimSpace(req.ExternalLogin))
+}
+
+func (s *Service) GetExternalUserMappings(ctx context.Context, provider string) ([]*domain.ExternalUserMapping, error) {
+	ctx, span := tracer.Start(ctx, "Service.GetExternalUserMappings")
+	defer span.End()
+
+	if provider != "" && !domain.IsProvider(provider) {
+		return nil, errors.New("INVALID_PROVIDER")
+	}
+	return s.repo.GetExternalUserMappings(ctx, provider)
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"avito-tech-internship/internal/domain"
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+)
+
+const (
+	defaultJobRunsLimit = 50
+	maxJobRunsLimit     = 500
+)
+
+// JobScheduler - планировщик фоновых заданий (scheduler.Scheduler)
+type JobScheduler interface {
+	Jobs(ctx context.Context) ([]*domain.ScheduledJob, error)
+	Trigger(ctx context.Context, name string) (*domain.JobRun, error)
+}
+
+// SetScheduler открывает задания планировщика для просмотра и ручного запуска
+func (s *Service) SetScheduler(scheduler JobScheduler) {
+	s.scheduler = scheduler
+}
+
+func (s *Service) GetJobs(ctx context.Context) ([]*domain.ScheduledJob, error) {
+	ctx, span := tracer.Start(ctx, "Service.GetJobs")
+	defer span.End()
+
+	if s.scheduler == nil {
+		return nil, errors.New("SCHEDULER_DISABLED")
+	}
+	return s.scheduler.Jobs(ctx)
+}
+
+// TriggerJob запускает задание вне расписания, запуск выполняется в фоне
+func (s *Service) TriggerJob(ctx context.Context, name string) (*domain.JobRun, error) {
+	ctx, span := tracer.Start(ctx, "Service.TriggerJob")
+	defer span.End()
+
+	if s.scheduler == nil {
+		return nil, errors.New("SCHEDULER_DISABLED")
+	}
+	return s.scheduler.Trigger(ctx, name)
+}
+
+// GetJobRuns возвращает историю запусков со всех реплик, пустой jobName - по всем заданиям
+func (s *Service) GetJobRuns(ctx context.Context, jobName string, limit int) ([]*domain.JobRun, error) {
+	ctx, span := tracer.Start(ctx, "Service.GetJobRuns")
+	defer span.End()
+
+	if limit <= 0 {
+		limit = defaultJobRunsLimit
+	}
+	return s.repo.GetJobRuns(ctx, jobName, min(limit, maxJobRunsLimit))
+}
+
+// CleanupHistory удаляет служебные записи старше HistoryRetention
+func (s *Service) CleanupHistory(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "Service.CleanupHistory")
+	defer span.End()
+
+	before := time.Now().UTC().Add(-s.cfg.HistoryRetention)
+	deleted, err := s.repo.DeleteHistoryBefore(ctx, before)
+	if err != nil {
+		return err
+	}
+	args := []any{"before", before}
+	for _, table := range slices.Sorted(maps.Keys(deleted)) {
+		args = append(args, table, deleted[table])
+	}
+	slog.Info("history cleaned up", args...)
+	return nil
+}
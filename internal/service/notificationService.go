@@ -0,0 +1,64 @@
+package service
+
+import (
+	"avito-tech-internship/internal/domain"
+	"context"
+	"errors"
+	"net/mail"
+	"net/url"
+)
+
+// SetUserNotifications включает или выключает уведомления пользователя в чат
+func (s *Service) SetUserNotifications(ctx context.Context, req *domain.SetUserNotificationsRequest) error {
+	ctx, span := tracer.Start(ctx, "Service.SetUserNotifications")
+	defer span.End()
+
+	return s.repo.SetUserNotifications(ctx, req.UserID, *req.Enabled)
+}
+
+// SetTeamNotificationChannel задает incoming webhook команды, пустой URL удаляет канал
+func (s *Service) SetTeamNotificationChannel(ctx context.Context, req *domain.SetTeamChannelRequest) (*domain.TeamNotificationChannel, error) {
+	ctx, span := tracer.Start(ctx, "Service.SetTeamNotificationChannel")
+	defer span.End()
+
+	if req.WebhookURL != "" {
+		target, err := url.Parse(req.WebhookURL)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			return nil, errors.New("INVALID_URL")
+		}
+	}
+	err := s.repo.SetTeamNotificationChannel(ctx, req.TeamName, req.WebhookURL, req.Channel)
+	if err != nil {
+		return nil, err
+	}
+	if req.WebhookURL == "" {
+		return nil, nil
+	}
+	return s.repo.GetTeamNotificationChannel(ctx, req.TeamName)
+}
+
+func (s *Service) GetTeamNotificationChannel(ctx context.Context, teamName string) (*domain.TeamNotificationChannel, error) {
+	ctx, span := tracer.Start(ctx, "Service.GetTeamNotificationChannel")
+	defer span.End()
+
+	return s.repo.GetTeamNotificationChannel(ctx, teamName)
+}
+
+// SetUserEmail задает адрес для ежедневной сводки ревью
+func (s *Service) SetUserEmail(ctx context.Context, req *domain.SetUserEmailRequest) error {
+	ctx, span := tracer.Start(ctx, "Service.SetUserEmail")
+	defer span.End()
+
+	if req.Email != "" {
+		address, err := mail.ParseAddress(req.Email)
+		// Принимаем только голый адрес, без имени
+		if err != nil || address.Address != req.Email {
+			return errors.New("INVALID_EMAIL")
+		}
+	}
+	digest := true
+	if req.Digest != nil {
+		digest = *req.Digest
+	}
+	return s.repo.SetUserEmail(ctx, req.UserID, req.Email, digest)
+}
@@ -1,73 +1,335 @@
 package service
 
 import (
+	"avito-tech-internship/internal/codeowners"
 	"avito-tech-internship/internal/domain"
 	"avito-tech-internship/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"time"
 )
 
+const maxPairingHistoryDepth = 100
+
+var tracer = otel.Tracer("avito-tech-internship/internal/service")
+
+// Config - настройки подбора ревьюеров
+type Config struct {
+	// Сколько ревьюеров назначать на новый PR
+	ReviewersCount int
+	// Переключатели отдельных этапов подбора
+	SkillMatching  bool
+	Codeowners     bool
+	PairingHistory bool
+	TeamEscalation bool
+	// SLA ответа ревьюера для команд без собственного SLA
+	ReviewSLA time.Duration
+	// Политика устаревания PR: пометка после StaleMarkAfter без активности,
+	// закрытие после StaleCloseAfter (0 - не закрывать)
+	StaleMarkAfter  time.Duration
+	StaleCloseAfter time.Duration
+	// Сколько хранить историю заданий, опубликованные события и другие служебные записи
+	HistoryRetention time.Duration
+}
+
 type Service struct {
 	repo storage.Repository
+	cfg  Config
+	// Клиенты обратной записи ревьюеров по провайдерам
+	reviewerWriters map[string]ReviewerWriter
+	// nil - планировщик в этом экземпляре выключен
+	scheduler JobScheduler
 }
 
-func NewService(repo storage.Repository) *Service {
-	return &Service{repo: repo}
+func NewService(repo storage.Repository, cfg Config) *Service {
+	return &Service{repo: repo, cfg: cfg}
 }
 
 // Users
-func (s *Service) AddNewUser(user *domain.User) (*domain.User, error) {
-	user, err := s.repo.AddNewUser(user)
+func (s *Service) AddNewUser(ctx context.Context, user *domain.User) (*domain.User, error) {
+	ctx, span := tracer.Start(ctx, "Service.AddNewUser")
+	defer span.End()
+
+	user, err := s.repo.AddNewUser(ctx, user)
 	if err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
-func (s *Service) GetUserByID(id string) (*domain.User, error) {
-	user, err := s.repo.GetUserByID(id)
+func (s *Service) GetUserByID(ctx context.Context, id string) (*domain.User, error) {
+	ctx, span := tracer.Start(ctx, "Service.GetUserByID")
+	defer span.End()
+
+	user, err := s.repo.GetUserByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("NOT_FOUND")
+	}
 	if err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
-func (s *Service) SetUserActive(userID string, isActive bool) (*domain.User, error) {
-	err := s.repo.SetUserActive(userID, isActive)
+func (s *Service) SetUserActive(ctx context.Context, userID string, isActive bool) (*domain.User, error) {
+	ctx, span := tracer.Start(ctx, "Service.SetUserActive")
+	defer span.End()
+
+	err := s.repo.SetUserActive(ctx, userID, isActive)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetUserByID(ctx, userID)
+}
+
+func (s *Service) SetMaxOpenReviews(ctx context.Context, req *domain.SetMaxOpenReviewsRequest) (*domain.User, error) {
+	ctx, span := tracer.Start(ctx, "Service.SetMaxOpenReviews")
+	defer span.End()
+
+	if req.MaxOpenReviews != nil && *req.MaxOpenReviews < 0 {
+		return nil, errors.New("INVALID_LIMIT")
+	}
+	err := s.repo.SetMaxOpenReviews(ctx, req.UserID, req.MaxOpenReviews)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetUserByID(ctx, req.UserID)
+}
+
+// Skills
+func (s *Service) AddUserSkills(ctx context.Context, req *domain.UserSkillsRequest) (*domain.User, error) {
+	ctx, span := tracer.Start(ctx, "Service.AddUserSkills")
+	defer span.End()
+
+	skills, err := normalizeTags(req.Skills)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.checkUserExists(ctx, req.UserID); err != nil {
+		return nil, err
+	}
+	err = s.repo.AddUserSkills(ctx, req.UserID, skills)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetUserByID(ctx, req.UserID)
+}
+
+func (s *Service) RemoveUserSkills(ctx context.Context, req *domain.UserSkillsRequest) (*domain.User, error) {
+	ctx, span := tracer.Start(ctx, "Service.RemoveUserSkills")
+	defer span.End()
+
+	skills, err := normalizeTags(req.Skills)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.checkUserExists(ctx, req.UserID); err != nil {
+		return nil, err
+	}
+	err = s.repo.RemoveUserSkills(ctx, req.UserID, skills)
 	if err != nil {
 		return nil, err
 	}
-	return s.repo.GetUserByID(userID)
+	return s.repo.GetUserByID(ctx, req.UserID)
+}
+
+func (s *Service) GetUserSkills(ctx context.Context, userID string) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "Service.GetUserSkills")
+	defer span.End()
+
+	if err := s.checkUserExists(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetUserSkills(ctx, userID)
+}
+
+// Проверяем что пользователь существует, отсутствие - NOT_FOUND для всех операций с навыками
+func (s *Service) checkUserExists(ctx context.Context, userID string) error {
+	_, err := s.repo.GetUserByID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("NOT_FOUND")
+	}
+	return err
 }
 
 // Teams
-func (s *Service) CreateNewTeam(team *domain.Team) error {
-	err := s.repo.AddTeam(team)
+func (s *Service) CreateNewTeam(ctx context.Context, req *domain.CreateTeamRequest) (*domain.Team, []*domain.TeamMemberResult, error) {
+	ctx, span := tracer.Start(ctx, "Service.CreateNewTeam")
+	defer span.End()
+
+	mode := req.Mode
+	if mode == "" {
+		mode = domain.TeamConflictFail
+	}
+	switch mode {
+	case domain.TeamConflictFail, domain.TeamConflictMove, domain.TeamConflictSkip, domain.TeamConflictJoin:
+	default:
+		return nil, nil, errors.New("INVALID_MODE")
+	}
+	// Повтор user_id иначе выглядел бы как конфликт с создаваемой командой
+	seen := make(map[string]bool, len(req.Members))
+	for _, member := range req.Members {
+		if seen[member.UserId] {
+			return nil, nil, errors.New("DUPLICATE_MEMBER")
+		}
+		seen[member.UserId] = true
+	}
+
+	team := &domain.Team{
+		TeamName:       req.TeamName,
+		ParentTeamName: req.ParentTeamName,
+		Members:        req.Members,
+	}
+	results, err := s.repo.AddTeam(ctx, team, mode)
 	if err != nil {
-		return err
+		return nil, results, err
+	}
+
+	// В ответ попадают только реально добавленные в команду участники
+	added := make(map[string]bool, len(results))
+	for _, result := range results {
+		if result.Result == domain.MemberCreated || result.Result == domain.MemberMoved || result.Result == domain.MemberJoined {
+			added[result.UserID] = true
+		}
 	}
-	return nil
+	members := make([]*domain.User, 0, len(added))
+	for _, member := range req.Members {
+		if added[member.UserId] {
+			members = append(members, member)
+		}
+	}
+
+	return &domain.Team{TeamName: req.TeamName, ParentTeamName: req.ParentTeamName, Members: members}, results, nil
 }
 
-func (s *Service) GetTeamByName(teamName string) (*domain.Team, error) {
-	team, err := s.repo.GetTeamByName(teamName)
+func (s *Service) AddTeamMember(ctx context.Context, req *domain.TeamMemberRequest) (*domain.User, error) {
+	ctx, span := tracer.Start(ctx, "Service.AddTeamMember")
+	defer span.End()
+
+	if req.ReviewShare != nil && (*req.ReviewShare <= 0 || *req.ReviewShare > 1) {
+		return nil, errors.New("INVALID_SHARE")
+	}
+	err := s.repo.AddTeamMember(ctx, req.TeamName, req.UserID, req.ReviewShare)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetUserByID(ctx, req.UserID)
+}
+
+func (s *Service) RemoveTeamMember(ctx context.Context, req *domain.TeamMemberRequest) (*domain.User, error) {
+	ctx, span := tracer.Start(ctx, "Service.RemoveTeamMember")
+	defer span.End()
+
+	err := s.repo.RemoveTeamMember(ctx, req.TeamName, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetUserByID(ctx, req.UserID)
+}
+
+func (s *Service) GetTeamByName(ctx context.Context, teamName string) (*domain.Team, error) {
+	ctx, span := tracer.Start(ctx, "Service.GetTeamByName")
+	defer span.End()
+
+	team, err := s.repo.GetTeamByName(ctx, teamName)
 	if err != nil {
 		return nil, err
 	}
 	return team, nil
 }
 
+func (s *Service) SetTeamCodeowners(ctx context.Context, req *domain.TeamCodeownersRequest) error {
+	ctx, span := tracer.Start(ctx, "Service.SetTeamCodeowners")
+	defer span.End()
+
+	// Сохраняем только корректные файлы, ошибки разбора возвращаем с номером строки
+	if _, err := codeowners.Parse(req.Content); err != nil {
+		return fmt.Errorf("INVALID_CODEOWNERS: %w", err)
+	}
+	return s.repo.SetTeamCodeowners(ctx, req.TeamName, req.Content)
+}
+
+func (s *Service) GetTeamCodeowners(ctx context.Context, teamName string) (string, error) {
+	ctx, span := tracer.Start(ctx, "Service.GetTeamCodeowners")
+	defer span.End()
+
+	return s.repo.GetTeamCodeowners(ctx, teamName)
+}
+
+func (s *Service) SetPairingHistoryDepth(ctx context.Context, req *domain.SetPairingDepthRequest) (int, error) {
+	ctx, span := tracer.Start(ctx, "Service.SetPairingHistoryDepth")
+	defer span.End()
+
+	if req.Depth < 0 || req.Depth > maxPairingHistoryDepth {
+		return 0, errors.New("INVALID_DEPTH")
+	}
+	err := s.repo.SetPairingHistoryDepth(ctx, req.TeamName, req.Depth)
+	if err != nil {
+		return 0, err
+	}
+	return req.Depth, nil
+}
+
+func (s *Service) SetTeamParent(ctx context.Context, req *domain.SetTeamParentRequest) (*domain.Team, error) {
+	ctx, span := tracer.Start(ctx, "Service.SetTeamParent")
+	defer span.End()
+
+	if req.TeamName == req.ParentTeamName {
+		return nil, errors.New("TEAM_CYCLE")
+	}
+	err := s.repo.SetTeamParent(ctx, req.TeamName, req.ParentTeamName)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetTeamByName(ctx, req.TeamName)
+}
+
+func (s *Service) GetTeamTree(ctx context.Context) ([]*domain.TeamNode, error) {
+	ctx, span := tracer.Start(ctx, "Service.GetTeamTree")
+	defer span.End()
+
+	links, err := s.repo.GetTeamLinks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[string]*domain.TeamNode, len(links))
+	for _, link := range links {
+		nodes[link.TeamName] = &domain.TeamNode{TeamName: link.TeamName, Children: []*domain.TeamNode{}}
+	}
+
+	roots := []*domain.TeamNode{}
+	for _, link := range links {
+		node := nodes[link.TeamName]
+		if link.ParentTeamName == nil {
+			roots = append(roots, node)
+			continue
+		}
+		parent := nodes[*link.ParentTeamName]
+		parent.Children = append(parent.Children, node)
+	}
+	return roots, nil
+}
+
 // Stats
-func (s *Service) GetStats() (*domain.StatsResponse, error) {
-	userStats, err := s.repo.GetPRReviewersStats()
+func (s *Service) GetStats(ctx context.Context) (*domain.StatsResponse, error) {
+	ctx, span := tracer.Start(ctx, "Service.GetStats")
+	defer span.End()
+
+	userStats, err := s.repo.GetPRReviewersStats(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	prStats, err := s.repo.GetDetailedPRStats()
+	prStats, err := s.repo.GetDetailedPRStats(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	teamStats, err := s.repo.GetTeamStats()
+	teamStats, err := s.repo.GetTeamStats(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -85,6 +347,30 @@ func (s *Service) GetStats() (*domain.StatsResponse, error) {
 	}, nil
 }
 
+func (s *Service) GetPairingStats(ctx context.Context, teamName string) (*domain.PairingMatrix, error) {
+	ctx, span := tracer.Start(ctx, "Service.GetPairingStats")
+	defer span.End()
+
+	pairings, err := s.repo.GetTeamPairingStats(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+
+	matrix := make(map[string]map[string]int)
+	for _, pairing := range pairings {
+		if matrix[pairing.AuthorID] == nil {
+			matrix[pairing.AuthorID] = make(map[string]int)
+		}
+		matrix[pairing.AuthorID][pairing.ReviewerID] = pairing.Count
+	}
+
+	return &domain.PairingMatrix{
+		TeamName: teamName,
+		Pairings: pairings,
+		Matrix:   matrix,
+	}, nil
+}
+
 func (s *Service) calculateSummary(userStats []*domain.UserStats, prStats []*domain.PRStats, teamStats []*domain.TeamStats) (*domain.StatsSummary, error) {
 	summary := &domain.StatsSummary{}
 
@@ -105,6 +391,8 @@ func (s *Service) calculateSummary(userStats []*domain.UserStats, prStats []*dom
 			summary.OpenPRs++
 		} else if pr.Status == "MERGED" {
 			summary.MergedPRs++
+		} else if pr.Status == "CLOSED" {
+			summary.ClosedPRs++
 		}
 	}
 
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"avito-tech-internship/internal/domain"
+	"avito-tech-internship/internal/metrics"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// Верхняя граница SLA команды
+const maxReviewSLA = 30 * 24 * time.Hour
+
+func (s *Service) SetTeamReviewSLA(ctx context.Context, req *domain.SetTeamReviewSLARequest) (*domain.TeamReviewSLA, error) {
+	ctx, span := tracer.Start(ctx, "Service.SetTeamReviewSLA")
+	defer span.End()
+
+	var sla *time.Duration
+	if req.SLA != "" {
+		d, err := time.ParseDuration(req.SLA)
+		if err != nil || d < time.Minute || d > maxReviewSLA {
+			return nil, errors.New("INVALID_SLA")
+		}
+		sla = &d
+	}
+	if err := s.repo.SetTeamReviewSLA(ctx, req.TeamName, sla, req.AutoReassign); err != nil {
+		return nil, err
+	}
+	return s.GetTeamReviewSLA(ctx, req.TeamName)
+}
+
+func (s *Service) GetTeamReviewSLA(ctx context.Context, teamName string) (*domain.TeamReviewSLA, error) {
+	ctx, span := tracer.Start(ctx, "Service.GetTeamReviewSLA")
+	defer span.End()
+
+	sla, autoReassign, err := s.repo.GetTeamReviewSLA(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+	result := &domain.TeamReviewSLA{
+		TeamName:     teamName,
+		Effective:    s.cfg.ReviewSLA.String(),
+		AutoReassign: autoReassign,
+	}
+	if sla != nil {
+		result.SLA = sla.String()
+		result.Effective = sla.String()
+	}
+	return result, nil
+}
+
+// RespondToReview отмечает, что ревьюер ответил на PR: SLA по этому назначению больше не отсчитывается
+func (s *Service) RespondToReview(ctx context.Context, req *domain.RespondReviewRequest) (*domain.PullRequest, error) {
+	ctx, span := tracer.Start(ctx, "Service.RespondToReview")
+	defer span.End()
+
+	pr, err := s.repo.GetPullRequestByID(ctx, req.PRID)
+	if err != nil {
+		return nil, err
+	}
+	if pr.Status != "OPEN" {
+		return nil, errors.New("PR_NOT_OPEN")
+	}
+	if err := s.repo.MarkReviewResponded(ctx, req.PRID, req.UserID); err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+// GetOverdueReviews возвращает просроченные ревью, пустой teamName - по всем командам
+func (s *Service) GetOverdueReviews(ctx context.Context, teamName string) ([]*domain.OverdueReview, error) {
+	ctx, span := tracer.Start(ctx, "Service.GetOverdueReviews")
+	defer span.End()
+
+	reviews, err := s.repo.GetOverdueReviews(ctx, s.cfg.ReviewSLA, teamName)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	for _, review := range reviews {
+		sla := time.Duration(review.SLASeconds) * time.Second
+		review.SLA = sla.String()
+		review.DueAt = review.AssignedAt.Add(sla)
+		review.OverdueBy = now.Sub(review.DueAt).Truncate(time.Minute).String()
+	}
+	return reviews, nil
+}
+
+// ScanReviewSLA записывает новые нарушения SLA и, если команда включила автопереназначение,
+// заменяет просрочившего ревьюера так же, как /pullRequest/reassign.
+// Нарушение записывается один раз на назначение, поэтому сканер можно запускать на всех репликах
+func (s *Service) ScanReviewSLA(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "Service.ScanReviewSLA")
+	defer span.End()
+
+	reviews, err := s.repo.GetOverdueReviews(ctx, s.cfg.ReviewSLA, "")
+	if err != nil {
+		return err
+	}
+	for _, review := range reviews {
+		recorded, err := s.repo.RecordSLABreach(ctx, review)
+		if err != nil {
+			return err
+		}
+		if !recorded {
+			continue
+		}
+		metrics.SLABreach(domain.SLABreachFlagged)
+		slog.Warn("review SLA breached", "pr", review.PRID, "reviewer", review.ReviewerID, "team", review.TeamName,
+			"assigned_at", review.AssignedAt, "sla", time.Duration(review.SLASeconds)*time.Second)
+
+		if !review.AutoReassign {
+			continue
+		}
+		_, newReviewerID, err := s.ReassignReviewer(ctx, review.PRID, review.ReviewerID)
+		if err != nil {
+			// Например, NO_CANDIDATE или CAPACITY_EXHAUSTED: нарушение остается отмеченным
+			slog.Warn("could not reassign overdue reviewer", "pr", review.PRID, "reviewer", review.ReviewerID, "error", err)
+			continue
+		}
+		if err := s.repo.SetSLABreachReassigned(ctx, review, newReviewerID); err != nil {
+			return err
+		}
+		metrics.SLABreach(domain.SLABreachReassigned)
+		slog.Info("overdue reviewer reassigned", "pr", review.PRID, "old_reviewer", review.ReviewerID, "new_reviewer", newReviewerID)
+	}
+	return nil
+}
+
+// GetSLAStats возвращает нарушения SLA за последние days дней и число просроченных ревью сейчас
+func (s *Service) GetSLAStats(ctx context.Context, days int) (*domain.SLAStats, error) {
+	ctx, span := tracer.Start(ctx, "Service.GetSLAStats")
+	defer span.End()
+
+	if days <= 0 || days > 365 {
+		return nil, errors.New("INVALID_PERIOD")
+	}
+	since := time.Now().UTC().AddDate(0, 0, -days)
+	teams, reviewers, err := s.repo.GetSLABreachStats(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+	overdue, err := s.repo.GetOverdueReviews(ctx, s.cfg.ReviewSLA, "")
+	if err != nil {
+		return nil, err
+	}
+	return &domain.SLAStats{
+		Since:            since,
+		CurrentlyOverdue: len(overdue),
+		Teams:            teams,
+		Reviewers:        reviewers,
+	}, nil
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"avito-tech-internship/internal/domain"
+	"avito-tech-internship/internal/metrics"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// GetStaleDryRun показывает, какие PR политика устаревания пометит и закроет при следующем запуске, ничего не меняя
+func (s *Service) GetStaleDryRun(ctx context.Context) (*domain.StaleReport, error) {
+	ctx, span := tracer.Start(ctx, "Service.GetStaleDryRun")
+	defer span.End()
+
+	mark, closing, err := s.repo.GetStalePullRequests(ctx, s.cfg.StaleMarkAfter, s.cfg.StaleCloseAfter)
+	if err != nil {
+		return nil, err
+	}
+	report := &domain.StaleReport{
+		MarkAfter:  s.cfg.StaleMarkAfter.String(),
+		WouldMark:  mark,
+		WouldClose: closing,
+	}
+	if s.cfg.StaleCloseAfter > 0 {
+		report.CloseAfter = s.cfg.StaleCloseAfter.String()
+	}
+	setInactiveFor(mark)
+	setInactiveFor(closing)
+	return report, nil
+}
+
+// ScanStalePullRequests закрывает PR, которые достаточно долго пробыли устаревшими, и помечает новые.
+// Закрытие идет первым, поэтому PR не закрывается в тот же запуск, в котором помечен.
+// Обновления условные, поэтому запуск на нескольких репликах не дублирует действия
+func (s *Service) ScanStalePullRequests(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "Service.ScanStalePullRequests")
+	defer span.End()
+
+	if s.cfg.StaleCloseAfter > 0 {
+		closed, err := s.repo.CloseStalePullRequests(ctx, s.cfg.StaleMarkAfter, s.cfg.StaleCloseAfter)
+		if err != nil {
+			return err
+		}
+		for _, pr := range closed {
+			metrics.StalePR(domain.StaleActionClosed)
+			slog.Info("stale pull request closed", "pr", pr.PRID, "author", pr.AuthorID, "last_activity_at", pr.LastActivityAt)
+		}
+	}
+
+	marked, err := s.repo.MarkStalePullRequests(ctx, s.cfg.StaleMarkAfter)
+	if err != nil {
+		return err
+	}
+	for _, pr := range marked {
+		metrics.StalePR(domain.StaleActionMarked)
+		slog.Info("pull request marked stale", "pr", pr.PRID, "author", pr.AuthorID, "last_activity_at", pr.LastActivityAt)
+	}
+	return nil
+}
+
+// SetStaleExempt исключает открытый PR из политики устаревания или возвращает его. Доступно только автору PR
+func (s *Service) SetStaleExempt(ctx context.Context, req *domain.SetStaleExemptRequest) (*domain.PullRequest, error) {
+	ctx, span := tracer.Start(ctx, "Service.SetStaleExempt")
+	defer span.End()
+
+	pr, err := s.repo.GetPullRequestByID(ctx, req.PRID)
+	if err != nil {
+		return nil, err
+	}
+	if pr.AuthorId != req.UserID {
+		return nil, errors.New("NOT_AUTHOR")
+	}
+	if pr.Status != "OPEN" {
+		return nil, errors.New("PR_NOT_OPEN")
+	}
+	if err := s.repo.SetPRStaleExempt(ctx, req.PRID, req.Exempt); err != nil {
+		return nil, err
+	}
+	return s.repo.GetPullRequestByID(ctx, req.PRID)
+}
+
+func setInactiveFor(prs []*domain.StalePR) {
+	now := time.Now().UTC()
+	for _, pr := range prs {
+		pr.InactiveFor = now.Sub(pr.LastActivityAt).Truncate(time.Hour).String()
+	}
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"avito-tech-internship/internal/domain"
+	"context"
+	"crypto/rand"
+	"errors"
+	"net/url"
+)
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 500
+)
+
+func (s *Service) CreateWebhookSubscription(ctx context.Context, req *domain.CreateWebhookRequest) (*domain.WebhookSubscription, error) {
+	ctx, span := tracer.Start(ctx, "Service.CreateWebhookSubscription")
+	defer span.End()
+
+	target, err := url.Parse(req.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, errors.New("INVALID_URL")
+	}
+	if len(req.Events) == 0 {
+		return nil, errors.New("INVALID_EVENTS")
+	}
+	for _, eventType := range req.Events {
+		if !domain.IsEventType(eventType) {
+			return nil, errors.New("INVALID_EVENTS")
+		}
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		secret = "whsec_" + rand.Text()
+	}
+	sub := &domain.WebhookSubscription{URL: req.URL, Secret: secret, Events: req.Events}
+	if err := s.repo.CreateWebhookSubscription(ctx, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+func (s *Service) GetWebhookSubscriptions(ctx context.Context) ([]*domain.WebhookSubscription, error) {
+	ctx, span := tracer.Start(ctx, "Service.GetWebhookSubscriptions")
+	defer span.End()
+
+	return s.repo.GetWebhookSubscriptions(ctx)
+}
+
+func (s *Service) DeleteWebhookSubscription(ctx context.Context, id string) error {
+	ctx, span := tracer.Start(ctx, "Service.DeleteWebhookSubscription")
+	defer span.End()
+
+	return s.repo.DeleteWebhookSubscription(ctx, id)
+}
+
+func (s *Service) GetWebhookDeliveries(ctx context.Context, filter domain.WebhookDeliveryFilter) ([]*domain.WebhookDelivery, error) {
+	ctx, span := tracer.Start(ctx, "Service.GetWebhookDeliveries")
+	defer span.End()
+
+	switch filter.Status {
+	case "", domain.DeliveryPending, domain.DeliveryDelivered, domain.DeliveryDead:
+	default:
+		return nil, errors.New("INVALID_STATUS")
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultDeliveriesLimit
+	}
+	filter.Limit = min(filter.Limit, maxDeliveriesLimit)
+	return s.repo.GetWebhookDeliveries(ctx, filter)
+}
+
+func (s *Service) GetWebhookDelivery(ctx context.Context, id string) (*domain.WebhookDelivery, error) {
+	ctx, span := tracer.Start(ctx, "Service.GetWebhookDelivery")
+	defer span.End()
+
+	return s.repo.GetWebhookDelivery(ctx, id)
+}
+
+func (s *Service) RedeliverWebhook(ctx context.Context, id string) (*domain.WebhookDelivery, error) {
+	ctx, span := tracer.Start(ctx, "Service.RedeliverWebhook")
+	defer span.End()
+
+	if err := s.repo.RedeliverWebhook(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetWebhookDelivery(ctx, id)
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"avito-tech-internship/internal/domain"
+	"context"
+	"fmt"
+	"time"
+)
+
+// Digest методы
+
+// SetUserEmail задает адрес для сводки, пустой email удаляет адрес
+func (r *PostgresRepository) SetUserEmail(ctx context.Context, userID, email string, digest bool) error {
+	ctx, end := r.instrument(ctx, "SetUserEmail")
+	defer end()
+
+	result, err := r.db.ExecContext(ctx, "UPDATE users SET email = NULLIF($2, ''), email_digest = $3 WHERE id = $1", userID, email, digest)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+// GetDigestRecipients возвращает активных пользователей с адресом и включенной сводкой
+func (r *PostgresRepository) GetDigestRecipients(ctx context.Context) ([]*domain.DigestRecipient, error) {
+	ctx, end := r.instrument(ctx, "GetDigestRecipients")
+	defer end()
+
+	recipients := []*domain.DigestRecipient{}
+	query := `
+        SELECT id, username, email
+        FROM users
+        WHERE is_active AND email_digest AND email IS NOT NULL
+        ORDER BY id
+    `
+	err := r.db.SelectContext(ctx, &recipients, query)
+	return recipients, err
+}
+
+// ClaimDigest отмечает сводку пользователя за день. false - сводку уже отправила эта или другая реплика
+func (r *PostgresRepository) ClaimDigest(ctx context.Context, userID string, day time.Time) (bool, error) {
+	ctx, end := r.instrument(ctx, "ClaimDigest")
+	defer end()
+
+	result, err := r.db.ExecContext(ctx, `
+        INSERT INTO email_digests (user_id, digest_date) VALUES ($1, $2)
+        ON CONFLICT DO NOTHING
+    `, userID, day.Format(time.DateOnly))
+	if err != nil {
+		return false, err
+	}
+	rows, _ := result.RowsAffected()
+	return rows == 1, nil
+}
+
+// ReleaseDigest снимает отметку, если сводку не удалось отправить
+func (r *PostgresRepository) ReleaseDigest(ctx context.Context, userID string, day time.Time) error {
+	ctx, end := r.instrument(ctx, "ReleaseDigest")
+	defer end()
+
+	_, err := r.db.ExecContext(ctx, "DELETE FROM email_digests WHERE user_id = $1 AND digest_date = $2", userID, day.Format(time.DateOnly))
+	return err
+}
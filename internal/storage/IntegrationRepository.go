@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"avito-tech-internship/internal/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"strings"
+)
+
+// Integrations методы
+
+func (r *PostgresRepository) SetExternalUserMapping(ctx context.Context, mapping *domain.ExternalUserMapping) error {
+	ctx, end := r.instrument(ctx, "SetExternalUserMapping")
+	defer end()
+
+	var exists bool
+	err := r.db.GetContext(ctx, &exists, "SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", mapping.UserID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("user not found")
+	}
+
+	query := `
+        INSERT INTO external_user_mappings (provider, external_login, user_id)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (provider, external_login) DO UPDATE SET user_id = EXCLUDED.user_id
+        RETURNING created_at
+    `
+	mapping.ExternalLogin = strings.ToLower(mapping.ExternalLogin)
+	return r.db.GetContext(ctx, &mapping.CreatedAt, query, mapping.Provider, mapping.ExternalLogin, mapping.UserID)
+}
+
+func (r *PostgresRepository) DeleteExternalUserMapping(ctx context.Context, provider, externalLogin string) error {
+	ctx, end := r.instrument(ctx, "DeleteExternalUserMapping")
+	defer end()
+
+	result, err := r.db.ExecContext(ctx, "DELETE FROM external_user_mappings WHERE provider = $1 AND external_login = $2",
+		provider, strings.ToLower(externalLogin))
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("mapping not found")
+	}
+	return nil
+}
+
+// GetExternalUserMappings возвращает соответствия логинов, пустой provider - всех провайдеров
+func (r *PostgresRepository) GetExternalUserMappings(ctx context.Context, provider string) ([]*domain.ExternalUserMapping, error) {
+	ctx, end := r.instrument(ctx, "GetExternalUserMappings")
+	defer end()
+
+	mappings := []*domain.ExternalUserMapping{}
+	query := `
+        SELECT provider, external_login, user_id, created_at
+        FROM external_user_mappings
+        WHERE $1 = '' OR provider = $1
+        ORDER BY provider, external_login
+    `
+	err := r.db.SelectContext(ctx, &mappings, query, provider)
+	return mappings, err
+}
+
+// ResolveExternalUser возвращает id пользователя по логину во внешней системе
+func (r *PostgresRepository) ResolveExternalUser(ctx context.Context, provider, externalLogin string) (string, error) {
+	ctx, end := r.instrument(ctx, "ResolveExternalUser")
+	defer end()
+
+	var userID string
+	err := r.db.GetContext(ctx, &userID, "SELECT user_id FROM external_user_mappings WHERE provider = $1 AND external_login = $2",
+		provider, strings.ToLower(externalLogin))
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("mapping not found")
+	}
+	return userID, err
+}
+
+func (r *PostgresRepository) GetIntegrationDelivery(ctx context.Context, provider, deliveryID string) (*domain.IntegrationDelivery, error) {
+	ctx, end := r.instrument(ctx, "GetIntegrationDelivery")
+	defer end()
+
+	var delivery domain.IntegrationDelivery
+	query := `
+        SELECT provider, delivery_id, event, action, pull_request_id, result, received_at
+        FROM integration_deliveries
+        WHERE provider = $1 AND delivery_id = $2
+    `
+	err := r.db.GetContext(ctx, &delivery, query, provider, deliveryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("delivery not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// RecordIntegrationDelivery запоминает обработанную доставку. Если ее уже записал параллельный запрос,
+// ничего не делает
+func (r *PostgresRepository) RecordIntegrationDelivery(ctx context.Context, delivery *domain.IntegrationDelivery) error {
+	ctx, end := r.instrument(ctx, "RecordIntegrationDelivery")
+	defer end()
+
+	query := `
+        INSERT INTO integration_deliveries (provider, delivery_id, event, action, pull_request_id, result)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (provider, delivery_id) DO NOTHING
+        RETURNING received_at
+    `
+	err := r.db.GetContext(ctx, &delivery.ReceivedAt, query,
+		delivery.Provider, delivery.DeliveryID, delivery.Event, delivery.Action, delivery.PRID, delivery.Result)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	return err
+}
+
+// GetExternalLogins возвращает логины пользователей во внешней системе: user_id -> логин.
+// Пользователи без соответствия в результат не попадают
+func (r *PostgresRepository) GetExternalLogins(ctx context.Context, provider string, userIDs []string) (map[string]string, error) {
+	ctx, end := r.instrument(ctx, "GetExternalLogins")
+	defer end()
+
+	var rows []struct {
+		UserID        string `db:"user_id"`
+		ExternalLogin string `db:"external_login"`
+	}
+	query := `
+        SELECT DISTINCT ON (user_id) user_id, external_login
+        FROM external_user_mappings
+        WHERE provider = $1 AND user_id = ANY ($2)
+        ORDER BY user_id, created_at
+    `
+	err := r.db.SelectContext(ctx, &rows, query, provider, pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	logins := make(map[string]string, len(rows))
+	for _, row := range rows {
+		logins[row.UserID] = row.ExternalLogin
+	}
+	return logins, nil
+}
+
+// GetPRProvider возвращает провайдера, из вебхука которого создан PR, или пустую строку для PR из API
+func (r *PostgresRepository) GetPRProvider(ctx context.Context, prID string) (string, error) {
+	ctx, end := r.instrument(ctx, "GetPRProvider")
+	defer end()
+
+	var provider string
+	err := r.db.GetContext(ctx, &provider, "SELECT COALESCE(provider, '') FROM pull_requests WHERE id = $1", prID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("pull request not found")
+	}
+	return provider, err
+}
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"avito-tech-internship/internal/domain"
+	"context"
+	"database/sql/driver"
+	"log/slog"
+	"time"
+)
+
+// Первый ключ advisory lock заданий планировщика, второй - hashtext(имя задания)
+const jobLockClass int32 = 0x6a6f62
+
+// Сколько ждать освобождения блокировки задания
+const jobUnlockTimeout = 5 * time.Second
+
+const jobRunColumns = `id, job_name, trigger, scheduled_for, status, error, instance, started_at, finished_at, duration_ms`
+
+// Job методы
+
+// AcquireJobLock берет сессионную блокировку задания на отдельном соединении, которое держится до release.
+// Если реплика упадет, соединение закроется и блокировка освободится сама
+func (r *PostgresRepository) AcquireJobLock(ctx context.Context, name string) (func(), bool, error) {
+	ctx, end := r.instrument(ctx, "AcquireJobLock")
+	defer end()
+
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	var locked bool
+	err = conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1, hashtext($2))`, jobLockClass, name).Scan(&locked)
+	if err != nil || !locked {
+		conn.Close()
+		return nil, false, err
+	}
+
+	release := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), jobUnlockTimeout)
+		defer cancel()
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1, hashtext($2))`, jobLockClass, name); err != nil {
+			slog.Warn("could not release job lock, dropping connection", "job", name, "error", err)
+			// Соединение с удержанной блокировкой нельзя возвращать в пул
+			_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}
+	return release, true, nil
+}
+
+// StartJobRun записывает начало запуска. Вызывается под блокировкой задания, поэтому незавершенные
+// записи этого задания остались от упавших реплик и помечаются abandoned
+func (r *PostgresRepository) StartJobRun(ctx context.Context, run *domain.JobRun) (bool, error) {
+	ctx, end := r.instrument(ctx, "StartJobRun")
+	defer end()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+        UPDATE job_runs SET status = $2, finished_at = NOW(), error = 'replica stopped before the run finished'
+        WHERE job_name = $1 AND status = $3
+    `, run.JobName, domain.JobRunAbandoned, domain.JobRunRunning)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := tx.QueryxContext(ctx, `
+        INSERT INTO job_runs (job_name, trigger, scheduled_for, status, instance)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (job_name, scheduled_for) DO NOTHING
+        RETURNING id, started_at
+    `, run.JobName, run.Trigger, run.ScheduledFor, run.Status, run.Instance)
+	if err != nil {
+		return false, err
+	}
+	inserted := rows.Next()
+	if inserted {
+		err = rows.Scan(&run.ID, &run.StartedAt)
+	}
+	rows.Close()
+	if err != nil {
+		return false, err
+	}
+	if err = rows.Err(); err != nil {
+		return false, err
+	}
+	return inserted, tx.Commit()
+}
+
+func (r *PostgresRepository) FinishJobRun(ctx context.Context, run *domain.JobRun) error {
+	ctx, end := r.instrument(ctx, "FinishJobRun")
+	defer end()
+
+	return r.db.QueryRowxContext(ctx, `
+        UPDATE job_runs SET status = $2, error = $3, duration_ms = $4, finished_at = NOW()
+        WHERE id = $1
+        RETURNING finished_at
+    `, run.ID, run.Status, run.Error, run.DurationMs).Scan(&run.FinishedAt)
+}
+
+// GetLastJobRuns возвращает последний запуск каждого задания
+func (r *PostgresRepository) GetLastJobRuns(ctx context.Context) (map[string]*domain.JobRun, error) {
+	ctx, end := r.instrument(ctx, "GetLastJobRuns")
+	defer end()
+
+	var runs []*domain.JobRun
+	err := r.db.SelectContext(ctx, &runs, `
+        SELECT DISTINCT ON (job_name) `+jobRunColumns+`
+        FROM job_runs
+        ORDER BY job_name, started_at DESC, id DESC
+    `)
+	if err != nil {
+		return nil, err
+	}
+	last := make(map[string]*domain.JobRun, len(runs))
+	for _, run := range runs {
+		last[run.JobName] = run
+	}
+	return last, nil
+}
+
+// GetJobRuns возвращает историю запусков, новые первыми. Пустой jobName - по всем заданиям
+func (r *PostgresRepository) GetJobRuns(ctx context.Context, jobName string, limit int) ([]*domain.JobRun, error) {
+	ctx, end := r.instrument(ctx, "GetJobRuns")
+	defer end()
+
+	runs := []*domain.JobRun{}
+	err := r.db.SelectContext(ctx, &runs, `
+        SELECT `+jobRunColumns+`
+        FROM job_runs
+        WHERE $1 = '' OR job_name = $1
+        ORDER BY started_at DESC, id DESC
+        LIMIT $2
+    `, jobName, limit)
+	return runs, err
+}
+
+// DeleteHistoryBefore удаляет служебные записи старше before: завершенные запуски заданий,
+// опубликованные события outbox, доставленные вебхуки и уведомления в чат, принятые вебхуки интеграций и отметки сводок.
+// Возвращает число удаленных строк по таблицам
+func (r *PostgresRepository) DeleteHistoryBefore(ctx context.Context, before time.Time) (map[string]int64, error) {
+	ctx, end := r.instrument(ctx, "DeleteHistoryBefore")
+	defer end()
+
+	queries := []struct {
+		table, query string
+	}{
+		{"job_runs", `DELETE FROM job_runs WHERE status != 'running' AND started_at < $1`},
+		{"outbox", `DELETE FROM outbox WHERE published_at IS NOT NULL AND created_at < $1`},
+		{"webhook_deliveries", `DELETE FROM webhook_deliveries WHERE status = 'delivered' AND created_at < $1`},
+		{"chat_deliveries", `DELETE FROM chat_deliveries WHERE status = 'delivered' AND created_at < $1`},
+		{"integration_deliveries", `DELETE FROM integration_deliveries WHERE received_at < $1`},
+		{"email_digests", `DELETE FROM email_digests WHERE sent_at < $1`},
+	}
+	deleted := make(map[string]int64, len(queries))
+	for _, q := range queries {
+		result, err := r.db.ExecContext(ctx, q.query, before)
+		if err != nil {
+			return deleted, err
+		}
+		deleted[q.table], _ = result.RowsAffected()
+	}
+	return deleted, nil
+}
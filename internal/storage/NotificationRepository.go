@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"avito-tech-internship/internal/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Notifications методы
+
+func (r *PostgresRepository) SetUserNotifications(ctx context.Context, userID string, enabled bool) error {
+	ctx, end := r.instrument(ctx, "SetUserNotifications")
+	defer end()
+
+	result, err := r.db.ExecContext(ctx, "UPDATE users SET chat_notifications = $2 WHERE id = $1", userID, enabled)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+// SetTeamNotificationChannel задает канал команды, пустой webhookURL удаляет его
+func (r *PostgresRepository) SetTeamNotificationChannel(ctx context.Context, teamName, webhookURL, channel string) error {
+	ctx, end := r.instrument(ctx, "SetTeamNotificationChannel")
+	defer end()
+
+	teamID, err := r.GetTeamIDByName(ctx, teamName)
+	if err != nil {
+		return err
+	}
+
+	if webhookURL == "" {
+		_, err = r.db.ExecContext(ctx, "DELETE FROM team_notification_channels WHERE team_id = $1", teamID)
+		return err
+	}
+	query := `
+        INSERT INTO team_notification_channels (team_id, webhook_url, channel)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (team_id) DO UPDATE
+            SET webhook_url = EXCLUDED.webhook_url, channel = EXCLUDED.channel, updated_at = NOW()
+    `
+	_, err = r.db.ExecContext(ctx, query, teamID, webhookURL, channel)
+	return err
+}
+
+func (r *PostgresRepository) GetTeamNotificationChannel(ctx context.Context, teamName string) (*domain.TeamNotificationChannel, error) {
+	ctx, end := r.instrument(ctx, "GetTeamNotificationChannel")
+	defer end()
+
+	var ch domain.TeamNotificationChannel
+	query := `
+        SELECT t.name AS team_name, c.webhook_url, c.channel, c.updated_at
+        FROM team_notification_channels c
+        JOIN teams t ON t.id = c.team_id
+        WHERE t.name = $1
+    `
+	err := r.db.GetContext(ctx, &ch, query, teamName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("channel not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ch, nil
+}
+
+// GetNotificationRecipients возвращает пользователей с их каналом уведомлений
+func (r *PostgresRepository) GetNotificationRecipients(ctx context.Context, userIDs []string) ([]*domain.NotificationRecipient, error) {
+	ctx, end := r.instrument(ctx, "GetNotificationRecipients")
+	defer end()
+
+	recipients := []*domain.NotificationRecipient{}
+	query := `
+        SELECT u.id AS user_id, u.username, u.chat_notifications, ch.webhook_url, ch.channel
+        FROM users u
+        LEFT JOIN LATERAL (
+            SELECT c.webhook_url, c.channel
+            FROM team_memberships tm
+            JOIN team_notification_channels c ON c.team_id = tm.team_id
+            JOIN teams t ON t.id = tm.team_id
+            WHERE tm.user_id = u.id
+            ORDER BY tm.team_id = u.team_id DESC, t.name
+            LIMIT 1
+        ) ch ON TRUE
+        WHERE u.id = ANY ($1)
+    `
+	err := r.db.SelectContext(ctx, &recipients, query, pq.Array(userIDs))
+	return recipients, err
+}
+
+// EnqueueChatDeliveries ставит сообщения в очередь отправки. Повторная публикация того же события
+// не создает дублей: сообщение уникально по событию и каналу
+func (r *PostgresRepository) EnqueueChatDeliveries(ctx context.Context, deliveries []*domain.ChatDelivery) error {
+	ctx, end := r.instrument(ctx, "EnqueueChatDeliveries")
+	defer end()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, d := range deliveries {
+		_, err := tx.ExecContext(ctx, `
+            INSERT INTO chat_deliveries (event_id, event_type, webhook_url, channel, text)
+            VALUES ($1, $2, $3, $4, $5)
+            ON CONFLICT (event_id, webhook_url, channel) DO NOTHING
+        `, d.EventID, d.EventType, d.WebhookURL, d.Channel, d.Text)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ClaimChatDeliveries выбирает сообщения, которым пора уходить, и откладывает их на lease,
+// чтобы другие реплики не взяли те же сообщения. Если отправитель упадет, сообщение вернется в очередь после lease.
+func (r *PostgresRepository) ClaimChatDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*domain.ChatDelivery, error) {
+	ctx, end := r.instrument(ctx, "ClaimChatDeliveries")
+	defer end()
+
+	query := `
+        UPDATE chat_deliveries
+        SET next_attempt_at = NOW() + make_interval(secs => $2)
+        WHERE id IN (SELECT id
+                     FROM chat_deliveries
+                     WHERE status = 'pending'
+                       AND next_attempt_at <= NOW()
+                     ORDER BY next_attempt_at
+                     LIMIT $1 FOR UPDATE SKIP LOCKED)
+        RETURNING id, event_id, event_type, webhook_url, channel, text, attempts
+    `
+	deliveries := []*domain.ChatDelivery{}
+	err := r.db.SelectContext(ctx, &deliveries, query, limit, lease.Seconds())
+	return deliveries, err
+}
+
+// RecordChatAttempt обновляет состояние сообщения после попытки отправки.
+// retryIn - через сколько повторить, если сообщение остается в статусе pending.
+func (r *PostgresRepository) RecordChatAttempt(ctx context.Context, id int64, attempt int, status string, lastError *string, retryIn time.Duration) error {
+	ctx, end := r.instrument(ctx, "RecordChatAttempt")
+	defer end()
+
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE chat_deliveries
+        SET attempts        = $2,
+            status          = $3,
+            last_error      = $4,
+            next_attempt_at = NOW() + make_interval(secs => $5),
+            delivered_at    = CASE WHEN $3 = 'delivered' THEN NOW() END
+        WHERE id = $1
+    `, id, attempt, status, lastError, retryIn.Seconds())
+	return err
+}
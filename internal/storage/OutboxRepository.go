@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"avito-tech-internship/internal/domain"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Ключ advisory lock для relay: публикует только одна реплика, иначе порядок событий не гарантирован
+const outboxLockKey int64 = 0x72657669657732
+
+// insertOutbox пишет событие в outbox внутри транзакции изменения вместе с его участниками
+func insertOutbox(ctx context.Context, tx *sqlx.Tx, aggregateID string, event *domain.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO outbox (event_id, event_type, aggregate_id, payload, participants)
+        VALUES ($1, $2, $3, $4, $5)
+    `, event.ID, event.Type, aggregateID, payload, pq.Array(event.Participants()))
+	return err
+}
+
+// ClaimOutboxEvents выбирает до limit событий, которые пора публиковать, в порядке записи и откладывает
+// их на lease, чтобы их не взяла другая реплика. Если relay упадет, события вернутся в очередь после lease.
+// Событие выбирается, только если у его агрегата нет более раннего события, ждущего повтора или арендованного,
+// поэтому события одного агрегата публикуются по порядку, а заблокированный агрегат не задерживает остальные.
+// Публикация идет уже после коммита, advisory lock держится только на время выборки.
+func (r *PostgresRepository) ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]*domain.OutboxEvent, error) {
+	ctx, end := r.instrument(ctx, "ClaimOutboxEvents")
+	defer end()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Без блокировки две реплики могли бы одновременно взять соседние события одного агрегата
+	var locked bool
+	if err := tx.GetContext(ctx, &locked, "SELECT pg_try_advisory_xact_lock($1)", outboxLockKey); err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, nil
+	}
+
+	events := []*domain.OutboxEvent{}
+	err = tx.SelectContext(ctx, &events, `
+        WITH claimed AS (
+            UPDATE outbox
+            SET next_attempt_at = NOW() + make_interval(secs => $2)
+            WHERE id IN (SELECT o.id
+                         FROM outbox o
+                         WHERE o.published_at IS NULL
+                           AND o.dead_at IS NULL
+                           AND o.next_attempt_at <= NOW()
+                           AND NOT EXISTS (SELECT 1
+                                           FROM outbox p
+                                           WHERE p.aggregate_id = o.aggregate_id
+                                             AND p.id < o.id
+                                             AND p.published_at IS NULL
+                                             AND p.dead_at IS NULL
+                                             AND p.next_attempt_at > NOW())
+                         ORDER BY o.id
+                         LIMIT $1)
+            RETURNING id, event_id, event_type, aggregate_id, payload, attempts, created_at
+        )
+        SELECT * FROM claimed ORDER BY id
+    `, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	return events, tx.Commit()
+}
+
+// MarkOutboxPublished отмечает события опубликованными
+func (r *PostgresRepository) MarkOutboxPublished(ctx context.Context, ids []int64) error {
+	ctx, end := r.instrument(ctx, "MarkOutboxPublished")
+	defer end()
+
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE outbox
+        SET published_at = NOW(), last_error = NULL
+        WHERE id = ANY ($1)
+    `, pq.Array(ids))
+	return err
+}
+
+// RecordOutboxFailure увеличивает счетчик попыток события и откладывает его на retryIn.
+// dead - попытки исчерпаны: событие больше не публикуется, следующие события агрегата идут без него.
+func (r *PostgresRepository) RecordOutboxFailure(ctx context.Context, id int64, lastError string, dead bool, retryIn time.Duration) error {
+	ctx, end := r.instrument(ctx, "RecordOutboxFailure")
+	defer end()
+
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE outbox
+        SET attempts        = attempts + 1,
+            last_error      = $2,
+            next_attempt_at = NOW() + make_interval(secs => $4),
+            dead_at         = CASE WHEN $3 THEN NOW() END
+        WHERE id = $1
+    `, id, lastError, dead, retryIn.Seconds())
+	return err
+}
+
+// ReleaseOutboxEvents возвращает в очередь взятые, но не отправленные события без траты попытки
+func (r *PostgresRepository) ReleaseOutboxEvents(ctx context.Context, ids []int64) error {
+	ctx, end := r.instrument(ctx, "ReleaseOutboxEvents")
+	defer end()
+
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE outbox
+        SET next_attempt_at = NOW()
+        WHERE id = ANY ($1) AND published_at IS NULL
+    `, pq.Array(ids))
+	return err
+}
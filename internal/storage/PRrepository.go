@@ -2,23 +2,65 @@ package storage
 
 import (
 	"avito-tech-internship/internal/domain"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"strings"
+	"github.com/lib/pq"
 )
 
 // PR методы
-func (r *PostgresRepository) CreatePullRequest(pr *domain.PullRequest) error {
+
+// CreatePullRequest создает PR вместе с назначенными ревьюерами (pr.AssignedReviewers)
+// и событиями pr.created и reviewer.assigned в одной транзакции
+func (r *PostgresRepository) CreatePullRequest(ctx context.Context, pr *domain.PullRequest) error {
+	ctx, end := r.instrument(ctx, "CreatePullRequest")
+	defer end()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
-        INSERT INTO pull_requests (id, name, author_id, status) 
-        VALUES ($1, $2, $3, 'OPEN')
+        INSERT INTO pull_requests (id, name, author_id, status, provider) 
+        VALUES ($1, $2, $3, 'OPEN', NULLIF($4, ''))
+        RETURNING status, created_at
     `
-	_, err := r.db.Exec(query, pr.ID, pr.Name, pr.AuthorId)
-	return err
+	err = tx.QueryRowContext(ctx, query, pr.ID, pr.Name, pr.AuthorId, pr.Provider).Scan(&pr.Status, &pr.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	for _, reviewerID := range pr.AssignedReviewers {
+		_, err = tx.ExecContext(ctx, `INSERT INTO pull_request_reviewers (pull_request_id, user_id) VALUES ($1, $2)`, pr.ID, reviewerID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = insertOutbox(ctx, tx, pr.ID, domain.NewEvent(domain.EventPRCreated, pr)); err != nil {
+		return err
+	}
+	for _, reviewerID := range pr.AssignedReviewers {
+		event := domain.NewEvent(domain.EventReviewerAssigned, domain.ReviewerAssignedData{
+			PRID:       pr.ID,
+			AuthorID:   pr.AuthorId,
+			ReviewerID: reviewerID,
+		})
+		if err = insertOutbox(ctx, tx, pr.ID, event); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
-func (r *PostgresRepository) GetPullRequestByID(prID string) (*domain.PullRequest, error) {
+func (r *PostgresRepository) GetPullRequestByID(ctx context.Context, prID string) (*domain.PullRequest, error) {
+	ctx, end := r.instrument(ctx, "GetPullRequestByID")
+	defer end()
+
 	var pr domain.PullRequest
 	query := `
         SELECT 
@@ -27,10 +69,13 @@ func (r *PostgresRepository) GetPullRequestByID(prID string) (*domain.PullReques
             author_id,
             status, 
             created_at, 
-            merged_at 
+            merged_at,
+            closed_at,
+            stale_at,
+            stale_exempt
         FROM pull_requests WHERE id = $1
     `
-	err := r.db.Get(&pr, query, prID)
+	err := r.db.GetContext(ctx, &pr, query, prID)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, fmt.Errorf("pull request not found")
 	}
@@ -39,7 +84,7 @@ func (r *PostgresRepository) GetPullRequestByID(prID string) (*domain.PullReques
 	}
 
 	// Получаем ревьюеров
-	reviewers, err := r.GetPRReviewers(prID)
+	reviewers, err := r.GetPRReviewers(ctx, prID)
 	if err != nil {
 		return nil, err
 	}
@@ -48,63 +93,112 @@ func (r *PostgresRepository) GetPullRequestByID(prID string) (*domain.PullReques
 	return &pr, nil
 }
 
-func (r *PostgresRepository) MergePullRequest(prID string) error {
-	query := `
+func (r *PostgresRepository) MergePullRequest(ctx context.Context, prID string) error {
+	ctx, end := r.instrument(ctx, "MergePullRequest")
+	defer end()
+
+	// PR уже мерджен или не существует - ничего не делаем
+	return r.setPRStatus(ctx, prID, domain.EventPRMerged, `
         UPDATE pull_requests 
-        SET status = 'MERGED', merged_at = NOW() 
+        SET status = 'MERGED', merged_at = NOW(), closed_at = NULL
         WHERE id = $1 AND status != 'MERGED'
-    `
-	result, err := r.db.Exec(query, prID)
+    `)
+}
+
+// ClosePullRequest закрывает открытый PR без мерджа, для остальных статусов ничего не делает
+func (r *PostgresRepository) ClosePullRequest(ctx context.Context, prID string) error {
+	ctx, end := r.instrument(ctx, "ClosePullRequest")
+	defer end()
+
+	return r.setPRStatus(ctx, prID, domain.EventPRClosed, `
+        UPDATE pull_requests 
+        SET status = 'CLOSED', closed_at = NOW()
+        WHERE id = $1 AND status = 'OPEN'
+    `)
+}
+
+// ReopenPullRequest открывает закрытый PR, ревьюеры остаются прежними. Повторное открытие считается активностью
+func (r *PostgresRepository) ReopenPullRequest(ctx context.Context, prID string) error {
+	ctx, end := r.instrument(ctx, "ReopenPullRequest")
+	defer end()
+
+	return r.setPRStatus(ctx, prID, domain.EventPRReopened, `
+        UPDATE pull_requests 
+        SET status = 'OPEN', closed_at = NULL, last_activity_at = NOW(), stale_at = NULL
+        WHERE id = $1 AND status = 'CLOSED'
+    `)
+}
+
+// setPRStatus выполняет update и, если статус изменился, пишет событие с PR в outbox в той же транзакции
+func (r *PostgresRepository) setPRStatus(ctx context.Context, prID, eventType, update string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	rows, _ := result.RowsAffected()
-	if rows == 0 {
-		// PR уже мерджен или не существует
+	var pr domain.PullRequest
+	query := update + `
+        RETURNING id AS pull_request_id, name AS pull_request_name, author_id, status, created_at, merged_at, closed_at,
+            stale_at, stale_exempt
+    `
+	err = tx.GetContext(ctx, &pr, query, prID)
+	if errors.Is(err, sql.ErrNoRows) {
 		return nil
 	}
+	if err != nil {
+		return err
+	}
 
-	return nil
+	pr.AssignedReviewers = []string{}
+	err = tx.SelectContext(ctx, &pr.AssignedReviewers, `SELECT user_id FROM pull_request_reviewers WHERE pull_request_id = $1`, prID)
+	if err != nil {
+		return err
+	}
+	if err = insertOutbox(ctx, tx, prID, domain.NewEvent(eventType, &pr)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (r *PostgresRepository) PRExists(prID string) (bool, error) {
+func (r *PostgresRepository) PRExists(ctx context.Context, prID string) (bool, error) {
+	ctx, end := r.instrument(ctx, "PRExists")
+	defer end()
+
 	var exists bool
 	query := `SELECT EXISTS(SELECT 1 FROM pull_requests WHERE id = $1)`
-	err := r.db.Get(&exists, query, prID)
+	err := r.db.GetContext(ctx, &exists, query, prID)
 	return exists, err
 }
 
 // Reviewer методы
-func (r *PostgresRepository) AssignReviewers(prID string, reviewerIDs []string) error {
-	if len(reviewerIDs) == 0 {
-		return nil
-	}
+func (r *PostgresRepository) GetPRReviewers(ctx context.Context, prID string) ([]string, error) {
+	ctx, end := r.instrument(ctx, "GetPRReviewers")
+	defer end()
 
-	query := `INSERT INTO pull_request_reviewers (pull_request_id, user_id) VALUES ($1, $2)`
-	for _, reviewerID := range reviewerIDs {
-		_, err := r.db.Exec(query, prID, reviewerID)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func (r *PostgresRepository) GetPRReviewers(prID string) ([]string, error) {
 	var reviewers []string
 	query := `SELECT user_id FROM pull_request_reviewers WHERE pull_request_id = $1`
-	err := r.db.Select(&reviewers, query, prID)
+	err := r.db.SelectContext(ctx, &reviewers, query, prID)
 	return reviewers, err
 }
 
-func (r *PostgresRepository) ReplaceReviewer(prID, oldReviewerID, newReviewerID string) error {
+func (r *PostgresRepository) ReplaceReviewer(ctx context.Context, prID, oldReviewerID, newReviewerID string) error {
+	ctx, end := r.instrument(ctx, "ReplaceReviewer")
+	defer end()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
         UPDATE pull_request_reviewers 
-        SET user_id = $1 
+        SET user_id = $1, assigned_at = NOW(), responded_at = NULL
         WHERE pull_request_id = $2 AND user_id = $3
     `
-	result, err := r.db.Exec(query, newReviewerID, prID, oldReviewerID)
+	result, err := tx.ExecContext(ctx, query, newReviewerID, prID, oldReviewerID)
 	if err != nil {
 		return err
 	}
@@ -113,69 +207,101 @@ func (r *PostgresRepository) ReplaceReviewer(prID, oldReviewerID, newReviewerID
 	if rows == 0 {
 		return fmt.Errorf("reviewer not assigned to this PR")
 	}
-	return nil
+
+	var authorID string
+	if err = tx.GetContext(ctx, &authorID, `SELECT author_id FROM pull_requests WHERE id = $1`, prID); err != nil {
+		return err
+	}
+
+	event := domain.NewEvent(domain.EventReviewerReplaced, domain.ReviewerReplacedData{
+		PRID:          prID,
+		AuthorID:      authorID,
+		OldReviewerID: oldReviewerID,
+		NewReviewerID: newReviewerID,
+	})
+	if err = insertOutbox(ctx, tx, prID, event); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (r *PostgresRepository) GetUserAssignedPRs(userID string) ([]domain.PullRequestShort, error) {
+func (r *PostgresRepository) GetUserAssignedPRs(ctx context.Context, userID string) ([]domain.PullRequestShort, error) {
+	ctx, end := r.instrument(ctx, "GetUserAssignedPRs")
+	defer end()
+
 	var prs []domain.PullRequestShort
 	query := `
         SELECT 
             pr.id,
             pr.name,
             pr.author_id,
-            pr.status
+            pr.status,
+            pr.created_at
         FROM pull_requests pr
         JOIN pull_request_reviewers prr ON pr.id = prr.pull_request_id
         WHERE prr.user_id = $1
         ORDER BY pr.created_at DESC
     `
-	err := r.db.Select(&prs, query, userID)
+	err := r.db.SelectContext(ctx, &prs, query, userID)
 	return prs, err
 }
 
+// Количество открытых PR, где пользователь u уже ревьюер
+const openReviewsQuery = `
+    SELECT COUNT(*)
+    FROM pull_request_reviewers orr
+    JOIN pull_requests opr ON orr.pull_request_id = opr.id
+    WHERE orr.user_id = u.id AND opr.status = 'OPEN'
+`
+
 // Business logic helpers
-func (r *PostgresRepository) GetActiveTeamMembers(teamID string, excludeUserID string) ([]domain.User, error) {
-	var users []domain.User
+func (r *PostgresRepository) GetActiveTeamMembers(ctx context.Context, teamIDs []string, excludeUserID string) ([]domain.ReviewerCandidate, error) {
+	ctx, end := r.instrument(ctx, "GetActiveTeamMembers")
+	defer end()
+
+	var candidates []domain.ReviewerCandidate
+	// Пользователь может состоять в нескольких командах автора - берем наибольшую долю
 	query := `
         SELECT 
-            id,
-            username, 
-            is_active,
-            team_id
-        FROM users 
-        WHERE team_id = $1 AND is_active = true AND id != $2
-        ORDER BY id
+            u.id,
+            u.username, 
+            u.is_active,
+            u.team_id,
+            u.max_open_reviews,
+            MAX(COALESCE(tm.review_share, 1)) as review_share,
+            (` + openReviewsQuery + `) as open_reviews
+        FROM users u 
+        JOIN team_memberships tm ON tm.user_id = u.id
+        WHERE tm.team_id = ANY($1) AND u.is_active = true AND u.id != $2
+        GROUP BY u.id, u.username, u.is_active, u.team_id, u.max_open_reviews
+        ORDER BY u.id
     `
-	err := r.db.Select(&users, query, teamID, excludeUserID)
-	return users, err
+	err := r.db.SelectContext(ctx, &candidates, query, pq.Array(teamIDs), excludeUserID)
+	return candidates, err
 }
 
-func (r *PostgresRepository) GetRandomActiveTeamMember(teamID string, excludeUserIDs []string) (*domain.User, error) {
-	if len(excludeUserIDs) == 0 {
-		excludeUserIDs = []string{""}
-	}
+func (r *PostgresRepository) GetRandomActiveTeamMember(ctx context.Context, teamIDs []string, excludeUserIDs []string) (*domain.User, error) {
+	ctx, end := r.instrument(ctx, "GetRandomActiveTeamMember")
+	defer end()
 
-	placeholders := make([]string, len(excludeUserIDs))
-	args := []interface{}{teamID}
-	for i, id := range excludeUserIDs {
-		placeholders[i] = fmt.Sprintf("$%d", i+2)
-		args = append(args, id)
-	}
-
-	query := fmt.Sprintf(`
+	// Взвешенный случайный выбор: чем больше доля, тем выше шанс оказаться первым
+	query := `
         SELECT 
-            id,
-            username,
-            is_active,
-            team_id
-        FROM users 
-        WHERE team_id = $1 AND is_active = true AND id NOT IN (%s)
-        ORDER BY RANDOM()
+            u.id,
+            u.username,
+            u.is_active,
+            u.team_id
+        FROM users u 
+        JOIN team_memberships tm ON tm.user_id = u.id
+        WHERE tm.team_id = ANY($1) AND u.is_active = true AND NOT (u.id = ANY($2))
+            AND (u.max_open_reviews IS NULL OR (` + openReviewsQuery + `) < u.max_open_reviews)
+        GROUP BY u.id, u.username, u.is_active, u.team_id
+        ORDER BY -LN(1 - RANDOM()) / MAX(COALESCE(tm.review_share, 1))
         LIMIT 1
-    `, strings.Join(placeholders, ", "))
+    `
 
 	var user domain.User
-	err := r.db.Get(&user, query, args...)
+	err := r.db.GetContext(ctx, &user, query, pq.Array(teamIDs), pq.Array(excludeUserIDs))
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil // Нет доступных кандидатов
 	}
@@ -185,13 +311,35 @@ func (r *PostgresRepository) GetRandomActiveTeamMember(teamID string, excludeUse
 	return &user, nil
 }
 
-// Получить команду автора PR
-func (r *PostgresRepository) GetAuthorTeam(authorID string) (string, error) {
-	var teamID string
-	query := `SELECT team_id FROM users WHERE id = $1`
-	err := r.db.Get(&teamID, query, authorID)
+// Сколько раз каждый ревьюер участвовал в последних lastN PR автора
+func (r *PostgresRepository) GetRecentPairings(ctx context.Context, authorID, excludePRID string, lastN int) (map[string]int, error) {
+	ctx, end := r.instrument(ctx, "GetRecentPairings")
+	defer end()
+
+	var rows []struct {
+		ReviewerID string `db:"reviewer_id"`
+		Count      int    `db:"count"`
+	}
+	query := `
+        SELECT prr.user_id as reviewer_id, COUNT(*) as count
+        FROM (
+            SELECT id
+            FROM pull_requests
+            WHERE author_id = $1 AND id != $2
+            ORDER BY created_at DESC
+            LIMIT $3
+        ) recent
+        JOIN pull_request_reviewers prr ON prr.pull_request_id = recent.id
+        GROUP BY prr.user_id
+    `
+	err := r.db.SelectContext(ctx, &rows, query, authorID, excludePRID, lastN)
 	if err != nil {
-		return "", fmt.Errorf("author not found")
+		return nil, err
+	}
+
+	pairings := make(map[string]int, len(rows))
+	for _, row := range rows {
+		pairings[row.ReviewerID] = row.Count
 	}
-	return teamID, nil
+	return pairings, nil
 }
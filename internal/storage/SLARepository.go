@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"avito-tech-internship/internal/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SLA методы
+
+// SetTeamReviewSLA задает SLA команды, nil - значение по умолчанию
+func (r *PostgresRepository) SetTeamReviewSLA(ctx context.Context, teamName string, sla *time.Duration, autoReassign bool) error {
+	ctx, end := r.instrument(ctx, "SetTeamReviewSLA")
+	defer end()
+
+	var seconds *float64
+	if sla != nil {
+		s := sla.Seconds()
+		seconds = &s
+	}
+	result, err := r.db.ExecContext(ctx, `
+        UPDATE teams
+        SET review_sla = CASE WHEN $2::float8 IS NULL THEN NULL ELSE make_interval(secs => $2::float8) END,
+            sla_auto_reassign = $3
+        WHERE name = $1
+    `, teamName, seconds, autoReassign)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("team not found")
+	}
+	return nil
+}
+
+// GetTeamReviewSLA возвращает SLA команды (nil - по умолчанию) и флаг автопереназначения
+func (r *PostgresRepository) GetTeamReviewSLA(ctx context.Context, teamName string) (*time.Duration, bool, error) {
+	ctx, end := r.instrument(ctx, "GetTeamReviewSLA")
+	defer end()
+
+	var row struct {
+		Seconds      *float64 `db:"sla_seconds"`
+		AutoReassign bool     `db:"sla_auto_reassign"`
+	}
+	err := r.db.GetContext(ctx, &row, `
+        SELECT EXTRACT(EPOCH FROM review_sla)::float8 AS sla_seconds, sla_auto_reassign
+        FROM teams WHERE name = $1
+    `, teamName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, fmt.Errorf("team not found")
+	}
+	if err != nil || row.Seconds == nil {
+		return nil, row.AutoReassign, err
+	}
+	sla := time.Duration(*row.Seconds * float64(time.Second))
+	return &sla, row.AutoReassign, nil
+}
+
+// GetOverdueReviews возвращает назначения без ответа в открытых PR, по которым истек SLA команды автора.
+// Пустой teamName - по всем командам
+func (r *PostgresRepository) GetOverdueReviews(ctx context.Context, defaultSLA time.Duration, teamName string) ([]*domain.OverdueReview, error) {
+	ctx, end := r.instrument(ctx, "GetOverdueReviews")
+	defer end()
+
+	reviews := []*domain.OverdueReview{}
+	query := `
+        SELECT
+            prr.pull_request_id,
+            pr.name AS pull_request_name,
+            pr.author_id,
+            prr.user_id,
+            t.id AS team_id,
+            t.name AS team_name,
+            prr.assigned_at,
+            EXTRACT(EPOCH FROM COALESCE(t.review_sla, make_interval(secs => $1)))::bigint AS sla_seconds,
+            t.sla_auto_reassign
+        FROM pull_request_reviewers prr
+        JOIN pull_requests pr ON pr.id = prr.pull_request_id
+        JOIN users a ON a.id = pr.author_id
+        JOIN teams t ON t.id = a.team_id
+        WHERE pr.status = 'OPEN'
+          AND prr.responded_at IS NULL
+          AND prr.assigned_at + COALESCE(t.review_sla, make_interval(secs => $1)) < NOW()
+          AND ($2 = '' OR t.name = $2)
+        ORDER BY prr.assigned_at
+    `
+	err := r.db.SelectContext(ctx, &reviews, query, defaultSLA.Seconds(), teamName)
+	return reviews, err
+}
+
+// RecordSLABreach записывает нарушение. false - нарушение по этому назначению уже записано
+func (r *PostgresRepository) RecordSLABreach(ctx context.Context, review *domain.OverdueReview) (bool, error) {
+	ctx, end := r.instrument(ctx, "RecordSLABreach")
+	defer end()
+
+	result, err := r.db.ExecContext(ctx, `
+        INSERT INTO sla_breaches (pull_request_id, user_id, assigned_at, team_id, sla_seconds)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT DO NOTHING
+    `, review.PRID, review.ReviewerID, review.AssignedAt, review.TeamID, review.SLASeconds)
+	if err != nil {
+		return false, err
+	}
+	rows, _ := result.RowsAffected()
+	return rows == 1, nil
+}
+
+func (r *PostgresRepository) SetSLABreachReassigned(ctx context.Context, review *domain.OverdueReview, newReviewerID string) error {
+	ctx, end := r.instrument(ctx, "SetSLABreachReassigned")
+	defer end()
+
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE sla_breaches SET action = $4, new_reviewer_id = $5
+        WHERE pull_request_id = $1 AND user_id = $2 AND assigned_at = $3
+    `, review.PRID, review.ReviewerID, review.AssignedAt, domain.SLABreachReassigned, newReviewerID)
+	return err
+}
+
+// MarkReviewResponded останавливает SLA ревьюера. Ответ считается активностью по PR и снимает отметку устаревания
+func (r *PostgresRepository) MarkReviewResponded(ctx context.Context, prID, userID string) error {
+	ctx, end := r.instrument(ctx, "MarkReviewResponded")
+	defer end()
+
+	result, err := r.db.ExecContext(ctx, `
+        WITH responded AS (
+            UPDATE pull_request_reviewers SET responded_at = COALESCE(responded_at, NOW())
+            WHERE pull_request_id = $1 AND user_id = $2
+            RETURNING pull_request_id
+        )
+        UPDATE pull_requests SET last_activity_at = NOW(), stale_at = NULL
+        WHERE id IN (SELECT pull_request_id FROM responded)
+    `, prID, userID)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("reviewer not assigned to this PR")
+	}
+	return nil
+}
+
+// GetSLABreachStats считает нарушения с момента since по командам и ревьюерам
+func (r *PostgresRepository) GetSLABreachStats(ctx context.Context, since time.Time) ([]*domain.TeamSLAStats, []*domain.ReviewerSLAStats, error) {
+	ctx, end := r.instrument(ctx, "GetSLABreachStats")
+	defer end()
+
+	teams := []*domain.TeamSLAStats{}
+	err := r.db.SelectContext(ctx, &teams, `
+        SELECT COALESCE(t.name, '') AS team_name,
+               COUNT(*) AS breaches,
+               COUNT(*) FILTER (WHERE b.action = 'reassigned') AS reassigned
+        FROM sla_breaches b
+        LEFT JOIN teams t ON t.id = b.team_id
+        WHERE b.detected_at >= $1
+        GROUP BY t.name
+        ORDER BY breaches DESC, team_name
+    `, since)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reviewers := []*domain.ReviewerSLAStats{}
+	err = r.db.SelectContext(ctx, &reviewers, `
+        SELECT user_id, COUNT(*) AS breaches
+        FROM sla_breaches
+        WHERE detected_at >= $1
+        GROUP BY user_id
+        ORDER BY breaches DESC, user_id
+    `, since)
+	if err != nil {
+		return nil, nil, err
+	}
+	return teams, reviewers, nil
+}
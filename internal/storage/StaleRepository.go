@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"avito-tech-internship/internal/domain"
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+// Stale PR методы
+
+// Открытые PR, на которые действует политика устаревания
+const staleScopeCondition = `status = 'OPEN' AND NOT stale_exempt`
+
+// GetStalePullRequests возвращает PR, которые будут помечены устаревшими и закрыты при следующем запуске политики.
+// closeAfter 0 - автозакрытие выключено
+func (r *PostgresRepository) GetStalePullRequests(ctx context.Context, markAfter, closeAfter time.Duration) ([]*domain.StalePR, []*domain.StalePR, error) {
+	ctx, end := r.instrument(ctx, "GetStalePullRequests")
+	defer end()
+
+	mark := []*domain.StalePR{}
+	err := r.db.SelectContext(ctx, &mark, `
+        SELECT id AS pull_request_id, name AS pull_request_name, author_id, last_activity_at, stale_at
+        FROM pull_requests
+        WHERE `+staleScopeCondition+` AND stale_at IS NULL
+          AND last_activity_at < NOW() - make_interval(secs => $1)
+        ORDER BY last_activity_at
+    `, markAfter.Seconds())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	closing := []*domain.StalePR{}
+	if closeAfter <= 0 {
+		return mark, closing, nil
+	}
+	err = r.db.SelectContext(ctx, &closing, `
+        SELECT id AS pull_request_id, name AS pull_request_name, author_id, last_activity_at, stale_at
+        FROM pull_requests
+        WHERE `+staleScopeCondition+` AND stale_at < NOW() - make_interval(secs => $1)
+        ORDER BY last_activity_at
+    `, (closeAfter - markAfter).Seconds())
+	if err != nil {
+		return nil, nil, err
+	}
+	return mark, closing, nil
+}
+
+// MarkStalePullRequests помечает устаревшими PR без активности дольше markAfter.
+// Отметка, запись в журнал и событие pr.stale пишутся в одной транзакции; уже помеченные PR не затрагиваются
+func (r *PostgresRepository) MarkStalePullRequests(ctx context.Context, markAfter time.Duration) ([]*domain.StalePR, error) {
+	ctx, end := r.instrument(ctx, "MarkStalePullRequests")
+	defer end()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	marked := []*domain.StalePR{}
+	err = tx.SelectContext(ctx, &marked, `
+        UPDATE pull_requests SET stale_at = NOW()
+        WHERE `+staleScopeCondition+` AND stale_at IS NULL
+          AND last_activity_at < NOW() - make_interval(secs => $1)
+        RETURNING id AS pull_request_id, name AS pull_request_name, author_id, last_activity_at, stale_at
+    `, markAfter.Seconds())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pr := range marked {
+		err = tx.SelectContext(ctx, &pr.AssignedReviewers, `SELECT user_id FROM pull_request_reviewers WHERE pull_request_id = $1`, pr.PRID)
+		if err != nil {
+			return nil, err
+		}
+		if err = insertStaleAction(ctx, tx, pr.PRID, domain.StaleActionMarked, pr.LastActivityAt); err != nil {
+			return nil, err
+		}
+		if err = insertOutbox(ctx, tx, pr.PRID, domain.NewEvent(domain.EventPRStale, pr)); err != nil {
+			return nil, err
+		}
+	}
+	return marked, tx.Commit()
+}
+
+// CloseStalePullRequests закрывает PR, которые пробыли устаревшими closeAfter - markAfter.
+// Для каждого PR пишется запись в журнал и событие pr.closed, как при обычном закрытии
+func (r *PostgresRepository) CloseStalePullRequests(ctx context.Context, markAfter, closeAfter time.Duration) ([]*domain.StalePR, error) {
+	ctx, end := r.instrument(ctx, "CloseStalePullRequests")
+	defer end()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var rows []struct {
+		domain.PullRequest
+		LastActivityAt time.Time `db:"last_activity_at"`
+	}
+	err = tx.SelectContext(ctx, &rows, `
+        UPDATE pull_requests SET status = 'CLOSED', closed_at = NOW()
+        WHERE `+staleScopeCondition+` AND stale_at < NOW() - make_interval(secs => $1)
+        RETURNING id AS pull_request_id, name AS pull_request_name, author_id, status, created_at, merged_at, closed_at,
+            stale_at, stale_exempt, last_activity_at
+    `, (closeAfter - markAfter).Seconds())
+	if err != nil {
+		return nil, err
+	}
+
+	closed := make([]*domain.StalePR, 0, len(rows))
+	for _, row := range rows {
+		pr := row.PullRequest
+		pr.AssignedReviewers = []string{}
+		err = tx.SelectContext(ctx, &pr.AssignedReviewers, `SELECT user_id FROM pull_request_reviewers WHERE pull_request_id = $1`, pr.ID)
+		if err != nil {
+			return nil, err
+		}
+		if err = insertStaleAction(ctx, tx, pr.ID, domain.StaleActionClosed, row.LastActivityAt); err != nil {
+			return nil, err
+		}
+		if err = insertOutbox(ctx, tx, pr.ID, domain.NewEvent(domain.EventPRClosed, &pr)); err != nil {
+			return nil, err
+		}
+		closed = append(closed, &domain.StalePR{
+			PRID:           pr.ID,
+			PRName:         pr.Name,
+			AuthorID:       pr.AuthorId,
+			LastActivityAt: row.LastActivityAt,
+			StaleAt:        pr.StaleAt,
+		})
+	}
+	return closed, tx.Commit()
+}
+
+// SetPRStaleExempt исключает PR из политики устаревания или возвращает его. Исключение снимает отметку устаревания
+func (r *PostgresRepository) SetPRStaleExempt(ctx context.Context, prID string, exempt bool) error {
+	ctx, end := r.instrument(ctx, "SetPRStaleExempt")
+	defer end()
+
+	result, err := r.db.ExecContext(ctx, `
+        UPDATE pull_requests
+        SET stale_exempt = $2, stale_at = CASE WHEN $2 THEN NULL ELSE stale_at END
+        WHERE id = $1
+    `, prID, exempt)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("pull request not found")
+	}
+	return nil
+}
+
+// insertStaleAction записывает действие политики в журнал stale_pr_actions
+func insertStaleAction(ctx context.Context, tx *sqlx.Tx, prID, action string, lastActivityAt time.Time) error {
+	_, err := tx.ExecContext(ctx, `
+        INSERT INTO stale_pr_actions (pull_request_id, action, last_activity_at)
+        VALUES ($1, $2, $3)
+    `, prID, action, lastActivityAt)
+	return err
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"avito-tech-internship/internal/domain"
+	"context"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Stream методы
+
+// GetLatestOutboxID возвращает id последнего события outbox, 0 - событий нет
+func (r *PostgresRepository) GetLatestOutboxID(ctx context.Context) (int64, error) {
+	ctx, end := r.instrument(ctx, "GetLatestOutboxID")
+	defer end()
+
+	var id int64
+	err := r.db.GetContext(ctx, &id, `SELECT COALESCE(MAX(id), 0) FROM outbox`)
+	return id, err
+}
+
+// GetOutboxIDBounds возвращает первый id, который еще есть в журнале outbox, и id, который получит следующее событие.
+// В пустом журнале first = next
+func (r *PostgresRepository) GetOutboxIDBounds(ctx context.Context) (first, next int64, err error) {
+	ctx, end := r.instrument(ctx, "GetOutboxIDBounds")
+	defer end()
+
+	var bounds struct {
+		First int64 `db:"first"`
+		Next  int64 `db:"next"`
+	}
+	query := `
+        SELECT COALESCE((SELECT MIN(id) FROM outbox), s.next) AS first, s.next
+        FROM (SELECT CASE WHEN is_called THEN last_value + 1 ELSE last_value END AS next FROM outbox_id_seq) s
+    `
+	err = r.db.GetContext(ctx, &bounds, query)
+	return bounds.First, bounds.Next, err
+}
+
+// GetStreamEvents возвращает до limit событий после afterID по порядку id, отмечая, какие проходят фильтр.
+// Фильтр по пользователю и команде проверяет участников, записанных вместе с событием, а не текущее состояние PR.
+// settled - событие записано раньше, чем settleAfter назад
+func (r *PostgresRepository) GetStreamEvents(ctx context.Context, afterID int64, filter domain.StreamFilter, limit int, settleAfter time.Duration) ([]*domain.StreamEvent, error) {
+	ctx, end := r.instrument(ctx, "GetStreamEvents")
+	defer end()
+
+	events := []*domain.StreamEvent{}
+	query := `
+        SELECT
+            o.id,
+            o.event_type,
+            o.payload,
+            (COALESCE(cardinality($2::text[]), 0) = 0 OR o.event_type = ANY($2))
+                AND ($3 = '' OR $3 = ANY(o.participants))
+                AND ($4 = '' OR EXISTS (
+                    SELECT 1
+                    FROM team_memberships tm
+                    JOIN teams t ON t.id = tm.team_id
+                    WHERE t.name = $4 AND tm.user_id = ANY(o.participants)
+                )) AS matches,
+            o.created_at < NOW() - make_interval(secs => $6) AS settled
+        FROM outbox o
+        WHERE o.id > $1
+        ORDER BY o.id
+        LIMIT $5
+    `
+	err := r.db.SelectContext(ctx, &events, query,
+		afterID, pq.Array(filter.Types), filter.UserID, filter.TeamName, limit, settleAfter.Seconds())
+	return events, err
+}
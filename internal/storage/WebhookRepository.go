@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"avito-tech-internship/internal/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"time"
+)
+
+func (r *PostgresRepository) CreateWebhookSubscription(ctx context.Context, sub *domain.WebhookSubscription) error {
+	ctx, end := r.instrument(ctx, "CreateWebhookSubscription")
+	defer end()
+
+	query := `
+        INSERT INTO webhook_subscriptions (url, secret, events)
+        VALUES ($1, $2, $3)
+        RETURNING id, is_active, created_at
+    `
+	return r.db.QueryRowContext(ctx, query, sub.URL, sub.Secret, pq.Array(sub.Events)).
+		Scan(&sub.ID, &sub.IsActive, &sub.CreatedAt)
+}
+
+func (r *PostgresRepository) GetWebhookSubscriptions(ctx context.Context) ([]*domain.WebhookSubscription, error) {
+	ctx, end := r.instrument(ctx, "GetWebhookSubscriptions")
+	defer end()
+
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, url, events, is_active, created_at
+        FROM webhook_subscriptions
+        ORDER BY created_at
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []*domain.WebhookSubscription{}
+	for rows.Next() {
+		var sub domain.WebhookSubscription
+		if err := rows.Scan(&sub.ID, &sub.URL, pq.Array(&sub.Events), &sub.IsActive, &sub.CreatedAt); err != nil {
+			return nil, err
+		}
+		subs = append(subs, &sub)
+	}
+	return subs, rows.Err()
+}
+
+func (r *PostgresRepository) DeleteWebhookSubscription(ctx context.Context, id string) error {
+	ctx, end := r.instrument(ctx, "DeleteWebhookSubscription")
+	defer end()
+
+	result, err := r.db.ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return errors.New("subscription not found")
+	}
+	return nil
+}
+
+// EnqueueWebhookEvent создает доставку события для каждой активной подписки на его тип
+// Повторная публикация того же события не создает дублей доставки.
+func (r *PostgresRepository) EnqueueWebhookEvent(ctx context.Context, event *domain.OutboxEvent) error {
+	ctx, end := r.instrument(ctx, "EnqueueWebhookEvent")
+	defer end()
+
+	query := `
+        INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+        SELECT id, $1, $2, $3
+        FROM webhook_subscriptions
+        WHERE is_active AND $2 = ANY (events)
+        ON CONFLICT (subscription_id, event_id) DO NOTHING
+    `
+	_, err := r.db.ExecContext(ctx, query, event.EventID, event.EventType, []byte(event.Payload))
+	return err
+}
+
+// ClaimWebhookDeliveries выбирает доставки, которым пора уходить, и откладывает их на lease,
+// чтобы другие реплики не взяли те же доставки. Если отправитель упадет, доставка вернется в очередь после lease.
+func (r *PostgresRepository) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*domain.WebhookDelivery, error) {
+	ctx, end := r.instrument(ctx, "ClaimWebhookDeliveries")
+	defer end()
+
+	query := `
+        UPDATE webhook_deliveries d
+        SET next_attempt_at = NOW() + make_interval(secs => $2)
+        FROM webhook_subscriptions s
+        WHERE d.subscription_id = s.id
+          AND d.id IN (SELECT id
+                       FROM webhook_deliveries
+                       WHERE status = 'pending'
+                         AND next_attempt_at <= NOW()
+                       ORDER BY next_attempt_at
+                       LIMIT $1 FOR UPDATE SKIP LOCKED)
+        RETURNING d.id, d.subscription_id, d.event_id, d.event_type, d.payload, d.status,
+                  d.attempts, d.next_attempt_at, d.created_at, s.url, s.secret
+    `
+	deliveries := []*domain.WebhookDelivery{}
+	err := r.db.SelectContext(ctx, &deliveries, query, limit, lease.Seconds())
+	return deliveries, err
+}
+
+// RecordWebhookAttempt пишет попытку в журнал и обновляет состояние доставки.
+// retryIn - через сколько повторить, если доставка остается в статусе pending.
+func (r *PostgresRepository) RecordWebhookAttempt(ctx context.Context, attempt *domain.WebhookAttempt, status string, retryIn time.Duration) error {
+	ctx, end := r.instrument(ctx, "RecordWebhookAttempt")
+	defer end()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO webhook_delivery_attempts (delivery_id, attempt, status_code, error, duration_ms)
+        VALUES ($1, $2, $3, $4, $5)
+    `, attempt.DeliveryID, attempt.Attempt, attempt.StatusCode, attempt.Error, attempt.DurationMs)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        UPDATE webhook_deliveries
+        SET attempts         = $2,
+            status           = $3,
+            last_error       = $4,
+            last_status_code = $5,
+            next_attempt_at  = NOW() + make_interval(secs => $6),
+            delivered_at     = CASE WHEN $3 = 'delivered' THEN NOW() END
+        WHERE id = $1
+    `, attempt.DeliveryID, attempt.Attempt, status, attempt.Error, attempt.StatusCode, retryIn.Seconds())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PostgresRepository) GetWebhookDeliveries(ctx context.Context, filter domain.WebhookDeliveryFilter) ([]*domain.WebhookDelivery, error) {
+	ctx, end := r.instrument(ctx, "GetWebhookDeliveries")
+	defer end()
+
+	query := `
+        SELECT id, subscription_id, event_id, event_type, payload, status, attempts,
+               next_attempt_at, last_error, last_status_code, created_at, delivered_at
+        FROM webhook_deliveries
+        WHERE ($1 = '' OR subscription_id = $1)
+          AND ($2 = '' OR status = $2)
+        ORDER BY created_at DESC
+        LIMIT $3
+    `
+	deliveries := []*domain.WebhookDelivery{}
+	err := r.db.SelectContext(ctx, &deliveries, query, filter.SubscriptionID, filter.Status, filter.Limit)
+	return deliveries, err
+}
+
+func (r *PostgresRepository) GetWebhookDelivery(ctx context.Context, id string) (*domain.WebhookDelivery, error) {
+	ctx, end := r.instrument(ctx, "GetWebhookDelivery")
+	defer end()
+
+	var delivery domain.WebhookDelivery
+	err := r.db.GetContext(ctx, &delivery, `
+        SELECT id, subscription_id, event_id, event_type, payload, status, attempts,
+               next_attempt_at, last_error, last_status_code, created_at, delivered_at
+        FROM webhook_deliveries
+        WHERE id = $1
+    `, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("delivery not found")
+		}
+		return nil, err
+	}
+
+	delivery.AttemptLog = []*domain.WebhookAttempt{}
+	err = r.db.SelectContext(ctx, &delivery.AttemptLog, `
+        SELECT delivery_id, attempt, status_code, error, duration_ms, created_at
+        FROM webhook_delivery_attempts
+        WHERE delivery_id = $1
+        ORDER BY id
+    `, id)
+	return &delivery, err
+}
+
+// RedeliverWebhook возвращает доставку в очередь с новым набором попыток
+func (r *PostgresRepository) RedeliverWebhook(ctx context.Context, id string) error {
+	ctx, end := r.instrument(ctx, "RedeliverWebhook")
+	defer end()
+
+	result, err := r.db.ExecContext(ctx, `
+        UPDATE webhook_deliveries
+        SET status = 'pending', attempts = 0, next_attempt_at = NOW(), delivered_at = NULL
+        WHERE id = $1
+    `, id)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return errors.New("delivery not found")
+	}
+	return nil
+}
@@ -2,40 +2,139 @@ package storage
 
 import (
 	"avito-tech-internship/internal/domain"
+	"avito-tech-internship/internal/metrics"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"strings"
+	"time"
 )
 
 type Repository interface {
 	// Users
-	GetUserByID(userId string) (*domain.User, error)
-	SetUserActive(userId string, isActive bool) error
-	AddNewUser(user *domain.User) (*domain.User, error)
+	GetUserByID(ctx context.Context, userId string) (*domain.User, error)
+	SetUserActive(ctx context.Context, userId string, isActive bool) error
+	AddNewUser(ctx context.Context, user *domain.User) (*domain.User, error)
+	AddUserSkills(ctx context.Context, userID string, skills []string) error
+	RemoveUserSkills(ctx context.Context, userID string, skills []string) error
+	GetUserSkills(ctx context.Context, userID string) ([]string, error)
+	GetSkillMatches(ctx context.Context, userIDs []string, tags []string) (map[string]int, error)
+	GetAvailableUserIDs(ctx context.Context, userIDs []string) ([]string, error)
+	SetMaxOpenReviews(ctx context.Context, userID string, maxOpenReviews *int) error
 
 	//Teams
-	AddTeam(team *domain.Team) error
-	GetTeamByName(name string) (*domain.Team, error)
+	AddTeam(ctx context.Context, team *domain.Team, mode string) ([]*domain.TeamMemberResult, error)
+	GetTeamByName(ctx context.Context, name string) (*domain.Team, error)
+	AddTeamMember(ctx context.Context, teamName, userID string, reviewShare *float64) error
+	RemoveTeamMember(ctx context.Context, teamName, userID string) error
+	GetUserTeamIDs(ctx context.Context, userID string) ([]string, error)
+	SetTeamParent(ctx context.Context, teamName, parentTeamName string) error
+	GetTeamLinks(ctx context.Context) ([]domain.TeamLink, error)
+	GetParentTeamIDs(ctx context.Context, teamIDs []string) ([]string, error)
+	GetChildTeamIDs(ctx context.Context, teamIDs []string) ([]string, error)
+	GetTeamIDByName(ctx context.Context, teamName string) (string, error)
+	SetTeamCodeowners(ctx context.Context, teamName, content string) error
+	GetTeamCodeowners(ctx context.Context, teamName string) (string, error)
+	GetCodeownersByTeamIDs(ctx context.Context, teamIDs []string) ([]string, error)
+	SetPairingHistoryDepth(ctx context.Context, teamName string, depth int) error
+	GetPairingHistoryDepth(ctx context.Context, teamIDs []string) (int, error)
 
 	//PullRequests
-	CreatePullRequest(pr *domain.PullRequest) error
-	GetPullRequestByID(prID string) (*domain.PullRequest, error)
-	MergePullRequest(prID string) error
-	PRExists(prID string) (bool, error)
-	AssignReviewers(prID string, reviewerIDs []string) error
-	GetPRReviewers(prID string) ([]string, error)
-	ReplaceReviewer(prID, oldReviewerID, newReviewerID string) error
-	GetUserAssignedPRs(userID string) ([]domain.PullRequestShort, error)
-	GetActiveTeamMembers(teamID string, excludeUserID string) ([]domain.User, error)
-	GetRandomActiveTeamMember(teamID string, excludeUserIDs []string) (*domain.User, error)
-	GetAuthorTeam(authorID string) (string, error)
+	CreatePullRequest(ctx context.Context, pr *domain.PullRequest) error
+	GetPullRequestByID(ctx context.Context, prID string) (*domain.PullRequest, error)
+	MergePullRequest(ctx context.Context, prID string) error
+	ClosePullRequest(ctx context.Context, prID string) error
+	ReopenPullRequest(ctx context.Context, prID string) error
+	PRExists(ctx context.Context, prID string) (bool, error)
+	GetPRReviewers(ctx context.Context, prID string) ([]string, error)
+	ReplaceReviewer(ctx context.Context, prID, oldReviewerID, newReviewerID string) error
+	GetUserAssignedPRs(ctx context.Context, userID string) ([]domain.PullRequestShort, error)
+	GetActiveTeamMembers(ctx context.Context, teamIDs []string, excludeUserID string) ([]domain.ReviewerCandidate, error)
+	GetRandomActiveTeamMember(ctx context.Context, teamIDs []string, excludeUserIDs []string) (*domain.User, error)
+	GetRecentPairings(ctx context.Context, authorID, excludePRID string, lastN int) (map[string]int, error)
 
 	//Stats
-	GetPRReviewersStats() ([]*domain.UserStats, error)
-	GetDetailedPRStats() ([]*domain.PRStats, error)
-	GetTeamStats() ([]*domain.TeamStats, error)
+	GetPRReviewersStats(ctx context.Context) ([]*domain.UserStats, error)
+	GetDetailedPRStats(ctx context.Context) ([]*domain.PRStats, error)
+	GetTeamStats(ctx context.Context) ([]*domain.TeamStats, error)
+	GetTeamPairingStats(ctx context.Context, teamName string) ([]*domain.PairingStats, error)
+	GetTeamLoadStats(ctx context.Context) ([]*domain.TeamLoadStats, error)
+
+	//Integrations
+	SetExternalUserMapping(ctx context.Context, mapping *domain.ExternalUserMapping) error
+	DeleteExternalUserMapping(ctx context.Context, provider, externalLogin string) error
+	GetExternalUserMappings(ctx context.Context, provider string) ([]*domain.ExternalUserMapping, error)
+	ResolveExternalUser(ctx context.Context, provider, externalLogin string) (string, error)
+	GetExternalLogins(ctx context.Context, provider string, userIDs []string) (map[string]string, error)
+	GetPRProvider(ctx context.Context, prID string) (string, error)
+	GetIntegrationDelivery(ctx context.Context, provider, deliveryID string) (*domain.IntegrationDelivery, error)
+	RecordIntegrationDelivery(ctx context.Context, delivery *domain.IntegrationDelivery) error
+
+	//Notifications
+	SetUserNotifications(ctx context.Context, userID string, enabled bool) error
+	SetTeamNotificationChannel(ctx context.Context, teamName, webhookURL, channel string) error
+	GetTeamNotificationChannel(ctx context.Context, teamName string) (*domain.TeamNotificationChannel, error)
+	GetNotificationRecipients(ctx context.Context, userIDs []string) ([]*domain.NotificationRecipient, error)
+	EnqueueChatDeliveries(ctx context.Context, deliveries []*domain.ChatDelivery) error
+	ClaimChatDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*domain.ChatDelivery, error)
+	RecordChatAttempt(ctx context.Context, id int64, attempt int, status string, lastError *string, retryIn time.Duration) error
+
+	//Digest
+	SetUserEmail(ctx context.Context, userID, email string, digest bool) error
+	GetDigestRecipients(ctx context.Context) ([]*domain.DigestRecipient, error)
+	ClaimDigest(ctx context.Context, userID string, day time.Time) (bool, error)
+	ReleaseDigest(ctx context.Context, userID string, day time.Time) error
+
+	//SLA
+	SetTeamReviewSLA(ctx context.Context, teamName string, sla *time.Duration, autoReassign bool) error
+	GetTeamReviewSLA(ctx context.Context, teamName string) (*time.Duration, bool, error)
+	GetOverdueReviews(ctx context.Context, defaultSLA time.Duration, teamName string) ([]*domain.OverdueReview, error)
+	RecordSLABreach(ctx context.Context, review *domain.OverdueReview) (bool, error)
+	SetSLABreachReassigned(ctx context.Context, review *domain.OverdueReview, newReviewerID string) error
+	MarkReviewResponded(ctx context.Context, prID, userID string) error
+	GetSLABreachStats(ctx context.Context, since time.Time) ([]*domain.TeamSLAStats, []*domain.ReviewerSLAStats, error)
+
+	//Stale PRs
+	GetStalePullRequests(ctx context.Context, markAfter, closeAfter time.Duration) (mark, closing []*domain.StalePR, err error)
+	MarkStalePullRequests(ctx context.Context, markAfter time.Duration) ([]*domain.StalePR, error)
+	CloseStalePullRequests(ctx context.Context, markAfter, closeAfter time.Duration) ([]*domain.StalePR, error)
+	SetPRStaleExempt(ctx context.Context, prID string, exempt bool) error
+
+	//Jobs
+	AcquireJobLock(ctx context.Context, name string) (release func(), acquired bool, err error)
+	StartJobRun(ctx context.Context, run *domain.JobRun) (bool, error)
+	FinishJobRun(ctx context.Context, run *domain.JobRun) error
+	GetLastJobRuns(ctx context.Context) (map[string]*domain.JobRun, error)
+	GetJobRuns(ctx context.Context, jobName string, limit int) ([]*domain.JobRun, error)
+	DeleteHistoryBefore(ctx context.Context, before time.Time) (map[string]int64, error)
+
+	//Event stream
+	GetLatestOutboxID(ctx context.Context) (int64, error)
+	GetOutboxIDBounds(ctx context.Context) (first, next int64, err error)
+	GetStreamEvents(ctx context.Context, afterID int64, filter domain.StreamFilter, limit int, settleAfter time.Duration) ([]*domain.StreamEvent, error)
+
+	//Outbox
+	ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]*domain.OutboxEvent, error)
+	MarkOutboxPublished(ctx context.Context, ids []int64) error
+	RecordOutboxFailure(ctx context.Context, id int64, lastError string, dead bool, retryIn time.Duration) error
+	ReleaseOutboxEvents(ctx context.Context, ids []int64) error
+
+	//Webhooks
+	CreateWebhookSubscription(ctx context.Context, sub *domain.WebhookSubscription) error
+	GetWebhookSubscriptions(ctx context.Context) ([]*domain.WebhookSubscription, error)
+	DeleteWebhookSubscription(ctx context.Context, id string) error
+	EnqueueWebhookEvent(ctx context.Context, event *domain.OutboxEvent) error
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*domain.WebhookDelivery, error)
+	RecordWebhookAttempt(ctx context.Context, attempt *domain.WebhookAttempt, status string, retryIn time.Duration) error
+	GetWebhookDeliveries(ctx context.Context, filter domain.WebhookDeliveryFilter) ([]*domain.WebhookDelivery, error)
+	GetWebhookDelivery(ctx context.Context, id string) (*domain.WebhookDelivery, error)
+	RedeliverWebhook(ctx context.Context, id string) error
 }
 
 type PostgresRepository struct {
@@ -46,13 +145,40 @@ func NewPostgresRepository(db *sqlx.DB) *PostgresRepository {
 	return &PostgresRepository{db: db}
 }
 
+var tracer = otel.Tracer("avito-tech-internship/internal/storage")
+
+// instrument открывает span на метод репозитория и пишет его длительность в метрики
+func (r *PostgresRepository) instrument(ctx context.Context, method string) (context.Context, func()) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, "PostgresRepository."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system", "postgresql")),
+	)
+	return ctx, func() {
+		span.End()
+		metrics.ObserveDBQuery(method, start)
+	}
+}
+
 // Users
-func (r *PostgresRepository) AddNewUser(user *domain.User) (*domain.User, error) {
+func (r *PostgresRepository) AddNewUser(ctx context.Context, user *domain.User) (*domain.User, error) {
+	ctx, end := r.instrument(ctx, "AddNewUser")
+	defer end()
+
 	var teamID string
-	err := r.db.Get(&teamID, "SELECT id FROM teams WHERE name = $1", user.TeamName)
+	err := r.db.GetContext(ctx, &teamID, "SELECT id FROM teams WHERE name = $1", user.TeamName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("team not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("team not found: %w", err)
+		return nil, err
 	}
+	defer tx.Rollback()
 
 	query := `
         INSERT INTO users (id, username, is_active, team_id) 
@@ -61,7 +187,7 @@ func (r *PostgresRepository) AddNewUser(user *domain.User) (*domain.User, error)
     `
 
 	var newUser domain.User
-	err = r.db.QueryRow(query, user.UserId, user.Username, user.IsActive, teamID).
+	err = tx.QueryRowContext(ctx, query, user.UserId, user.Username, user.IsActive, teamID).
 		Scan(&newUser.UserId, &newUser.Username, &newUser.IsActive, &newUser.TeamId)
 
 	if err != nil {
@@ -71,15 +197,39 @@ func (r *PostgresRepository) AddNewUser(user *domain.User) (*domain.User, error)
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
+	_, err = tx.ExecContext(ctx, "INSERT INTO team_memberships (user_id, team_id) VALUES ($1, $2)", newUser.UserId, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create team membership: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
 	newUser.TeamName = user.TeamName
+	newUser.Teams = []*domain.TeamMembership{{TeamName: user.TeamName}}
 
 	return &newUser, nil
 }
 
-func (r *PostgresRepository) GetUserByID(userID string) (*domain.User, error) {
+func (r *PostgresRepository) GetUserByID(ctx context.Context, userID string) (*domain.User, error) {
+	ctx, end := r.instrument(ctx, "GetUserByID")
+	defer end()
+
 	var user domain.User
-	query := "SELECT * FROM users WHERE id = $1"
-	err := r.db.Get(&user, query, userID)
+	query := `
+        SELECT 
+            u.id,
+            u.username,
+            u.is_active,
+            u.team_id,
+            u.max_open_reviews,
+            t.name as team_name
+        FROM users u
+        JOIN teams t ON u.team_id = t.id
+        WHERE u.id = $1
+    `
+	err := r.db.GetContext(ctx, &user, query, userID)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, err
 	}
@@ -87,12 +237,114 @@ func (r *PostgresRepository) GetUserByID(userID string) (*domain.User, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
-	return &user, err
+
+	// Все команды пользователя
+	query = `
+        SELECT 
+            t.name as team_name,
+            tm.review_share
+        FROM team_memberships tm
+        JOIN teams t ON tm.team_id = t.id
+        WHERE tm.user_id = $1
+        ORDER BY t.name
+    `
+	err = r.db.SelectContext(ctx, &user.Teams, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user teams: %w", err)
+	}
+
+	user.Skills, err = r.GetUserSkills(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Skills
+func (r *PostgresRepository) AddUserSkills(ctx context.Context, userID string, skills []string) error {
+	ctx, end := r.instrument(ctx, "AddUserSkills")
+	defer end()
+
+	query := `
+        INSERT INTO user_skills (user_id, tag)
+        SELECT $1, UNNEST($2::text[])
+        ON CONFLICT DO NOTHING
+    `
+	_, err := r.db.ExecContext(ctx, query, userID, pq.Array(skills))
+	return err
+}
+
+func (r *PostgresRepository) RemoveUserSkills(ctx context.Context, userID string, skills []string) error {
+	ctx, end := r.instrument(ctx, "RemoveUserSkills")
+	defer end()
+
+	query := `DELETE FROM user_skills WHERE user_id = $1 AND tag = ANY($2)`
+	_, err := r.db.ExecContext(ctx, query, userID, pq.Array(skills))
+	return err
+}
+
+func (r *PostgresRepository) GetUserSkills(ctx context.Context, userID string) ([]string, error) {
+	ctx, end := r.instrument(ctx, "GetUserSkills")
+	defer end()
+
+	skills := []string{}
+	query := `SELECT tag FROM user_skills WHERE user_id = $1 ORDER BY tag`
+	err := r.db.SelectContext(ctx, &skills, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user skills: %w", err)
+	}
+	return skills, nil
+}
+
+func (r *PostgresRepository) GetSkillMatches(ctx context.Context, userIDs []string, tags []string) (map[string]int, error) {
+	ctx, end := r.instrument(ctx, "GetSkillMatches")
+	defer end()
+
+	var rows []struct {
+		UserID  string `db:"user_id"`
+		Matches int    `db:"matches"`
+	}
+	query := `
+        SELECT user_id, COUNT(*) as matches
+        FROM user_skills
+        WHERE user_id = ANY($1) AND tag = ANY($2)
+        GROUP BY user_id
+    `
+	err := r.db.SelectContext(ctx, &rows, query, pq.Array(userIDs), pq.Array(tags))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get skill matches: %w", err)
+	}
+
+	matches := make(map[string]int, len(rows))
+	for _, row := range rows {
+		matches[row.UserID] = row.Matches
+	}
+	return matches, nil
 }
 
-func (r *PostgresRepository) SetUserActive(userID string, iaActive bool) error {
-	query := "UPDATE users SET is_active = $1 WHERE id = $2"
-	result, err := r.db.Exec(query, iaActive, userID)
+// Возвращает только существующих активных пользователей, не достигших лимита ревью, сохраняя порядок
+func (r *PostgresRepository) GetAvailableUserIDs(ctx context.Context, userIDs []string) ([]string, error) {
+	ctx, end := r.instrument(ctx, "GetAvailableUserIDs")
+	defer end()
+
+	var availableIDs []string
+	query := `
+        SELECT u.id
+        FROM UNNEST($1::text[]) WITH ORDINALITY AS ids(id, ord)
+        JOIN users u ON u.id = ids.id
+        WHERE u.is_active = true AND (u.max_open_reviews IS NULL OR (` + openReviewsQuery + `) < u.max_open_reviews)
+        ORDER BY ids.ord
+    `
+	err := r.db.SelectContext(ctx, &availableIDs, query, pq.Array(userIDs))
+	return availableIDs, err
+}
+
+func (r *PostgresRepository) SetMaxOpenReviews(ctx context.Context, userID string, maxOpenReviews *int) error {
+	ctx, end := r.instrument(ctx, "SetMaxOpenReviews")
+	defer end()
+
+	query := "UPDATE users SET max_open_reviews = $1 WHERE id = $2"
+	result, err := r.db.ExecContext(ctx, query, maxOpenReviews, userID)
 	if err != nil {
 		return err
 	}
@@ -103,54 +355,188 @@ func (r *PostgresRepository) SetUserActive(userID string, iaActive bool) error {
 	return nil
 }
 
-// Teams
-func (r *PostgresRepository) AddTeam(team *domain.Team) error {
-	tx, err := r.db.Beginx()
+// SetUserActive при деактивации активного пользователя пишет событие user.deactivated
+func (r *PostgresRepository) SetUserActive(ctx context.Context, userID string, isActive bool) error {
+	ctx, end := r.instrument(ctx, "SetUserActive")
+	defer end()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
+	var wasActive bool
+	err = tx.GetContext(ctx, &wasActive, "SELECT is_active FROM users WHERE id = $1 FOR UPDATE", userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("user was not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE users SET is_active = $1 WHERE id = $2", isActive, userID)
+	if err != nil {
+		return err
+	}
+
+	if wasActive && !isActive {
+		var user domain.User
+		err = tx.GetContext(ctx, &user, `
+            SELECT u.id, u.username, u.is_active, t.name AS team_name
+            FROM users u
+            JOIN teams t ON t.id = u.team_id
+            WHERE u.id = $1
+        `, userID)
+		if err != nil {
+			return err
+		}
+		if err = insertOutbox(ctx, tx, userID, domain.NewEvent(domain.EventUserDeactivated, &user)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Teams
+func (r *PostgresRepository) AddTeam(ctx context.Context, team *domain.Team, mode string) ([]*domain.TeamMemberResult, error) {
+	ctx, end := r.instrument(ctx, "AddTeam")
+	defer end()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var parentTeamID *string
+	if team.ParentTeamName != "" {
+		var id string
+		err = tx.GetContext(ctx, &id, "SELECT id FROM teams WHERE name = $1", team.ParentTeamName)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("parent team not found")
+		}
+		if err != nil {
+			return nil, err
+		}
+		parentTeamID = &id
+	}
+
 	// Создаем команду (ID сгенерируется автоматически)
 	var teamID string
-	err = tx.QueryRow(
-		"INSERT INTO teams (name) VALUES ($1) RETURNING id",
-		team.TeamName,
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO teams (name, parent_team_id) VALUES ($1, $2) RETURNING id",
+		team.TeamName, parentTeamID,
 	).Scan(&teamID)
 
 	if err != nil {
 		// Проверяем на уникальность имени команды
 		if strings.Contains(err.Error(), "unique constraint") {
-			return errors.New("TEAM_EXISTS")
+			return nil, errors.New("TEAM_EXISTS")
 		}
-		return err
+		return nil, err
 	}
 
-	// Создаем/обновляем пользователей
+	results := make([]*domain.TeamMemberResult, 0, len(team.Members))
+	hasConflicts := false
 	for _, member := range team.Members {
-		_, err := tx.Exec(`
-            INSERT INTO users (id, username, is_active, team_id) 
-            VALUES ($1, $2, $3, $4)
-            ON CONFLICT (id) 
-            DO UPDATE SET username = $2, is_active = $3, team_id = $4
-        `, member.UserId, member.Username, member.IsActive, teamID)
+		// Пользователь мог уже состоять в другой команде
+		var currentTeamID, currentTeamName string
+		err := tx.QueryRowContext(ctx, `
+            SELECT u.team_id, t.name
+            FROM users u
+            JOIN teams t ON u.team_id = t.id
+            WHERE u.id = $1
+            FOR UPDATE OF u
+        `, member.UserId).Scan(&currentTeamID, &currentTeamName)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			_, err = tx.ExecContext(ctx, `
+                INSERT INTO users (id, username, is_active, team_id) 
+                VALUES ($1, $2, $3, $4)
+            `, member.UserId, member.Username, member.IsActive, teamID)
+			if err != nil {
+				return nil, err
+			}
+			_, err = tx.ExecContext(ctx, "INSERT INTO team_memberships (user_id, team_id) VALUES ($1, $2)", member.UserId, teamID)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, &domain.TeamMemberResult{UserID: member.UserId, Result: domain.MemberCreated})
+			continue
+		}
 		if err != nil {
-			return err
+			return nil, err
 		}
+
+		result := &domain.TeamMemberResult{UserID: member.UserId, FromTeam: currentTeamName}
+		switch mode {
+		case domain.TeamConflictSkip:
+			result.Result = domain.MemberSkipped
+		case domain.TeamConflictJoin:
+			// Пользователь остается в своих командах и дополнительно вступает в новую
+			_, err = tx.ExecContext(ctx, "INSERT INTO team_memberships (user_id, team_id) VALUES ($1, $2)", member.UserId, teamID)
+			if err != nil {
+				return nil, err
+			}
+			result.Result = domain.MemberJoined
+		case domain.TeamConflictMove:
+			_, err = tx.ExecContext(ctx, `
+                UPDATE users SET username = $2, is_active = $3, team_id = $4
+                WHERE id = $1
+            `, member.UserId, member.Username, member.IsActive, teamID)
+			if err != nil {
+				return nil, err
+			}
+			_, err = tx.ExecContext(ctx, "DELETE FROM team_memberships WHERE user_id = $1 AND team_id = $2", member.UserId, currentTeamID)
+			if err != nil {
+				return nil, err
+			}
+			_, err = tx.ExecContext(ctx, "INSERT INTO team_memberships (user_id, team_id) VALUES ($1, $2)", member.UserId, teamID)
+			if err != nil {
+				return nil, err
+			}
+			// Фиксируем перевод пользователя между командами
+			_, err = tx.ExecContext(ctx, `
+                INSERT INTO user_team_events (user_id, from_team_id, to_team_id)
+                VALUES ($1, $2, $3)
+            `, member.UserId, currentTeamID, teamID)
+			if err != nil {
+				return nil, err
+			}
+			result.Result = domain.MemberMoved
+		default:
+			result.Result = domain.MemberConflict
+			hasConflicts = true
+		}
+		results = append(results, result)
 	}
 
-	return tx.Commit()
+	if hasConflicts {
+		return results, errors.New("MEMBERS_CONFLICT")
+	}
+
+	return results, tx.Commit()
 }
 
-func (r *PostgresRepository) GetTeamByName(teamName string) (*domain.Team, error) {
-	var teamExists bool
-	err := r.db.Get(&teamExists, "SELECT exists(SELECT 1 FROM teams WHERE name = $1)", teamName)
+func (r *PostgresRepository) GetTeamByName(ctx context.Context, teamName string) (*domain.Team, error) {
+	ctx, end := r.instrument(ctx, "GetTeamByName")
+	defer end()
+
+	var parentTeamName sql.NullString
+	err := r.db.GetContext(ctx, &parentTeamName, `
+        SELECT p.name
+        FROM teams t
+        LEFT JOIN teams p ON t.parent_team_id = p.id
+        WHERE t.name = $1
+    `, teamName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("team not found")
+	}
 	if err != nil {
 		return nil, fmt.Errorf("database error: %w", err)
 	}
-	if !teamExists {
-		return nil, errors.New("team not found")
-	}
 
 	var members []*domain.User
 	query := `
@@ -159,26 +545,293 @@ func (r *PostgresRepository) GetTeamByName(teamName string) (*domain.Team, error
             u.username, 
             u.is_active,
             t.name as team_name,
-            u.team_id
+            t.id as team_id
         FROM users u 
-        JOIN teams t ON u.team_id = t.id 
+        JOIN team_memberships tm ON tm.user_id = u.id
+        JOIN teams t ON tm.team_id = t.id 
         WHERE t.name = $1
         ORDER BY u.username
     `
-	err = r.db.Select(&members, query, teamName)
+	err = r.db.SelectContext(ctx, &members, query, teamName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get team members: %w", err)
 	}
 
 	team := &domain.Team{
-		TeamName: teamName,
-		Members:  members,
+		TeamName:       teamName,
+		ParentTeamName: parentTeamName.String,
+		Members:        members,
 	}
 	return team, nil
 }
 
+func (r *PostgresRepository) AddTeamMember(ctx context.Context, teamName, userID string, reviewShare *float64) error {
+	ctx, end := r.instrument(ctx, "AddTeamMember")
+	defer end()
+
+	var teamID string
+	err := r.db.GetContext(ctx, &teamID, "SELECT id FROM teams WHERE name = $1", teamName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("team not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	var userExists bool
+	err = r.db.GetContext(ctx, &userExists, "SELECT exists(SELECT 1 FROM users WHERE id = $1)", userID)
+	if err != nil {
+		return err
+	}
+	if !userExists {
+		return errors.New("user not found")
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+        INSERT INTO team_memberships (user_id, team_id, review_share)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (user_id, team_id)
+        DO UPDATE SET review_share = $3
+    `, userID, teamID, reviewShare)
+	return err
+}
+
+func (r *PostgresRepository) RemoveTeamMember(ctx context.Context, teamName, userID string) error {
+	ctx, end := r.instrument(ctx, "RemoveTeamMember")
+	defer end()
+
+	var teamID string
+	err := r.db.GetContext(ctx, &teamID, "SELECT id FROM teams WHERE name = $1", teamName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("team not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	// Основную команду убрать нельзя - для смены используется перевод
+	var isPrimary bool
+	err = r.db.GetContext(ctx, &isPrimary, "SELECT exists(SELECT 1 FROM users WHERE id = $1 AND team_id = $2)", userID, teamID)
+	if err != nil {
+		return err
+	}
+	if isPrimary {
+		return errors.New("PRIMARY_TEAM")
+	}
+
+	result, err := r.db.ExecContext(ctx, "DELETE FROM team_memberships WHERE user_id = $1 AND team_id = $2", userID, teamID)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return errors.New("membership not found")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) GetUserTeamIDs(ctx context.Context, userID string) ([]string, error) {
+	ctx, end := r.instrument(ctx, "GetUserTeamIDs")
+	defer end()
+
+	var teamIDs []string
+	query := `SELECT team_id FROM team_memberships WHERE user_id = $1 ORDER BY team_id`
+	err := r.db.SelectContext(ctx, &teamIDs, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(teamIDs) == 0 {
+		return nil, errors.New("user not found")
+	}
+	return teamIDs, nil
+}
+
+// Иерархия команд
+func (r *PostgresRepository) SetTeamParent(ctx context.Context, teamName, parentTeamName string) error {
+	ctx, end := r.instrument(ctx, "SetTeamParent")
+	defer end()
+
+	var teamID string
+	err := r.db.GetContext(ctx, &teamID, "SELECT id FROM teams WHERE name = $1", teamName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("team not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	if parentTeamName == "" {
+		_, err = r.db.ExecContext(ctx, "UPDATE teams SET parent_team_id = NULL WHERE id = $1", teamID)
+		return err
+	}
+
+	var parentTeamID string
+	err = r.db.GetContext(ctx, &parentTeamID, "SELECT id FROM teams WHERE name = $1", parentTeamName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("parent team not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	// Команда не может стать потомком самой себя
+	var createsCycle bool
+	err = r.db.GetContext(ctx, &createsCycle, `
+        WITH RECURSIVE ancestors AS (
+            SELECT id, parent_team_id FROM teams WHERE id = $1
+            UNION
+            SELECT t.id, t.parent_team_id
+            FROM teams t
+            JOIN ancestors a ON t.id = a.parent_team_id
+        )
+        SELECT exists(SELECT 1 FROM ancestors WHERE id = $2)
+    `, parentTeamID, teamID)
+	if err != nil {
+		return err
+	}
+	if createsCycle {
+		return errors.New("TEAM_CYCLE")
+	}
+
+	_, err = r.db.ExecContext(ctx, "UPDATE teams SET parent_team_id = $1 WHERE id = $2", parentTeamID, teamID)
+	return err
+}
+
+func (r *PostgresRepository) GetTeamLinks(ctx context.Context) ([]domain.TeamLink, error) {
+	ctx, end := r.instrument(ctx, "GetTeamLinks")
+	defer end()
+
+	var links []domain.TeamLink
+	query := `
+        SELECT 
+            t.name as team_name,
+            p.name as parent_team_name
+        FROM teams t
+        LEFT JOIN teams p ON t.parent_team_id = p.id
+        ORDER BY t.name
+    `
+	err := r.db.SelectContext(ctx, &links, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get team links: %w", err)
+	}
+	return links, nil
+}
+
+func (r *PostgresRepository) GetParentTeamIDs(ctx context.Context, teamIDs []string) ([]string, error) {
+	ctx, end := r.instrument(ctx, "GetParentTeamIDs")
+	defer end()
+
+	var parentIDs []string
+	query := `
+        SELECT DISTINCT parent_team_id
+        FROM teams
+        WHERE id = ANY($1) AND parent_team_id IS NOT NULL
+    `
+	err := r.db.SelectContext(ctx, &parentIDs, query, pq.Array(teamIDs))
+	return parentIDs, err
+}
+
+func (r *PostgresRepository) GetChildTeamIDs(ctx context.Context, teamIDs []string) ([]string, error) {
+	ctx, end := r.instrument(ctx, "GetChildTeamIDs")
+	defer end()
+
+	var childIDs []string
+	query := `SELECT id FROM teams WHERE parent_team_id = ANY($1)`
+	err := r.db.SelectContext(ctx, &childIDs, query, pq.Array(teamIDs))
+	return childIDs, err
+}
+
+func (r *PostgresRepository) GetTeamIDByName(ctx context.Context, teamName string) (string, error) {
+	ctx, end := r.instrument(ctx, "GetTeamIDByName")
+	defer end()
+
+	var teamID string
+	err := r.db.GetContext(ctx, &teamID, "SELECT id FROM teams WHERE name = $1", teamName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", errors.New("team not found")
+	}
+	return teamID, err
+}
+
+// CODEOWNERS
+func (r *PostgresRepository) SetTeamCodeowners(ctx context.Context, teamName, content string) error {
+	ctx, end := r.instrument(ctx, "SetTeamCodeowners")
+	defer end()
+
+	teamID, err := r.GetTeamIDByName(ctx, teamName)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+        INSERT INTO team_codeowners (team_id, content, updated_at)
+        VALUES ($1, $2, NOW())
+        ON CONFLICT (team_id)
+        DO UPDATE SET content = $2, updated_at = NOW()
+    `, teamID, content)
+	return err
+}
+
+func (r *PostgresRepository) GetTeamCodeowners(ctx context.Context, teamName string) (string, error) {
+	ctx, end := r.instrument(ctx, "GetTeamCodeowners")
+	defer end()
+
+	var content string
+	query := `
+        SELECT co.content
+        FROM team_codeowners co
+        JOIN teams t ON co.team_id = t.id
+        WHERE t.name = $1
+    `
+	err := r.db.GetContext(ctx, &content, query, teamName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", errors.New("codeowners not found")
+	}
+	return content, err
+}
+
+func (r *PostgresRepository) GetCodeownersByTeamIDs(ctx context.Context, teamIDs []string) ([]string, error) {
+	ctx, end := r.instrument(ctx, "GetCodeownersByTeamIDs")
+	defer end()
+
+	var contents []string
+	query := `SELECT content FROM team_codeowners WHERE team_id = ANY($1) ORDER BY team_id`
+	err := r.db.SelectContext(ctx, &contents, query, pq.Array(teamIDs))
+	return contents, err
+}
+
+// История пар автор-ревьюер
+func (r *PostgresRepository) SetPairingHistoryDepth(ctx context.Context, teamName string, depth int) error {
+	ctx, end := r.instrument(ctx, "SetPairingHistoryDepth")
+	defer end()
+
+	result, err := r.db.ExecContext(ctx, "UPDATE teams SET pairing_history_depth = $1 WHERE name = $2", depth, teamName)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return errors.New("team not found")
+	}
+	return nil
+}
+
+// Для нескольких команд автора берется наибольшая глубина
+func (r *PostgresRepository) GetPairingHistoryDepth(ctx context.Context, teamIDs []string) (int, error) {
+	ctx, end := r.instrument(ctx, "GetPairingHistoryDepth")
+	defer end()
+
+	var depth int
+	query := `SELECT COALESCE(MAX(pairing_history_depth), 0) FROM teams WHERE id = ANY($1)`
+	err := r.db.GetContext(ctx, &depth, query, pq.Array(teamIDs))
+	return depth, err
+}
+
 // Stats
-func (r *PostgresRepository) GetPRReviewersStats() ([]*domain.UserStats, error) {
+func (r *PostgresRepository) GetPRReviewersStats(ctx context.Context) ([]*domain.UserStats, error) {
+	ctx, end := r.instrument(ctx, "GetPRReviewersStats")
+	defer end()
+
 	query := `
         SELECT 
             u.id as user_id,
@@ -196,7 +849,7 @@ func (r *PostgresRepository) GetPRReviewersStats() ([]*domain.UserStats, error)
     `
 
 	var stats []*domain.UserStats
-	err := r.db.Select(&stats, query)
+	err := r.db.SelectContext(ctx, &stats, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get PR reviewers stats: %w", err)
 	}
@@ -204,7 +857,10 @@ func (r *PostgresRepository) GetPRReviewersStats() ([]*domain.UserStats, error)
 	return stats, nil
 }
 
-func (r *PostgresRepository) GetDetailedPRStats() ([]*domain.PRStats, error) {
+func (r *PostgresRepository) GetDetailedPRStats(ctx context.Context) ([]*domain.PRStats, error) {
+	ctx, end := r.instrument(ctx, "GetDetailedPRStats")
+	defer end()
+
 	query := `
         SELECT 
             pr.id as pull_request_id,
@@ -226,7 +882,7 @@ func (r *PostgresRepository) GetDetailedPRStats() ([]*domain.PRStats, error) {
     `
 
 	var stats []*domain.PRStats
-	err := r.db.Select(&stats, query)
+	err := r.db.SelectContext(ctx, &stats, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get detailed PR stats: %w", err)
 	}
@@ -234,7 +890,10 @@ func (r *PostgresRepository) GetDetailedPRStats() ([]*domain.PRStats, error) {
 	return stats, nil
 }
 
-func (r *PostgresRepository) GetTeamStats() ([]*domain.TeamStats, error) {
+func (r *PostgresRepository) GetTeamStats(ctx context.Context) ([]*domain.TeamStats, error) {
+	ctx, end := r.instrument(ctx, "GetTeamStats")
+	defer end()
+
 	query := `
         SELECT 
             t.name as team_name,
@@ -243,7 +902,8 @@ func (r *PostgresRepository) GetTeamStats() ([]*domain.TeamStats, error) {
             COUNT(DISTINCT prr.pull_request_id) as reviewed_pr_count,
             COUNT(DISTINCT CASE WHEN pr.status = 'MERGED' THEN pr.id END) as merged_pr_count
         FROM teams t
-        LEFT JOIN users u ON t.id = u.team_id AND u.is_active = true
+        LEFT JOIN team_memberships tm ON t.id = tm.team_id
+        LEFT JOIN users u ON tm.user_id = u.id AND u.is_active = true
         LEFT JOIN pull_requests pr ON u.id = pr.author_id
         LEFT JOIN pull_request_reviewers prr ON u.id = prr.user_id
         GROUP BY t.name
@@ -251,10 +911,75 @@ func (r *PostgresRepository) GetTeamStats() ([]*domain.TeamStats, error) {
     `
 
 	var stats []*domain.TeamStats
-	err := r.db.Select(&stats, query)
+	err := r.db.SelectContext(ctx, &stats, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get team stats: %w", err)
 	}
 
 	return stats, nil
 }
+
+// Матрица автор x ревьюер для авторов из команды
+func (r *PostgresRepository) GetTeamPairingStats(ctx context.Context, teamName string) ([]*domain.PairingStats, error) {
+	ctx, end := r.instrument(ctx, "GetTeamPairingStats")
+	defer end()
+
+	teamID, err := r.GetTeamIDByName(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+        SELECT 
+            pr.author_id,
+            prr.user_id as reviewer_id,
+            COUNT(*) as count
+        FROM pull_requests pr
+        JOIN pull_request_reviewers prr ON pr.id = prr.pull_request_id
+        JOIN team_memberships tm ON tm.user_id = pr.author_id AND tm.team_id = $1
+        GROUP BY pr.author_id, prr.user_id
+        ORDER BY pr.author_id, count DESC
+    `
+
+	stats := []*domain.PairingStats{}
+	err = r.db.SelectContext(ctx, &stats, query, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pairing stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// Открытые PR (по командам автора) и открытые ревью (по командам ревьюера)
+func (r *PostgresRepository) GetTeamLoadStats(ctx context.Context) ([]*domain.TeamLoadStats, error) {
+	ctx, end := r.instrument(ctx, "GetTeamLoadStats")
+	defer end()
+
+	query := `
+        SELECT 
+            t.name as team_name,
+            (
+                SELECT COUNT(DISTINCT pr.id)
+                FROM pull_requests pr
+                JOIN team_memberships tm ON tm.user_id = pr.author_id
+                WHERE tm.team_id = t.id AND pr.status = 'OPEN'
+            ) as open_pr_count,
+            (
+                SELECT COUNT(*)
+                FROM pull_request_reviewers prr
+                JOIN pull_requests pr ON prr.pull_request_id = pr.id
+                JOIN team_memberships tm ON tm.user_id = prr.user_id
+                WHERE tm.team_id = t.id AND pr.status = 'OPEN'
+            ) as open_review_count
+        FROM teams t
+        ORDER BY t.name
+    `
+
+	var stats []*domain.TeamLoadStats
+	err := r.db.SelectContext(ctx, &stats, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get team load stats: %w", err)
+	}
+
+	return stats, nil
+}
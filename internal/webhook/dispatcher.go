@@ -0,0 +1,180 @@
+// Package webhook доставляет события подписчикам: HMAC-подпись, повторы с экспоненциальной
+// задержкой и dead-letter список для доставок, исчерпавших попытки.
+package webhook
+
+import (
+	"avito-tech-internship/internal/domain"
+	"avito-tech-internship/internal/metrics"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Заголовки запроса к подписчику
+const (
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Сколько байт тела ответа сохранять в журнале при ошибке
+const maxErrorBody = 512
+
+// Store - хранилище доставок, реализуется storage.PostgresRepository
+type Store interface {
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*domain.WebhookDelivery, error)
+	RecordWebhookAttempt(ctx context.Context, attempt *domain.WebhookAttempt, status string, retryIn time.Duration) error
+}
+
+type Config struct {
+	// Как часто проверять очередь доставок
+	PollInterval time.Duration
+	BatchSize    int
+	// После MaxAttempts неудачных попыток доставка уходит в dead-letter
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Таймаут одного запроса к подписчику
+	Timeout time.Duration
+}
+
+type Dispatcher struct {
+	store  Store
+	client *http.Client
+	cfg    Config
+}
+
+// NewDispatcher создает отправителя. client можно подменить, например на клиент httptest-сервера.
+func NewDispatcher(store Store, client *http.Client, cfg Config) *Dispatcher {
+	if client == nil {
+		client = &http.Client{}
+	}
+	return &Dispatcher{store: store, client: client, cfg: cfg}
+}
+
+// Run отправляет доставки из очереди до отмены ctx
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Пока очередь полная, забираем следующую пачку сразу
+		for d.dispatchBatch(ctx) == d.cfg.BatchSize && ctx.Err() == nil {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Dispatcher) dispatchBatch(ctx context.Context) int {
+	// Аренда с запасом на все запросы пачки, чтобы доставку не взяла другая реплика
+	lease := d.cfg.Timeout*time.Duration(d.cfg.BatchSize) + d.cfg.PollInterval
+	deliveries, err := d.store.ClaimWebhookDeliveries(ctx, d.cfg.BatchSize, lease)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("could not claim webhook deliveries", "error", err)
+		}
+		return 0
+	}
+	for _, delivery := range deliveries {
+		d.deliver(ctx, delivery)
+	}
+	return len(deliveries)
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery *domain.WebhookDelivery) {
+	attempt := &domain.WebhookAttempt{DeliveryID: delivery.ID, Attempt: delivery.Attempts + 1}
+
+	start := time.Now()
+	statusCode, err := d.send(ctx, delivery)
+	attempt.DurationMs = time.Since(start).Milliseconds()
+	if statusCode != 0 {
+		attempt.StatusCode = &statusCode
+	}
+
+	status, retryIn := domain.DeliveryDelivered, time.Duration(0)
+	if err != nil {
+		message := err.Error()
+		attempt.Error = &message
+		status, retryIn = domain.DeliveryPending, d.backoff(attempt.Attempt)
+		if attempt.Attempt >= d.cfg.MaxAttempts {
+			status = domain.DeliveryDead
+			slog.Warn("webhook delivery moved to dead letters", "delivery", delivery.ID, "event", delivery.EventType, "error", err)
+		}
+	}
+	metrics.WebhookDelivery(status)
+
+	// Запись результата не должна прерываться остановкой сервера, иначе попытка потеряется из журнала
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := d.store.RecordWebhookAttempt(recordCtx, attempt, status, retryIn); err != nil {
+		slog.Error("could not record webhook attempt", "delivery", delivery.ID, "error", err)
+	}
+}
+
+// send возвращает код ответа (0, если ответа не было) и ошибку, если доставка не удалась
+func (d *Dispatcher) send(ctx context.Context, delivery *domain.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "reviewer-service-webhooks")
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, body)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff - задержка перед следующей попыткой: InitialBackoff * 2^(attempt-1), не больше MaxBackoff
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.cfg.InitialBackoff
+	for i := 1; i < attempt && delay < d.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.cfg.MaxBackoff)
+}
+
+// Sign считает подпись "sha256=<hex>" от "<timestamp>.<body>" на секрете подписки.
+// Метка времени входит в подпись, чтобы получатель мог отбросить повторно отправленные старые запросы.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify проверяет подпись на стороне получателя
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
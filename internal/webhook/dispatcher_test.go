@@ -0,0 +1,250 @@
+package webhook
+
+import (
+	"avito-tech-internship/internal/domain"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testSecret = "whsec_test_secret_value"
+
+// memoryStore - очередь доставок в памяти: все доставки в статусе pending считаются готовыми к отправке,
+// задержка между попытками проверяется по retryIn
+type memoryStore struct {
+	mu         sync.Mutex
+	deliveries []*domain.WebhookDelivery
+	records    []record
+}
+
+type record struct {
+	attempt domain.WebhookAttempt
+	status  string
+	retryIn time.Duration
+}
+
+func (s *memoryStore) ClaimWebhookDeliveries(_ context.Context, limit int, _ time.Duration) ([]*domain.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	claimed := []*domain.WebhookDelivery{}
+	for _, d := range s.deliveries {
+		if d.Status == domain.DeliveryPending && len(claimed) < limit {
+			copied := *d
+			claimed = append(claimed, &copied)
+		}
+	}
+	return claimed, nil
+}
+
+func (s *memoryStore) RecordWebhookAttempt(_ context.Context, attempt *domain.WebhookAttempt, status string, retryIn time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range s.deliveries {
+		if d.ID == attempt.DeliveryID {
+			d.Attempts = attempt.Attempt
+			d.Status = status
+		}
+	}
+	s.records = append(s.records, record{attempt: *attempt, status: status, retryIn: retryIn})
+	return nil
+}
+
+func newTestDispatcher(t *testing.T, handler http.HandlerFunc, cfg Config) (*Dispatcher, *memoryStore) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	store := &memoryStore{deliveries: []*domain.WebhookDelivery{{
+		ID:        "dlv-1",
+		EventID:   "evt_1",
+		EventType: domain.EventPRMerged,
+		Payload:   []byte(`{"id":"evt_1","type":"pr.merged","data":{"pull_request_id":"pr-1"}}`),
+		Status:    domain.DeliveryPending,
+		URL:       server.URL,
+		Secret:    testSecret,
+	}}}
+	return NewDispatcher(store, server.Client(), cfg), store
+}
+
+func testConfig() Config {
+	return Config{
+		PollInterval:   time.Second,
+		BatchSize:      10,
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     4 * time.Second,
+		Timeout:        time.Second,
+	}
+}
+
+func TestDispatcherSignsRequest(t *testing.T) {
+	var requests atomic.Int32
+	d, store := newTestDispatcher(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		timestamp, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if err != nil {
+			t.Errorf("invalid %s header: %v", HeaderTimestamp, err)
+		}
+		signature := r.Header.Get(HeaderSignature)
+
+		if !Verify(testSecret, timestamp, body, signature) {
+			t.Errorf("signature %q does not verify", signature)
+		}
+		if Verify("another-secret", timestamp, body, signature) {
+			t.Error("signature verifies with another secret")
+		}
+		if Verify(testSecret, timestamp+1, body, signature) {
+			t.Error("signature verifies with another timestamp")
+		}
+		if Verify(testSecret, timestamp, append(body, ' '), signature) {
+			t.Error("signature verifies with modified body")
+		}
+		if got := r.Header.Get(HeaderDelivery); got != "dlv-1" {
+			t.Errorf("%s = %q, want dlv-1", HeaderDelivery, got)
+		}
+		if got := r.Header.Get(HeaderEvent); got != domain.EventPRMerged {
+			t.Errorf("%s = %q, want %s", HeaderEvent, got, domain.EventPRMerged)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}, testConfig())
+
+	if n := d.dispatchBatch(context.Background()); n != 1 {
+		t.Fatalf("dispatchBatch = %d, want 1", n)
+	}
+	// Доставленное событие больше не отправляется
+	if n := d.dispatchBatch(context.Background()); n != 0 {
+		t.Fatalf("second dispatchBatch = %d, want 0", n)
+	}
+
+	if got := requests.Load(); got != 1 {
+		t.Fatalf("requests = %d, want 1", got)
+	}
+	if len(store.records) != 1 {
+		t.Fatalf("records = %d, want 1", len(store.records))
+	}
+	rec := store.records[0]
+	if rec.status != domain.DeliveryDelivered || rec.retryIn != 0 || rec.attempt.Error != nil {
+		t.Errorf("record = %+v, want delivered without error", rec)
+	}
+	if rec.attempt.StatusCode == nil || *rec.attempt.StatusCode != http.StatusNoContent {
+		t.Errorf("status code = %v, want %d", rec.attempt.StatusCode, http.StatusNoContent)
+	}
+}
+
+func TestDispatcherRetriesWithBackoff(t *testing.T) {
+	var requests atomic.Int32
+	d, store := newTestDispatcher(t, func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= 3 {
+			http.Error(w, "unavailable", http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}, testConfig())
+
+	for i := 0; i < 6; i++ {
+		d.dispatchBatch(context.Background())
+	}
+
+	if got := requests.Load(); got != 4 {
+		t.Fatalf("requests = %d, want 4", got)
+	}
+	want := []struct {
+		status  string
+		retryIn time.Duration
+	}{
+		{domain.DeliveryPending, time.Second},
+		{domain.DeliveryPending, 2 * time.Second},
+		{domain.DeliveryPending, 4 * time.Second},
+		{domain.DeliveryDelivered, 0},
+	}
+	if len(store.records) != len(want) {
+		t.Fatalf("records = %d, want %d", len(store.records), len(want))
+	}
+	for i, w := range want {
+		rec := store.records[i]
+		if rec.attempt.Attempt != i+1 || rec.status != w.status || rec.retryIn != w.retryIn {
+			t.Errorf("attempt %d: got attempt=%d status=%s retryIn=%s, want status=%s retryIn=%s",
+				i+1, rec.attempt.Attempt, rec.status, rec.retryIn, w.status, w.retryIn)
+		}
+	}
+	if rec := store.records[0]; rec.attempt.StatusCode == nil || *rec.attempt.StatusCode != http.StatusBadGateway || rec.attempt.Error == nil {
+		t.Errorf("failed attempt = %+v, want status 502 with error", rec.attempt)
+	}
+}
+
+func TestDispatcherRetriesOnTimeout(t *testing.T) {
+	cfg := testConfig()
+	cfg.Timeout = 50 * time.Millisecond
+	release := make(chan struct{})
+	d, store := newTestDispatcher(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}, cfg)
+	defer close(release)
+
+	d.dispatchBatch(context.Background())
+
+	if len(store.records) != 1 {
+		t.Fatalf("records = %d, want 1", len(store.records))
+	}
+	rec := store.records[0]
+	if rec.status != domain.DeliveryPending || rec.retryIn != cfg.InitialBackoff {
+		t.Errorf("status = %s, retryIn = %s, want pending after %s", rec.status, rec.retryIn, cfg.InitialBackoff)
+	}
+	if rec.attempt.StatusCode != nil || rec.attempt.Error == nil {
+		t.Errorf("attempt = %+v, want error without status code", rec.attempt)
+	}
+}
+
+func TestDispatcherMarksDeadAfterMaxAttempts(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxAttempts = 3
+	var requests atomic.Int32
+	d, store := newTestDispatcher(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}, cfg)
+
+	for i := 0; i < cfg.MaxAttempts+2; i++ {
+		d.dispatchBatch(context.Background())
+	}
+
+	if got := requests.Load(); got != int32(cfg.MaxAttempts) {
+		t.Fatalf("requests = %d, want %d", got, cfg.MaxAttempts)
+	}
+	last := store.records[len(store.records)-1]
+	if last.status != domain.DeliveryDead || last.attempt.Attempt != cfg.MaxAttempts {
+		t.Errorf("last record = status %s attempt %d, want dead at attempt %d", last.status, last.attempt.Attempt, cfg.MaxAttempts)
+	}
+	if store.deliveries[0].Status != domain.DeliveryDead {
+		t.Errorf("delivery status = %s, want dead", store.deliveries[0].Status)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(nil, nil, Config{InitialBackoff: 10 * time.Second, MaxBackoff: time.Minute})
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{4, time.Minute},
+		{50, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := d.backoff(tt.attempt); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS user_team_events;
\ No newline at end of file
@@ -0,0 +1,11 @@
+-- Журнал переводов пользователей между командами
+CREATE TABLE IF NOT EXISTS user_team_events
+(
+    id           BIGSERIAL PRIMARY KEY,
+    user_id      TEXT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    from_team_id TEXT REFERENCES teams (id) ON DELETE SET NULL,
+    to_team_id   TEXT REFERENCES teams (id) ON DELETE SET NULL,
+    created_at   TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_team_events_user ON user_team_events (user_id);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS team_memberships;
\ No newline at end of file
@@ -0,0 +1,18 @@
+-- Пользователь может состоять в нескольких командах (многие-ко-многим).
+-- users.team_id остается основной командой пользователя.
+CREATE TABLE IF NOT EXISTS team_memberships
+(
+    user_id      TEXT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    team_id      TEXT      NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+    review_share NUMERIC(3, 2) CHECK (review_share > 0 AND review_share <= 1),
+    created_at   TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, team_id)
+);
+
+-- Переносим существующие связи пользователь-команда
+INSERT INTO team_memberships (user_id, team_id)
+SELECT id, team_id
+FROM users
+ON CONFLICT DO NOTHING;
+
+CREATE INDEX IF NOT EXISTS idx_team_memberships_team ON team_memberships (team_id);
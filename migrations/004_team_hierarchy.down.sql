@@ -0,0 +1 @@
+ALTER TABLE teams DROP COLUMN IF EXISTS parent_team_id;
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- Иерархия команд: департамент -> команды
+ALTER TABLE teams
+    ADD COLUMN IF NOT EXISTS parent_team_id TEXT REFERENCES teams (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_teams_parent ON teams (parent_team_id);
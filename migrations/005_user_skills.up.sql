@@ -0,0 +1,9 @@
+-- Навыки пользователей для подбора ревьюеров по затронутому коду
+CREATE TABLE IF NOT EXISTS user_skills
+(
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    tag     TEXT NOT NULL,
+    PRIMARY KEY (user_id, tag)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_skills_tag ON user_skills (tag);
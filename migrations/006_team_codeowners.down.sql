@@ -0,0 +1 @@
+DROP TABLE IF EXISTS team_codeowners;
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- Файлы владельцев кода (формат GitHub CODEOWNERS), по одному на команду
+CREATE TABLE IF NOT EXISTS team_codeowners
+(
+    team_id    TEXT PRIMARY KEY REFERENCES teams (id) ON DELETE CASCADE,
+    content    TEXT      NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_prs_author_created;
+ALTER TABLE teams DROP COLUMN IF EXISTS pairing_history_depth;
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- Сколько последних PR автора учитывать при штрафе за повторные пары автор-ревьюер (0 - не учитывать)
+ALTER TABLE teams
+    ADD COLUMN IF NOT EXISTS pairing_history_depth INT NOT NULL DEFAULT 5 CHECK (pairing_history_depth >= 0);
+
+CREATE INDEX IF NOT EXISTS idx_prs_author_created ON pull_requests (author_id, created_at DESC);
@@ -0,0 +1,3 @@
+-- Лимит одновременно открытых ревью на пользователя (NULL - без ограничений)
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS max_open_reviews INT CHECK (max_open_reviews >= 0);
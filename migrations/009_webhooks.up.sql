@@ -0,0 +1,45 @@
+-- Подписки на события: URL получателя, секрет для HMAC-подписи и список типов событий
+CREATE TABLE IF NOT EXISTS webhook_subscriptions
+(
+    id         TEXT PRIMARY KEY DEFAULT gen_random_uuid(),
+    url        TEXT      NOT NULL,
+    secret     TEXT      NOT NULL,
+    events     TEXT[]    NOT NULL,
+    is_active  BOOLEAN   NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Доставка одного события одному подписчику. status: pending, delivered, dead (исчерпаны попытки)
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id               TEXT PRIMARY KEY DEFAULT gen_random_uuid(),
+    subscription_id  TEXT      NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+    event_id         TEXT      NOT NULL,
+    event_type       TEXT      NOT NULL,
+    payload          JSONB     NOT NULL,
+    status           TEXT      NOT NULL DEFAULT 'pending',
+    attempts         INT       NOT NULL DEFAULT 0,
+    next_attempt_at  TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_error       TEXT,
+    last_status_code INT,
+    created_at       TIMESTAMP NOT NULL DEFAULT NOW(),
+    delivered_at     TIMESTAMP,
+    UNIQUE (subscription_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_status ON webhook_deliveries (status, created_at);
+
+-- Журнал попыток доставки
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts
+(
+    id          BIGSERIAL PRIMARY KEY,
+    delivery_id TEXT      NOT NULL REFERENCES webhook_deliveries (id) ON DELETE CASCADE,
+    attempt     INT       NOT NULL,
+    status_code INT,
+    error       TEXT,
+    duration_ms BIGINT    NOT NULL,
+    created_at  TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_attempts_delivery ON webhook_delivery_attempts (delivery_id);
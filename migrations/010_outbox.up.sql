@@ -0,0 +1,22 @@
+-- Transactional outbox: события пишутся в одной транзакции с изменением и публикуются фоновым relay
+CREATE TABLE IF NOT EXISTS outbox
+(
+    id              BIGSERIAL PRIMARY KEY,
+    event_id        TEXT      NOT NULL UNIQUE,
+    event_type      TEXT      NOT NULL,
+    -- PR или пользователь, к которому относится событие. События одного агрегата публикуются по порядку
+    aggregate_id    TEXT      NOT NULL,
+    payload         JSONB     NOT NULL,
+    attempts        INT       NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    created_at      TIMESTAMP NOT NULL DEFAULT NOW(),
+    -- Когда событие можно взять снова: после аренды relay или задержки перед повтором
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    published_at    TIMESTAMP,
+    -- Событие исчерпало попытки и больше не публикуется
+    dead_at         TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox (id) WHERE published_at IS NULL AND dead_at IS NULL;
+-- Поиск более раннего неопубликованного события того же агрегата
+CREATE INDEX IF NOT EXISTS idx_outbox_pending_aggregate ON outbox (aggregate_id, id) WHERE published_at IS NULL AND dead_at IS NULL;
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS integration_deliveries;
+DROP TABLE IF EXISTS external_user_mappings;
+
+UPDATE pull_requests SET status = 'OPEN' WHERE status = 'CLOSED';
+ALTER TABLE pull_requests DROP COLUMN IF EXISTS closed_at;
+ALTER TABLE pull_requests DROP COLUMN IF EXISTS provider;
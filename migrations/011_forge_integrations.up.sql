@@ -0,0 +1,30 @@
+-- PR, закрытые без мерджа в GitHub/GitLab, получают статус CLOSED
+ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS closed_at TIMESTAMP;
+-- Внешняя система, из вебхука которой создан PR; NULL для PR, созданных через API
+ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS provider TEXT;
+
+-- Соответствие логинов во внешних системах пользователям сервиса. provider: github, gitlab
+CREATE TABLE IF NOT EXISTS external_user_mappings
+(
+    provider       TEXT      NOT NULL,
+    -- Логин хранится в нижнем регистре: GitHub сравнивает логины без учета регистра
+    external_login TEXT      NOT NULL,
+    user_id        TEXT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at     TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (provider, external_login)
+);
+
+CREATE INDEX IF NOT EXISTS idx_external_user_mappings_user ON external_user_mappings (user_id);
+
+-- Обработанные входящие вебхуки: повторная доставка с тем же id не применяется второй раз
+CREATE TABLE IF NOT EXISTS integration_deliveries
+(
+    provider        TEXT      NOT NULL,
+    delivery_id     TEXT      NOT NULL,
+    event           TEXT      NOT NULL,
+    action          TEXT      NOT NULL,
+    pull_request_id TEXT,
+    result          TEXT      NOT NULL,
+    received_at     TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (provider, delivery_id)
+);
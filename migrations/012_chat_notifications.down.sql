@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS chat_deliveries;
+
+DROP TABLE IF EXISTS team_notification_channels;
+
+ALTER TABLE users DROP COLUMN IF EXISTS chat_notifications;
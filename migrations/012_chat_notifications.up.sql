@@ -0,0 +1,33 @@
+-- Пользователь может отказаться от уведомлений в чат
+ALTER TABLE users ADD COLUMN IF NOT EXISTS chat_notifications BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- Incoming webhook Slack/Mattermost, куда уходят уведомления участников команды
+CREATE TABLE IF NOT EXISTS team_notification_channels
+(
+    team_id     TEXT PRIMARY KEY REFERENCES teams (id) ON DELETE CASCADE,
+    webhook_url TEXT      NOT NULL,
+    -- Переопределение канала, пустой - канал по умолчанию для webhook
+    channel     TEXT      NOT NULL DEFAULT '',
+    updated_at  TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Уведомление в чат: одно сообщение на событие и канал. Текст рендерится при постановке в очередь,
+-- повторы идут с экспоненциальной задержкой. status: pending, delivered, dead (исчерпаны попытки)
+CREATE TABLE IF NOT EXISTS chat_deliveries
+(
+    id              BIGSERIAL PRIMARY KEY,
+    event_id        TEXT      NOT NULL,
+    event_type      TEXT      NOT NULL,
+    webhook_url     TEXT      NOT NULL,
+    channel         TEXT      NOT NULL DEFAULT '',
+    text            TEXT      NOT NULL,
+    status          TEXT      NOT NULL DEFAULT 'pending',
+    attempts        INT       NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_error      TEXT,
+    created_at      TIMESTAMP NOT NULL DEFAULT NOW(),
+    delivered_at    TIMESTAMP,
+    UNIQUE (event_id, webhook_url, channel)
+);
+
+CREATE INDEX IF NOT EXISTS idx_chat_deliveries_due ON chat_deliveries (next_attempt_at) WHERE status = 'pending';
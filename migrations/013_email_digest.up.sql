@@ -0,0 +1,12 @@
+-- Адрес для ежедневной сводки ревью, NULL - сводка не отправляется
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_digest BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- Отправленные сводки: реплики не отправляют сводку за один день дважды
+CREATE TABLE IF NOT EXISTS email_digests
+(
+    user_id     TEXT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    digest_date DATE      NOT NULL,
+    sent_at     TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, digest_date)
+);
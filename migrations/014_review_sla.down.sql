@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS sla_breaches;
+
+ALTER TABLE teams DROP COLUMN IF EXISTS sla_auto_reassign;
+ALTER TABLE teams DROP COLUMN IF EXISTS review_sla;
+
+ALTER TABLE pull_request_reviewers DROP COLUMN IF EXISTS responded_at;
+ALTER TABLE pull_request_reviewers DROP COLUMN IF EXISTS assigned_at;
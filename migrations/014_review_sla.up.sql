@@ -0,0 +1,29 @@
+-- Время назначения и ответа ревьюера: от назначения отсчитывается SLA, ответ его останавливает
+ALTER TABLE pull_request_reviewers ADD COLUMN IF NOT EXISTS assigned_at TIMESTAMP;
+UPDATE pull_request_reviewers prr
+SET assigned_at = pr.created_at
+FROM pull_requests pr
+WHERE pr.id = prr.pull_request_id AND prr.assigned_at IS NULL;
+ALTER TABLE pull_request_reviewers ALTER COLUMN assigned_at SET DEFAULT NOW();
+ALTER TABLE pull_request_reviewers ALTER COLUMN assigned_at SET NOT NULL;
+ALTER TABLE pull_request_reviewers ADD COLUMN IF NOT EXISTS responded_at TIMESTAMP;
+
+-- SLA команды автора PR, NULL - значение по умолчанию из конфигурации
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS review_sla INTERVAL;
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS sla_auto_reassign BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Нарушения SLA: одно на назначение ревьюера. action: flagged или reassigned
+CREATE TABLE IF NOT EXISTS sla_breaches
+(
+    pull_request_id TEXT      NOT NULL REFERENCES pull_requests (id) ON DELETE CASCADE,
+    user_id         TEXT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    assigned_at     TIMESTAMP NOT NULL,
+    team_id         TEXT      REFERENCES teams (id) ON DELETE SET NULL,
+    sla_seconds     BIGINT    NOT NULL,
+    action          TEXT      NOT NULL DEFAULT 'flagged',
+    new_reviewer_id TEXT      REFERENCES users (id) ON DELETE SET NULL,
+    detected_at     TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (pull_request_id, user_id, assigned_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sla_breaches_detected ON sla_breaches (detected_at);
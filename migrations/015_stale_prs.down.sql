@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS stale_pr_actions;
+
+DROP INDEX IF EXISTS idx_pull_requests_open_activity;
+ALTER TABLE pull_requests DROP COLUMN IF EXISTS stale_exempt;
+ALTER TABLE pull_requests DROP COLUMN IF EXISTS stale_at;
+ALTER TABLE pull_requests DROP COLUMN IF EXISTS last_activity_at;
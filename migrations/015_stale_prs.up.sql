@@ -0,0 +1,28 @@
+-- Последняя активность по PR: создание, ответ ревьюера, повторное открытие
+ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS last_activity_at TIMESTAMP;
+UPDATE pull_requests pr
+SET last_activity_at = GREATEST(
+        pr.created_at,
+        (SELECT MAX(prr.responded_at) FROM pull_request_reviewers prr WHERE prr.pull_request_id = pr.id)
+    )
+WHERE pr.last_activity_at IS NULL;
+ALTER TABLE pull_requests ALTER COLUMN last_activity_at SET DEFAULT NOW();
+ALTER TABLE pull_requests ALTER COLUMN last_activity_at SET NOT NULL;
+
+-- Когда PR помечен устаревшим (сбрасывается при активности) и исключен ли он автором из политики
+ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS stale_at TIMESTAMP;
+ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS stale_exempt BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_pull_requests_open_activity ON pull_requests (last_activity_at) WHERE status = 'OPEN';
+
+-- Журнал действий политики устаревания. action: marked_stale или closed
+CREATE TABLE IF NOT EXISTS stale_pr_actions
+(
+    id               BIGSERIAL PRIMARY KEY,
+    pull_request_id  TEXT      NOT NULL REFERENCES pull_requests (id) ON DELETE CASCADE,
+    action           TEXT      NOT NULL,
+    last_activity_at TIMESTAMP NOT NULL,
+    created_at       TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_stale_pr_actions_pr ON stale_pr_actions (pull_request_id);
@@ -0,0 +1,20 @@
+-- История запусков заданий планировщика. status: running, succeeded, failed, abandoned
+CREATE TABLE IF NOT EXISTS job_runs
+(
+    id            BIGSERIAL PRIMARY KEY,
+    job_name      TEXT      NOT NULL,
+    -- schedule или manual
+    trigger       TEXT      NOT NULL,
+    -- Момент расписания, NULL для ручного запуска
+    scheduled_for TIMESTAMPTZ,
+    status        TEXT      NOT NULL DEFAULT 'running',
+    error         TEXT,
+    instance      TEXT      NOT NULL DEFAULT '',
+    started_at    TIMESTAMP NOT NULL DEFAULT NOW(),
+    finished_at   TIMESTAMP,
+    duration_ms   BIGINT
+);
+
+-- Один запуск на момент расписания: реплика, взявшая блокировку позже, его не повторит
+CREATE UNIQUE INDEX IF NOT EXISTS idx_job_runs_scheduled ON job_runs (job_name, scheduled_for);
+CREATE INDEX IF NOT EXISTS idx_job_runs_job_started ON job_runs (job_name, started_at DESC);
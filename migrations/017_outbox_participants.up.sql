@@ -0,0 +1,23 @@
+-- Участники события на момент записи (автор и ревьюеры PR, замененный ревьюер, пользователь в user.deactivated),
+-- по ним фильтруется поток событий. Для существующих событий берутся из payload, автор PR - из pull_requests
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS participants TEXT[] NOT NULL DEFAULT '{}';
+
+UPDATE outbox o
+SET participants = ARRAY(
+        SELECT DISTINCT s.user_id
+        FROM (SELECT o.payload -> 'data' ->> key AS user_id
+              FROM unnest(ARRAY ['author_id', 'reviewer_id', 'old_reviewer_id', 'new_reviewer_id']) AS key
+              UNION ALL
+              SELECT jsonb_array_elements_text(
+                             CASE
+                                 WHEN jsonb_typeof(o.payload -> 'data' -> 'assigned_reviewers') = 'array'
+                                     THEN o.payload -> 'data' -> 'assigned_reviewers'
+                                 ELSE '[]'::jsonb
+                                 END)
+              UNION ALL
+              SELECT pr.author_id FROM pull_requests pr WHERE pr.id = o.aggregate_id AND o.event_type != 'user.deactivated'
+              UNION ALL
+              SELECT o.payload -> 'data' ->> 'user_id' WHERE o.event_type = 'user.deactivated') s
+        WHERE s.user_id IS NOT NULL
+    )
+WHERE participants = '{}';
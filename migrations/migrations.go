@@ -0,0 +1,8 @@
+// Package migrations встраивает SQL-миграции в бинарник.
+// Файлы именуются в формате golang-migrate: NNN_name.up.sql и NNN_name.down.sql.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS